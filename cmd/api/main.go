@@ -5,6 +5,7 @@ import (
 	"Proyectos-UTEQ/api-ortografia/internal/db"
 	"Proyectos-UTEQ/api-ortografia/internal/handlers"
 	"Proyectos-UTEQ/api-ortografia/internal/services"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
@@ -23,6 +24,30 @@ func main() {
 	config.SetDefault("APP_PORT", "3000")
 	config.SetDefault("PORT", "8080")
 	config.SetDefault("APP_ENV", "development")
+	config.SetDefault("APP_JWT_ACCESS_TTL", "15m")
+	config.SetDefault("APP_JWT_REFRESH_TTL", "720h")
+	config.SetDefault("APP_EMAIL_VERIFICATION_TTL", "48h")
+	config.SetDefault("APP_RESET_PASSWORD_TTL", "30m")
+	config.SetDefault("APP_RESET_PASSWORD_WINDOW", "1h")
+	config.SetDefault("APP_RESET_PASSWORD_MAX_PER_EMAIL", 3)
+	config.SetDefault("APP_RESET_PASSWORD_MAX_PER_IP", 10)
+	config.SetDefault("APP_LOGIN_MAX_ATTEMPTS", 5)
+	config.SetDefault("APP_LOGIN_LOCKOUT", "15m")
+	config.SetDefault("APP_LOGIN_BASE_DELAY", "1s")
+	config.SetDefault("APP_LOGIN_MAX_DELAY", "30s")
+	config.SetDefault("APP_LOGIN_IP_WINDOW", "15m")
+	config.SetDefault("APP_LOGIN_MAX_ATTEMPTS_PER_IP", 50)
+	config.SetDefault("APP_TOTP_ISSUER", "Poliword")
+	config.SetDefault("APP_TWO_FACTOR_CHALLENGE_TTL", "5m")
+	config.SetDefault("APP_IMPORT_MAX_ROWS", 1000)
+	config.SetDefault("APP_IMPORT_INVITE_TTL", "168h")
+	config.SetDefault("APP_ACCOUNT_DELETION_GRACE", "720h")
+	config.SetDefault("APP_ACCOUNT_DELETION_INTERVAL", "1h")
+	config.SetDefault("APP_ACCOUNT_DELETION_TOKEN_TTL", "30m")
+	config.SetDefault("APP_AVATAR_MAX_SIZE", "2MB")
+	config.SetDefault("APP_IMPERSONATION_TTL", "10m")
+	config.SetDefault("GOOGLE_CALLBACK_URL", "http://localhost:3000/api/auth/google/callback")
+	config.SetDefault("GOOGLE_REDIRECT_URL", "http://localhost:5173/onboard")
 
 	// Read the config file
 	config.SetConfigName("config")
@@ -37,15 +62,35 @@ func main() {
 		log.Println(err)
 	}
 
+	// Las urls de los avatares se completan con el host de la API.
+	data.PublicHost = config.GetString("APP_HOST")
+
 	// Connect to the database
 	database := db.ConnectDB(config)
 
 	migrate := config.GetBool("APP_MIGRATE")
 	if migrate {
+		// los módulos creados antes de las versiones se publican como versión 1 al crear la tabla.
+		moduleVersioning := database.Migrator().HasTable(&data.ModuleVersion{})
+
 		// Migrate the schema
 		err = database.AutoMigrate(
 			&data.User{},
 			&data.ResetPassword{},
+			&data.RefreshToken{},
+			&data.Session{},
+			&data.EmailVerification{},
+			&data.AccountDeletionConfirmation{},
+			&data.ApprovalDecision{},
+			&data.ThrottleEvent{},
+			&data.RecoveryCode{},
+			&data.TwoFactorPolicy{},
+			&data.Permission{},
+			&data.Role{},
+			&data.GuardianLink{},
+			&data.AuditLog{},
+			&data.APIKey{},
+			&data.ModuleVersion{},
 			&data.Module{},
 			&data.Subscription{},
 			&data.Class{},
@@ -58,11 +103,38 @@ func main() {
 			&data.Answer{},
 			&data.Questionnaire{},
 			&data.TestModule{},
+			&data.LearningPath{},
+			&data.LearningPathModule{},
+			&data.ModulePrerequisite{},
+			&data.ModuleCollaborator{},
 		)
 		if err != nil {
 			fmt.Println(err)
 		}
+
+		// Los registros de auditoría solo se insertan.
+		if err := data.ProtectAuditLogs(); err != nil {
+			log.Println("Error al proteger la auditoría", err)
+		}
+
+		// Búsqueda de texto completo en español de los módulos.
+		if err := data.SetupModuleSearch(); err != nil {
+			log.Println("Error al crear la búsqueda de módulos", err)
+		}
+
+		if !moduleVersioning {
+			if err := data.PublishLegacyModules(); err != nil {
+				log.Println("Error al publicar los módulos existentes", err)
+			}
+		}
+	}
+
+	// Registra los permisos y los roles base.
+	if err := data.SeedRoles(); err != nil {
+		log.Println("Error al registrar los roles", err)
 	}
+	// los roles se validan contra la base de datos.
+	types.RoleExists = data.RoleExists
 
 	// Create fiber app
 	app := fiber.New(fiber.Config{
@@ -87,6 +159,18 @@ func main() {
 	authHandler.ConfigProvider()
 	jwtHandler := handlers.NewJWTHandler(config)
 	moduleHandler := handlers.NewModuleHandler(config)
+	approvalHandler := handlers.NewApprovalHandler(config)
+	twoFactorHandler := handlers.NewTwoFactorHandler(config)
+	roleHandler := handlers.NewRoleHandler(config)
+	guardianHandler := handlers.NewGuardianHandler(config)
+	accountHandler := handlers.NewAccountHandler(config)
+	auditHandler := handlers.NewAuditHandler(config)
+	sessionHandler := handlers.NewSessionHandler(config)
+	apiKeyHandler := handlers.NewAPIKeyHandler(config)
+	avatarHandler := handlers.NewAvatarHandler(config)
+	impersonationHandler := handlers.NewImpersonationHandler(config)
+	moduleVersionHandler := handlers.NewModuleVersionHandler(config)
+	moduleBundleHandler := handlers.NewModuleBundleHandler(config)
 
 	api := app.Group("/api")
 
@@ -98,7 +182,12 @@ func main() {
 	auth := api.Group("/auth")
 	// Routes for auth users
 	auth.Post("/sign-in", userHandler.HandlerSignin)
+	auth.Post("/sign-in/2fa", twoFactorHandler.HandlerSignin)
 	auth.Post("/sign-up", userHandler.HandlerSignup)
+	auth.Post("/refresh", userHandler.HandlerRefreshToken)
+	auth.Post("/logout", userHandler.HandlerLogout)
+	auth.Post("/verify-email", userHandler.HandlerVerifyEmail)
+	auth.Post("/verify-email/resend", userHandler.HandlerResendVerification)
 	auth.Post("/reset-password", userHandler.HandlerResetPassword) // se encarga de enviar el correo electronico al usuario
 	auth.Put("/change-password", userHandler.HandlerChangePassword)
 	auth.Put("/change-password/inside", jwtHandler.JWTMiddleware, userHandler.HandlerChangePasswordInside)
@@ -107,54 +196,155 @@ func main() {
 	auth.Get("/google/callback", adaptor.HTTPHandlerFunc(authHandler.GetAuthCallbackFunction))
 	auth.Get("/google/success", adaptor.HTTPHandlerFunc(authHandler.GetAuthSuccessFunction))
 
-	userGroup := api.Group("/users", jwtHandler.JWTMiddleware)
-	userGroup.Get("/me", userHandler.HandlerGetUser)
-	userGroup.Put("/me", userHandler.HandlerUpdateUser)
+	// las API keys solo se aceptan en los grupos con JWTOrAPIKeyMiddleware, en las rutas que declaran un permiso.
+	// Las rutas de la cuenta personal no aceptan API keys.
+	userGroup := api.Group("/users", jwtHandler.JWTOrAPIKeyMiddleware)
+	userGroup.Get("/me", handlers.RejectAPIKeys, userHandler.HandlerGetUser)
+	userGroup.Put("/me", handlers.RejectAPIKeys, userHandler.HandlerUpdateUser)
+	userGroup.Get("/me/export", handlers.RejectAPIKeys, handlers.DenyImpersonation, accountHandler.ExportData)
+	userGroup.Post("/me/delete", handlers.RejectAPIKeys, accountHandler.RequestDeletion)
+	userGroup.Delete("/me/delete", handlers.RejectAPIKeys, accountHandler.CancelDeletion)
+
+	// Foto de perfil, sin foto se usa el avatar con las iniciales.
+	userGroup.Post("/me/avatar", handlers.RejectAPIKeys, avatarHandler.UploadAvatar)
+	userGroup.Delete("/me/avatar", handlers.RejectAPIKeys, avatarHandler.DeleteAvatar)
+	api.Get("/avatars/:id", avatarHandler.GetAvatar)
+
+	// Sesiones abiertas en cada dispositivo.
+	userGroup.Get("/me/sessions", handlers.RejectAPIKeys, handlers.DenyImpersonation, sessionHandler.GetSessions)
+	userGroup.Delete("/me/sessions", handlers.RejectAPIKeys, sessionHandler.RevokeAllSessions)
+	userGroup.Delete("/me/sessions/:id", handlers.RejectAPIKeys, sessionHandler.RevokeSession)
+
+	// Verificación en dos pasos para profesores y administradores.
+	userGroup.Post("/me/2fa/setup", handlers.RejectAPIKeys, handlers.RequirePermission(data.PermTwoFactor), twoFactorHandler.Setup)
+	userGroup.Post("/me/2fa/enable", handlers.RejectAPIKeys, handlers.RequirePermission(data.PermTwoFactor), twoFactorHandler.Enable)
+	userGroup.Post("/me/2fa/disable", handlers.RejectAPIKeys, handlers.RequirePermission(data.PermTwoFactor), twoFactorHandler.Disable)
+	userGroup.Post("/me/2fa/recovery-codes", handlers.RejectAPIKeys, handlers.RequirePermission(data.PermTwoFactor), twoFactorHandler.RegenerateRecoveryCodes)
+	userGroup.Get("/2fa/policies", handlers.RequirePermission(data.PermSecurityManage), twoFactorHandler.GetPolicies)
+	userGroup.Put("/2fa/policies", handlers.RequirePermission(data.PermSecurityManage), twoFactorHandler.SetPolicy)
 
 	// Adminstración de usuarios
-	userGroup.Get("/", handlers.Authorization("admin"), userHandler.GetAllUsers)
-	userGroup.Put("/:id/approved", handlers.Authorization("admin"), userHandler.ActiveUser)
-	userGroup.Put("/:id/blocked", handlers.Authorization("admin"), userHandler.BlockedUser)
-
-	module := api.Group("/module", jwtHandler.JWTMiddleware) // solo con JWT se tiene acceso.
-	module.Put("/:id", handlers.Authorization("teacher", "admin"), moduleHandler.UpdateModule)
+	userGroup.Get("/", handlers.RequirePermission(data.PermUsersManage), userHandler.GetAllUsers)
+	userGroup.Get("/export", handlers.RequirePermission(data.PermUsersManage), userHandler.ExportUsers)
+	userGroup.Put("/status", handlers.RequirePermission(data.PermUsersManage), userHandler.BulkChangeStatus)
+	userGroup.Put("/:id/approved", handlers.RequirePermission(data.PermUsersApprove), userHandler.ActiveUser)
+	userGroup.Put("/:id/blocked", handlers.RequirePermission(data.PermUsersManage), userHandler.BlockedUser)
+	userGroup.Put("/:id/unlock", handlers.RequirePermission(data.PermUsersManage), userHandler.UnlockUser)
+
+	// Cola de aprobación de profesores y administradores.
+	userGroup.Get("/pending-approvals", handlers.RequirePermission(data.PermUsersApprove), approvalHandler.GetPendingApprovals)
+	userGroup.Put("/:id/approve", handlers.RequirePermission(data.PermUsersApprove), approvalHandler.ApproveUser)
+	userGroup.Put("/:id/reject", handlers.RequirePermission(data.PermUsersApprove), approvalHandler.RejectUser)
+	userGroup.Get("/:id/approvals", handlers.RequirePermission(data.PermUsersApprove), approvalHandler.GetApprovalHistory)
+
+	userGroup.Put("/:id/role", handlers.RequirePermission(data.PermRolesManage), roleHandler.SetUserRole)
+
+	// Administración de roles y permisos.
+	rolesGroup := api.Group("/roles", jwtHandler.JWTMiddleware, handlers.RequirePermission(data.PermRolesManage))
+	rolesGroup.Get("/", roleHandler.GetRoles)
+	rolesGroup.Get("/permissions", roleHandler.GetPermissions)
+	rolesGroup.Post("/", roleHandler.CreateRole)
+	rolesGroup.Put("/:id", roleHandler.UpdateRole)
+	rolesGroup.Delete("/:id", roleHandler.DeleteRole)
+
+	// Registro de auditoría de las acciones administrativas y de calificación.
+	adminGroup := api.Group("/admin", jwtHandler.JWTOrAPIKeyMiddleware, handlers.DenyImpersonation)
+	adminGroup.Get("/audit", handlers.RequirePermission(data.PermAuditView), auditHandler.GetAuditLogs)
+
+	// API keys para las integraciones, se envían en el encabezado X-API-Key.
+	adminGroup.Get("/api-keys", handlers.RequirePermission(data.PermAPIKeysManage), apiKeyHandler.GetAPIKeys)
+	adminGroup.Post("/api-keys", handlers.RequirePermission(data.PermAPIKeysManage), apiKeyHandler.CreateAPIKey)
+	adminGroup.Delete("/api-keys/:id", handlers.RequirePermission(data.PermAPIKeysManage), apiKeyHandler.RevokeAPIKey)
+
+	// Ver la aplicación como otro usuario para dar soporte, el token es de corta duración y se audita.
+	adminGroup.Post("/impersonate/:id", handlers.RequirePermission(data.PermUsersImpersonate), impersonationHandler.Impersonate)
+
+	// Representantes con acceso de solo lectura al progreso de los estudiantes vinculados.
+	guardianGroup := api.Group("/guardians", jwtHandler.JWTMiddleware)
+	guardianGroup.Post("/links", handlers.RequirePermission(data.PermGuardianView), guardianHandler.RequestLink)
+	guardianGroup.Get("/links", handlers.RequirePermission(data.PermGuardianView), guardianHandler.GetLinks)
+	guardianGroup.Delete("/links/:id", guardianHandler.DeleteLink)
+	guardianGroup.Get("/invitations", guardianHandler.GetPendingInvitations)
+	guardianGroup.Put("/invitations/:id/accept", guardianHandler.AcceptInvitation)
+	guardianGroup.Put("/invitations/:id/reject", guardianHandler.RejectInvitation)
+	guardianStudents := guardianGroup.Group("/students", handlers.RequirePermission(data.PermGuardianView))
+	guardianStudents.Get("/", guardianHandler.GetStudents)
+	guardianStudents.Get("/:id/modules", handlers.RequireOwnership(handlers.GuardianOf("id")), guardianHandler.GetStudentModules)
+	guardianStudents.Get("/:id/tests", handlers.RequireOwnership(handlers.GuardianOf("id")), guardianHandler.GetStudentTests)
+	guardianStudents.Get("/:id/qualifications", handlers.RequireOwnership(handlers.GuardianOf("id")), guardianHandler.GetStudentQualifications)
+	guardianStudents.Get("/:id/classes", handlers.RequireOwnership(handlers.GuardianOf("id")), guardianHandler.GetStudentClasses)
+
+	module := api.Group("/module", jwtHandler.JWTOrAPIKeyMiddleware) // solo con JWT o con una API key se tiene acceso.
+	module.Put("/:id", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleOwner("id")), moduleHandler.UpdateModule)
 	// Lista todos los modulos.
-	module.Get("/teacher", handlers.Authorization("teacher", "admin"), moduleHandler.GetModulesForTeacher)
+	module.Get("/teacher", handlers.RequirePermission(data.PermModuleEdit), moduleHandler.GetModulesForTeacher)
 	// Lista todos los modulos.
-	module.Get("/", moduleHandler.GetModules)
+	module.Get("/", handlers.RejectAPIKeys, moduleHandler.GetModules)
 
 	// Recupera todos los modulos y ademas indica si el usuario esta suscrito o no.
-	module.Get("/with-is-subscribed", moduleHandler.GetModuleWithIsSubscribed)
+	module.Get("/with-is-subscribed", handlers.RejectAPIKeys, moduleHandler.GetModuleWithIsSubscribed)
 
-	module.Post("/subscribe", moduleHandler.Subscribe)
-	module.Get("/subscribed", moduleHandler.Subscriptions)
+	module.Post("/subscribe", handlers.RequirePermission(data.PermTestTake), moduleHandler.Subscribe)
+	module.Get("/subscribed", handlers.RejectAPIKeys, moduleHandler.Subscriptions)
 
 	// Listar todos los estudiantes que estan suscritos a un modulo.
-	module.Get("/:id/students", moduleHandler.GetStudents)
+	module.Get("/:id/students", handlers.RequirePermission(data.PermModuleEdit), moduleHandler.GetStudents)
+	module.Get("/:id/grades", handlers.RequirePermission(data.PermGradesRead), handlers.RequireOwnership(handlers.ModuleOwner("id")), moduleHandler.GetGrades)
+
+	// Borrador y versiones publicadas del módulo, los estudiantes solo ven la versión publicada.
+	module.Get("/:id/preview", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleViewer("id")), moduleVersionHandler.PreviewModule)
+	module.Post("/:id/publish", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleOwner("id")), moduleVersionHandler.PublishModule)
+	module.Get("/:id/versions", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleViewer("id")), moduleVersionHandler.GetVersions)
+	module.Get("/:id/versions/:version", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleViewer("id")), moduleVersionHandler.GetVersion)
 
 	// Routes for modules
 	// Crea un modulo.
-	module.Post("/", handlers.Authorization("teacher", "admin"), moduleHandler.CreateModuleForTeacher)
-	module.Get("/:id", moduleHandler.GetModuleByID) // Recupera un módulo por el ID
+	module.Post("/", handlers.RequirePermission(data.PermModuleCreate), moduleHandler.CreateModuleForTeacher)
+	module.Post("/:id/clone", handlers.RequirePermission(data.PermModuleCreate), moduleHandler.CloneModule)
+
+	// Colaboradores del módulo, solo el dueño invita, cambia roles y transfiere el módulo.
+	moduleCollaboratorHandler := handlers.NewModuleCollaboratorHandler(config)
+	module.Get("/invitations", handlers.RequirePermission(data.PermModuleEdit), moduleCollaboratorHandler.GetInvitations)
+	module.Put("/invitations/:id/accept", handlers.RequirePermission(data.PermModuleEdit), moduleCollaboratorHandler.AcceptInvitation)
+	module.Delete("/invitations/:id", handlers.RejectAPIKeys, moduleCollaboratorHandler.LeaveCollaboration)
+	module.Get("/:id/collaborators", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleViewer("id")), moduleCollaboratorHandler.GetCollaborators)
+	module.Post("/:id/collaborators", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleCreator("id")), moduleCollaboratorHandler.InviteCollaborator)
+	module.Put("/:id/collaborators/:collaborator", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleCreator("id")), moduleCollaboratorHandler.UpdateCollaborator)
+	module.Delete("/:id/collaborators/:collaborator", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleCreator("id")), moduleCollaboratorHandler.RemoveCollaborator)
+	module.Put("/:id/owner", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleCreator("id")), moduleCollaboratorHandler.TransferModule)
+
+	// Exportación e importación de módulos entre instalaciones.
+	module.Get("/:id/export", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleViewer("id")), moduleBundleHandler.ExportModule)
+	module.Post("/import", handlers.RequirePermission(data.PermModuleCreate), moduleBundleHandler.ImportModule)
+	// Prerrequisitos para desbloquear los test del módulo
+	learningPathHandler := handlers.NewLearningPathHandler(config)
+	module.Get("/:id/prerequisites", handlers.RejectAPIKeys, learningPathHandler.GetModulePrerequisites)
+	module.Put("/:id/prerequisites", handlers.RequirePermission(data.PermModuleEdit), handlers.RequireOwnership(handlers.ModuleOwner("id")), learningPathHandler.SetModulePrerequisites)
+	module.Get("/:id", handlers.RejectAPIKeys, moduleHandler.GetModuleByID) // Recupera un módulo por el ID
 
 	// Rutas para los test de los módulos.
-	testModule := module.Group("/:id/test", handlers.Authorization("student"))
+	testModule := module.Group("/:id/test", handlers.RequirePermission(data.PermTestTake))
 	testModule.Post("/", moduleHandler.GenerateTest)
 	testModule.Get("/my-tests", moduleHandler.GetMyTestsByModule)
-	module.Get("/test/:id", moduleHandler.GetTestByID)
-	module.Put("/test/validate-answer/:answer_user_id", handlers.Authorization("student"), moduleHandler.ValidationAnswerForTestModule)
-	module.Post("/test/feedback-answer/:answer_user_id", handlers.Authorization("student"), moduleHandler.GetFeedbackAnswerUser)
-	module.Put("/test/:id/finish", handlers.Authorization("student"), moduleHandler.FinishTest)
+	module.Get("/test/:id", handlers.RejectAPIKeys, handlers.RequireOwnership(handlers.TestViewer("id")), moduleHandler.GetTestByID)
+	module.Put("/test/validate-answer/:answer_user_id", handlers.RequirePermission(data.PermTestTake), handlers.RequireOwnership(handlers.AnswerUserOwner("answer_user_id")), moduleHandler.ValidationAnswerForTestModule)
+	module.Post("/test/feedback-answer/:answer_user_id", handlers.RequirePermission(data.PermTestTake), handlers.RequireOwnership(handlers.AnswerUserOwner("answer_user_id")), moduleHandler.GetFeedbackAnswerUser)
+	module.Put("/test/:id/finish", handlers.RequirePermission(data.PermTestTake), handlers.RequireOwnership(handlers.TestOwner("id")), moduleHandler.FinishTest)
 
 	// Routes for questions
 	questionHandler := handlers.NewQuestionHandler(config)
-	moduleQuestionGroup := module.Group("/:id/question")
-	moduleQuestionGroup.Post("/", questionHandler.RegisterQuestionForModule)
-	moduleQuestionGroup.Get("/", questionHandler.GetQuestionsForModule)
-	moduleQuestionGroup.Delete("/:idquestion", questionHandler.DeleteQuestion)
-	moduleQuestionGroup.Put("/:idquestion", questionHandler.UpdateQuestion)
+	moduleQuestionGroup := module.Group("/:id/question", handlers.RequirePermission(data.PermQuestionManage))
+	moduleQuestionGroup.Post("/", handlers.RequireOwnership(handlers.ModuleOwner("id")), questionHandler.RegisterQuestionForModule)
+	moduleQuestionGroup.Get("/", handlers.RequireOwnership(handlers.ModuleViewer("id")), questionHandler.GetQuestionsForModule)
+	moduleQuestionGroup.Delete("/:idquestion", handlers.RequireOwnership(handlers.QuestionOwner("id", "idquestion")), questionHandler.DeleteQuestion)
+	moduleQuestionGroup.Put("/:idquestion", handlers.RequireOwnership(handlers.QuestionOwner("id", "idquestion")), questionHandler.UpdateQuestion)
 	moduleQuestionGroup.Get("/activities", questionHandler.GetActivityForModule)
-	module.Get("/question/:id", questionHandler.GetQuestionByID)
+	module.Get("/question/:id", handlers.RequirePermission(data.PermQuestionManage), questionHandler.GetQuestionByID)
+
+	// Routes for question banks (Moodle GIFT and QTI 2.1)
+	questionInterchangeHandler := handlers.NewQuestionInterchangeHandler(config)
+	module.Get("/:id/questions/export", handlers.RequirePermission(data.PermQuestionManage), handlers.RequireOwnership(handlers.ModuleViewer("id")), questionInterchangeHandler.ExportQuestions)
+	module.Post("/:id/questions/import", handlers.RequirePermission(data.PermQuestionManage), handlers.RequireOwnership(handlers.ModuleOwner("id")), questionInterchangeHandler.ImportQuestions)
 
 	// Routes for upload
 	upload := api.Group("/uploads")
@@ -162,7 +352,7 @@ func main() {
 
 	// Routes for GPT AI.
 	gptHandlers := handlers.NewGPTHandler(config)
-	gptGroup := api.Group("/gpt", jwtHandler.JWTMiddleware, handlers.Authorization("admin", "teacher", "student"))
+	gptGroup := api.Group("/gpt", jwtHandler.JWTOrAPIKeyMiddleware, handlers.RequirePermission(data.PermAIGenerate))
 	gptGroup.Post("/generate-question", gptHandlers.GenerateQuestion)
 	gptGroup.Post("/generate-response", gptHandlers.GenerateResponse)
 	gptGroup.Post("/generate-image", gptHandlers.GenerateImage)
@@ -172,19 +362,30 @@ func main() {
 	upload.Static("/", "./uploads")
 	upload.Post("/google", jwtHandler.JWTMiddleware, uploadHandler.UploadFileToGoogle)
 
+	// Routes for learning paths
+	learningPaths := api.Group("/learning-paths", jwtHandler.JWTOrAPIKeyMiddleware)
+	learningPaths.Get("/", handlers.RejectAPIKeys, learningPathHandler.GetLearningPaths)
+	learningPaths.Post("/", handlers.RequirePermission(data.PermLearningPaths), learningPathHandler.CreateLearningPath)
+	learningPaths.Get("/:id", handlers.RejectAPIKeys, learningPathHandler.GetLearningPath)
+	learningPaths.Put("/:id", handlers.RequirePermission(data.PermLearningPaths), handlers.RequireOwnership(handlers.LearningPathOwner("id")), learningPathHandler.UpdateLearningPath)
+	learningPaths.Delete("/:id", handlers.RequirePermission(data.PermLearningPaths), handlers.RequireOwnership(handlers.LearningPathOwner("id")), learningPathHandler.DeleteLearningPath)
+
 	classesHandler := handlers.NewClassesHandler(config)
-	classesGroup := api.Group("/classes", jwtHandler.JWTMiddleware)
-	classesGroup.Post("/", handlers.Authorization("teacher", "admin"), classesHandler.NewClasses)
-	classesGroup.Put("/:id", handlers.Authorization("teacher", "admin"), classesHandler.UpdateClassByID)
-	classesGroup.Put("/:id/archive", handlers.Authorization("teacher", "admin"), classesHandler.ArchiveClassByID)
-	classesGroup.Post("/subscribe", handlers.Authorization("student"), classesHandler.SuscribeClass)
-	classesGroup.Delete("/:id/unsubscribe", handlers.Authorization("student"), classesHandler.UnsubscribeClass)
-	classesGroup.Get("/subscribed", handlers.Authorization("student"), classesHandler.GetClassesSubscribedByStudent)
-	classesGroup.Get("/:id/students", classesHandler.GetStudentsByClass)
-	api.Get("/professors/:id/classes", jwtHandler.JWTMiddleware, handlers.Authorization("teacher"), classesHandler.GetClassesByTeacher)
-	api.Get("/professors/:id/classes/archived", jwtHandler.JWTMiddleware, handlers.Authorization("teacher"), classesHandler.GetClassesArchivedByTeacher)
+	importHandler := handlers.NewImportHandler(config)
+	classesGroup := api.Group("/classes", jwtHandler.JWTOrAPIKeyMiddleware)
+	classesGroup.Post("/", handlers.RequirePermission(data.PermClassManage), classesHandler.NewClasses)
+	classesGroup.Put("/:id", handlers.RequirePermission(data.PermClassManage), handlers.RequireOwnership(handlers.ClassOwner("id")), classesHandler.UpdateClassByID)
+	classesGroup.Post("/:id/import", handlers.RequirePermission(data.PermClassManage, data.PermUsersCreate), handlers.RequireOwnership(handlers.ClassOwner("id")), importHandler.ImportStudents)
+	classesGroup.Put("/:id/archive", handlers.RequirePermission(data.PermClassManage), handlers.RequireOwnership(handlers.ClassOwner("id")), classesHandler.ArchiveClassByID)
+	classesGroup.Post("/subscribe", handlers.RequirePermission(data.PermClassEnroll), classesHandler.SuscribeClass)
+	classesGroup.Delete("/:id/unsubscribe", handlers.RequirePermission(data.PermClassEnroll), classesHandler.UnsubscribeClass)
+	classesGroup.Get("/subscribed", handlers.RequirePermission(data.PermClassEnroll), classesHandler.GetClassesSubscribedByStudent)
+	classesGroup.Get("/:id/students", handlers.RequirePermission(data.PermClassManage, data.PermClassEnroll), classesHandler.GetStudentsByClass)
+	api.Get("/professors/:id/classes", jwtHandler.JWTOrAPIKeyMiddleware, handlers.RequirePermission(data.PermClassManage), classesHandler.GetClassesByTeacher)
+	api.Get("/professors/:id/classes/archived", jwtHandler.JWTOrAPIKeyMiddleware, handlers.RequirePermission(data.PermClassManage), classesHandler.GetClassesArchivedByTeacher)
 
 	go services.TelegramBot(config)
+	go services.AccountDeletionWorker(config)
 	err = app.Listen(":" + config.GetString("PORT"))
 	if err != nil {
 		log.Println(err)
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// AccountDeletionConfirmation token enviado al correo para confirmar la eliminación de las cuentas sin contraseña
+// (Google). Solo se guarda el hash del token.
+type AccountDeletionConfirmation struct {
+	gorm.Model
+	UserID    uint
+	User      User
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+func (AccountDeletionConfirmation) TableName() string {
+	return "account_deletion_confirmations"
+}
+
+// CheckUserPassword revisa la contraseña del usuario, los usuarios sin contraseña (Google) nunca la cumplen
+// y deben confirmar con el token enviado al correo.
+func CheckUserPassword(userID uint, password string) bool {
+	var user User
+	result := db.DB.Select("password").First(&user, userID)
+	if result.Error != nil || user.Password == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+// HasPassword indica si el usuario tiene contraseña, los usuarios registrados con Google no la tienen.
+func HasPassword(userID uint) (bool, error) {
+	var user User
+	result := db.DB.Select("password").First(&user, userID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return user.Password != "", nil
+}
+
+// SaveDeletionConfirmation registra el token para confirmar la eliminación, los tokens anteriores se invalidan.
+func SaveDeletionConfirmation(userID uint, tokenHash string, expiresAt time.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AccountDeletionConfirmation{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&AccountDeletionConfirmation{
+			UserID:    userID,
+			TokenHash: tokenHash,
+			ExpiresAt: expiresAt,
+		}).Error
+	})
+}
+
+// UseDeletionConfirmation revisa que el token sea del usuario, no haya expirado ni se haya utilizado, y lo marca como utilizado.
+func UseDeletionConfirmation(userID uint, tokenHash string) bool {
+	result := db.DB.Model(&AccountDeletionConfirmation{}).
+		Where("user_id = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", userID, tokenHash, time.Now()).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// RequestAccountDeletion programa la eliminación de la cuenta al terminar el periodo de gracia.
+func RequestAccountDeletion(userID uint, scheduledAt time.Time) (*User, error) {
+	var user User
+	result := db.DB.First(&user, userID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if user.DeletionScheduledAt != nil {
+		return nil, errors.New("la eliminación de la cuenta ya fue solicitada")
+	}
+
+	result = db.DB.Model(&user).Update("deletion_scheduled_at", scheduledAt)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// CancelAccountDeletion cancela la eliminación programada de la cuenta.
+func CancelAccountDeletion(userID uint) error {
+	result := db.DB.Model(&User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).
+		Update("deletion_scheduled_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("la cuenta no tiene una eliminación pendiente")
+	}
+	return nil
+}
+
+// AnonymizeDueAccounts anonimiza las cuentas cuyo periodo de gracia terminó.
+func AnonymizeDueAccounts() (int, error) {
+	var users []User
+	result := db.DB.Select("id").
+		Where("deletion_scheduled_at <= ? AND status <> ?", time.Now(), Deleted).
+		Find(&users)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	anonymized := 0
+	for _, user := range users {
+		if err := AnonymizeUser(user.ID); err != nil {
+			log.Println("Error al anonimizar el usuario", user.ID, err)
+			continue
+		}
+		anonymized++
+	}
+	return anonymized, nil
+}
+
+// AnonymizeUser elimina los datos personales del usuario y los datos que solo le sirven a él.
+// La fila del usuario, sus test, suscripciones y matrículas se conservan para que las estadísticas como
+// StudentPointsList no cambien.
+func AnonymizeUser(userID uint) error {
+	var user User
+	result := db.DB.Select("id", "email").First(&user, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"first_name":             "Usuario",
+			"last_name":              "eliminado",
+			"email":                  fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+			"password":               "",
+			"birth_date":             time.Time{},
+			"whatsapp":               "",
+			"telegram":               "",
+			"telegram_id":            0,
+			"url_avatar":             "",
+			"google_id":              "",
+			"status":                 Deleted,
+			"two_factor_enabled":     false,
+			"two_factor_secret":      "",
+			"two_factor_last_step":   0,
+			"failed_login_attempts":  0,
+			"last_failed_login_at":   nil,
+			"locked_until":           nil,
+			"deletion_scheduled_at":  nil,
+			"perfil_update_required": false,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// los mensajes con la IA pueden tener datos personales.
+		chatIssues := tx.Model(&ChatIssue{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Unscoped().Where("chat_issue_id IN (?)", chatIssues).Delete(&HistoryChat{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ChatIssue{}).Where("user_id = ?", userID).Update("issue", "").Error; err != nil {
+			return err
+		}
+
+		// las suscripciones y matrículas se conservan apuntando al usuario anonimizado para que los listados de
+		// estudiantes y las estadísticas de los módulos y clases no cambien.
+		for _, model := range []interface{}{&RefreshToken{}, &Session{}, &EmailVerification{}, &ResetPassword{}, &RecoveryCode{}, &AccountDeletionConfirmation{}} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("key = ?", "reset-password:email:"+strings.ToLower(user.Email)).Delete(&ThrottleEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("guardian_id = ? OR student_id = ?", userID, userID).Delete(&GuardianLink{}).Error; err != nil {
+			return err
+		}
+		// los módulos del profesor pasan a sus editores para que no queden sin dueño.
+		if err := transferModulesOnLeave(tx, userID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// la foto del usuario también es un dato personal.
+	return RemoveAvatarFiles(userID, "")
+}
+
+// GetUserDataExport recupera todos los datos vinculados al usuario.
+func GetUserDataExport(userID uint) (*types.UserDataExport, error) {
+	var user User
+	result := db.DB.First(&user, userID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	export := types.UserDataExport{
+		Profile: types.UserProfileExport{
+			UserAPI:   *UserToAPI(user),
+			CreatedAt: utils.GetFullDate(user.CreatedAt),
+		},
+		Tests:         make([]types.TestModule, 0),
+		Subscriptions: make([]types.SubscriptionExport, 0),
+		Enrollments:   make([]types.EnrollmentExport, 0),
+		Chats:         make([]types.ChatExport, 0),
+	}
+
+	var tests []TestModule
+	result = db.DB.Select("id").Where("user_id = ?", userID).Order("id").Find(&tests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, test := range tests {
+		testAPI, err := TestByID(test.ID)
+		if err != nil {
+			return nil, err
+		}
+		export.Tests = append(export.Tests, testAPI)
+	}
+
+	var subscriptions []Subscription
+	result = db.DB.Preload("Module").Where("user_id = ?", userID).Find(&subscriptions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, subscription := range subscriptions {
+		export.Subscriptions = append(export.Subscriptions, types.SubscriptionExport{
+			ModuleID:     subscription.ModuleID,
+			ModuleTitle:  subscription.Module.Title,
+			SubscribedAt: utils.GetFullDate(subscription.CreatedAt),
+		})
+	}
+
+	var enrollments []Matricula
+	result = db.DB.Preload("Class").Where("user_id = ?", userID).Find(&enrollments)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, enrollment := range enrollments {
+		export.Enrollments = append(export.Enrollments, types.EnrollmentExport{
+			ClassID:    enrollment.ClassID,
+			ClassName:  enrollment.Class.Name,
+			EnrolledAt: utils.GetFullDate(enrollment.CreatedAt),
+		})
+	}
+
+	var chatIssues []ChatIssue
+	result = db.DB.Where("user_id = ?", userID).Order("id").Find(&chatIssues)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, chatIssue := range chatIssues {
+		var history []HistoryChat
+		result = db.DB.Where("chat_issue_id = ?", chatIssue.ID).Order("id").Find(&history)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		chat := types.ChatExport{
+			ID:        chatIssue.ID,
+			Issue:     chatIssue.Issue,
+			CreatedAt: utils.GetFullDate(chatIssue.CreatedAt),
+			Messages:  make([]types.ChatMessageExport, 0, len(history)),
+		}
+		for _, message := range history {
+			chat.Messages = append(chat.Messages, types.ChatMessageExport{
+				Message:   message.Message,
+				IsIA:      message.IsIA,
+				CreatedAt: utils.GetFullDate(message.CreatedAt),
+			})
+		}
+		export.Chats = append(export.Chats, chat)
+	}
+
+	var guardianLinks []GuardianLink
+	result = db.DB.Preload("Guardian").Preload("Student").
+		Where("guardian_id = ? OR student_id = ?", userID, userID).
+		Find(&guardianLinks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	export.GuardianLinks = GuardianLinksToAPI(guardianLinks)
+
+	sessions, err := GetUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	export.Sessions = SessionsToAPI(sessions, 0)
+
+	return &export, nil
+}
@@ -0,0 +1,167 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix prefijo de las API keys, permite reconocerlas en los encabezados y en los escaneos de secretos.
+const APIKeyPrefix = "pw_"
+
+// permisos que no se pueden asignar a una API key.
+var apiKeyForbiddenScopes = []string{PermAPIKeysManage, PermRolesManage, PermSecurityManage, PermTwoFactor, PermUsersImpersonate}
+
+var ErrInvalidAPIKey = errors.New("la API key no es valida")
+
+// APIKey credencial de una integración, sus permisos (scopes) se eligen al crearla.
+// Solo se guarda el hash de la key, el inicio de la key permite reconocerla en el listado.
+type APIKey struct {
+	gorm.Model
+	Name        string
+	KeyPrefix   string
+	KeyHash     string       `gorm:"uniqueIndex"`
+	Permissions []Permission `gorm:"many2many:api_key_permissions;"`
+	CreatedByID uint
+	CreatedBy   User
+	ExpiresAt   *time.Time
+	RevokedAt   *time.Time
+	LastUsedAt  *time.Time
+	LastUsedIP  string
+	UsageCount  int64
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+func APIKeyToAPI(key APIKey) types.APIKey {
+	scopes := make([]string, 0, len(key.Permissions))
+	for _, permission := range key.Permissions {
+		scopes = append(scopes, permission.Name)
+	}
+
+	keyAPI := types.APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		KeyPrefix:  key.KeyPrefix,
+		Scopes:     scopes,
+		CreatedAt:  utils.GetFullDate(key.CreatedAt),
+		ExpiresAt:  utils.GetFullDateOrNull(key.ExpiresAt),
+		RevokedAt:  utils.GetFullDateOrNull(key.RevokedAt),
+		LastUsedAt: utils.GetFullDateOrNull(key.LastUsedAt),
+		LastUsedIP: key.LastUsedIP,
+		UsageCount: key.UsageCount,
+	}
+	if key.CreatedBy.ID != 0 {
+		keyAPI.CreatedBy = UserToAPI(key.CreatedBy)
+	}
+	return keyAPI
+}
+
+func APIKeysToAPI(keys []APIKey) []types.APIKey {
+	keysAPI := make([]types.APIKey, 0)
+	for _, key := range keys {
+		keysAPI = append(keysAPI, APIKeyToAPI(key))
+	}
+	return keysAPI
+}
+
+// CreateAPIKey registra una API key, los scopes deben ser permisos que tiene el administrador que la crea.
+func CreateAPIKey(name string, scopes []string, expiresAt *time.Time, createdBy *types.UserClaims) (*APIKey, string, error) {
+	for _, scope := range scopes {
+		if containsString(apiKeyForbiddenScopes, scope) {
+			return nil, "", errors.New("el permiso " + scope + " no se puede asignar a una API key")
+		}
+		if !createdBy.HasPermission(scope) {
+			return nil, "", errors.New("no puedes asignar el permiso " + scope + " porque no lo tienes")
+		}
+	}
+
+	permissions, err := findPermissions(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, _, err := utils.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := APIKeyPrefix + token
+
+	key := APIKey{
+		Name:        name,
+		KeyPrefix:   secret[:len(APIKeyPrefix)+6],
+		KeyHash:     utils.HashToken(secret),
+		Permissions: permissions,
+		CreatedByID: createdBy.UserAPI.ID,
+		ExpiresAt:   expiresAt,
+	}
+	result := db.DB.Create(&key)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	return &key, secret, nil
+}
+
+// GetAPIKeys recupera todas las API keys, las revocadas se conservan para el historial.
+func GetAPIKeys() ([]APIKey, error) {
+	var keys []APIKey
+	result := db.DB.Preload("Permissions").Preload("CreatedBy").Order("id desc").Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByID recupera una API key con sus permisos.
+func GetAPIKeyByID(id uint) (*APIKey, error) {
+	var key APIKey
+	result := db.DB.Preload("Permissions").First(&key, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey revoca una API key, deja de funcionar de inmediato.
+func RevokeAPIKey(id uint) error {
+	result := db.DB.Model(&APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UseAPIKey valida la API key y registra su uso.
+func UseAPIKey(secret, ip string) (*APIKey, error) {
+	var key APIKey
+	result := db.DB.Preload("Permissions").Where("key_hash = ?", utils.HashToken(secret)).First(&key)
+	if result.Error != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("la API key fue revocada")
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, errors.New("la API key ha expirado")
+	}
+
+	db.DB.Model(&APIKey{}).Where("id = ?", key.ID).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"last_used_ip": ip,
+		"usage_count":  gorm.Expr("usage_count + 1"),
+	})
+
+	return &key, nil
+}
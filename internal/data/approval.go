@@ -0,0 +1,175 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ApprovalDecision historial de aprobaciones y rechazos de profesores y administradores.
+type ApprovalDecision struct {
+	gorm.Model
+	UserID      uint
+	User        User
+	DecidedByID uint
+	DecidedBy   User `gorm:"foreignKey:DecidedByID"`
+	Decision    Decision
+	Reason      string
+}
+
+type Decision string
+
+const (
+	DecisionApproved Decision = "approved"
+	DecisionRejected Decision = "rejected"
+)
+
+func (ApprovalDecision) TableName() string {
+	return "approval_decisions"
+}
+
+func ApprovalDecisionToAPI(decision ApprovalDecision) types.ApprovalDecision {
+	return types.ApprovalDecision{
+		ID:        decision.ID,
+		CreatedAt: utils.GetFullDate(decision.CreatedAt),
+		UserID:    decision.UserID,
+		DecidedBy: UserToAPI(decision.DecidedBy),
+		Decision:  string(decision.Decision),
+		Reason:    decision.Reason,
+	}
+}
+
+func ApprovalDecisionsToAPI(decisions []ApprovalDecision) []types.ApprovalDecision {
+	decisionsAPI := make([]types.ApprovalDecision, 0)
+	for _, decision := range decisions {
+		decisionsAPI = append(decisionsAPI, ApprovalDecisionToAPI(decision))
+	}
+	return decisionsAPI
+}
+
+// GetPendingApprovals recupera los profesores y administradores pendientes de aprobación.
+func GetPendingApprovals(paginated *types.Paginated, filter *types.ApprovalFilter) ([]User, *types.PagintaedDetails, error) {
+	var users []User
+	var paginatedDetails types.PagintaedDetails
+
+	query := func() *gorm.DB {
+		status := PendingApproval
+		if filter.Status == string(Rejected) {
+			status = Rejected
+		}
+
+		tx := db.DB.Model(&User{}).
+			Where("status = ?", status).
+			Where("type_user IN ?", []TypeUser{Teacher, Admin})
+
+		if filter.TypeUser != "" {
+			tx = tx.Where("type_user = ?", filter.TypeUser)
+		}
+		if paginated.Query != "" {
+			tx = tx.Where("first_name ILIKE ? OR last_name ILIKE ? OR email ILIKE ?",
+				"%"+paginated.Query+"%", "%"+paginated.Query+"%", "%"+paginated.Query+"%")
+		}
+		if from, _ := utils.ParseDateOrNull(filter.From); from != nil {
+			tx = tx.Where("created_at >= ?", *from)
+		}
+		if to, _ := utils.ParseDateOrNull(filter.To); to != nil {
+			tx = tx.Where("created_at < ?", to.AddDate(0, 0, 1))
+		}
+		return tx
+	}
+
+	query().Count(&paginatedDetails.TotalItems)
+	paginatedDetails.Page = paginated.Page
+	paginatedDetails.TotalPage = int64(math.Ceil(float64(paginatedDetails.TotalItems) / float64(paginated.Limit)))
+
+	result := query().
+		Order(fmt.Sprintf("%s %s", paginated.Sort, paginated.Order)).
+		Limit(paginated.Limit).
+		Offset((paginated.Page - 1) * paginated.Limit).
+		Find(&users)
+
+	paginatedDetails.ItemsPerPage = len(users)
+
+	if result.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) {
+			if pgErr.Code == "42703" {
+				return nil, nil, fmt.Errorf("columna inexistente: %s", paginated.Sort)
+			}
+		}
+		return nil, nil, result.Error
+	}
+
+	return users, &paginatedDetails, nil
+}
+
+// DecideApproval aprueba o rechaza la solicitud de un profesor o administrador y registra la decisión.
+func DecideApproval(userID, adminID uint, approved bool, reason string) (*User, error) {
+	var user User
+	result := db.DB.First(&user, userID)
+	if result.Error != nil {
+		return nil, errors.New("el usuario no existe")
+	}
+
+	decision := DecisionApproved
+	status := Actived
+	if !approved {
+		decision = DecisionRejected
+		status = Rejected
+	}
+
+	// solo se pueden rechazar solicitudes pendientes, las rechazadas se pueden aprobar después.
+	if user.Status != PendingApproval && (!approved || user.Status != Rejected) {
+		return nil, errors.New("el usuario no tiene una solicitud pendiente")
+	}
+
+	tx := db.DB.Begin()
+
+	result = tx.Model(&User{}).Where("id = ?", user.ID).Update("status", status)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+
+	result = tx.Create(&ApprovalDecision{
+		UserID:      user.ID,
+		DecidedByID: adminID,
+		Decision:    decision,
+		Reason:      reason,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+
+	tx.Commit()
+
+	user.Status = status
+	return &user, nil
+}
+
+// GetApprovalHistory recupera el historial de decisiones de un usuario.
+func GetApprovalHistory(userID uint) ([]ApprovalDecision, error) {
+	var decisions []ApprovalDecision
+	result := db.DB.Preload("DecidedBy").Where("user_id = ?", userID).Order("created_at desc").Find(&decisions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return decisions, nil
+}
+
+// GetActiveAdmins recupera los administradores activos para notificarles.
+func GetActiveAdmins() ([]User, error) {
+	var admins []User
+	result := db.DB.Where("type_user = ? AND status = ?", Admin, Actived).Find(&admins)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return admins, nil
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"encoding/json"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Acciones que se registran en la auditoría.
+const (
+	AuditUserApproved        = "user.approved"
+	AuditUserRejected        = "user.rejected"
+	AuditUserBlocked         = "user.blocked"
+	AuditUserUnlocked        = "user.unlocked"
+	AuditUserUnblocked       = "user.unblocked"
+	AuditUserRole            = "user.role"
+	AuditRoleCreated         = "role.created"
+	AuditRoleUpdated         = "role.updated"
+	AuditRoleDeleted         = "role.deleted"
+	AuditModuleCreated       = "module.created"
+	AuditModuleUpdated       = "module.updated"
+	AuditModulePublished     = "module.published"
+	AuditModuleCloned        = "module.cloned"
+	AuditModuleImported      = "module.imported"
+	AuditModulePrerequisites = "module.prerequisites"
+	AuditPathCreated         = "learning_path.created"
+	AuditPathUpdated         = "learning_path.updated"
+	AuditPathDeleted         = "learning_path.deleted"
+	AuditCollaboratorInvited = "module.collaborator_invited"
+	AuditCollaboratorUpdated = "module.collaborator_updated"
+	AuditCollaboratorRemoved = "module.collaborator_removed"
+	AuditModuleTransferred   = "module.transferred"
+	AuditQuestionCreate      = "question.created"
+	AuditQuestionUpdate      = "question.updated"
+	AuditQuestionDelete      = "question.deleted"
+	AuditQuestionsImport     = "questions.imported"
+	AuditClassCreated        = "class.created"
+	AuditClassUpdated        = "class.updated"
+	AuditClassArchived       = "class.archived"
+	AuditTestFinished        = "test.finished"
+	AuditAPIKeyCreated       = "api_key.created"
+	AuditAPIKeyRevoked       = "api_key.revoked"
+	// suplantación de usuarios por los administradores.
+	AuditImpersonationStarted = "impersonation.started"
+	AuditImpersonationRequest = "impersonation.request"
+	AuditImpersonationBlocked = "impersonation.blocked"
+)
+
+// AuditLog registro de una acción administrativa o de calificación.
+// Los registros solo se insertan, no se modifican ni se eliminan.
+type AuditLog struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	ActorID   *uint
+	Actor     User `gorm:"foreignKey:ActorID"`
+	APIKeyID  *uint
+	// OnBehalfOfID usuario suplantado por el actor.
+	OnBehalfOfID *uint  `gorm:"index"`
+	Action       string `gorm:"index"`
+	EntityType   string `gorm:"index"`
+	EntityID     uint   `gorm:"index"`
+	Before       string `gorm:"type:jsonb"`
+	After        string `gorm:"type:jsonb"`
+	IP           string
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+func AuditLogToAPI(log AuditLog) types.AuditLog {
+	logAPI := types.AuditLog{
+		ID:           log.ID,
+		CreatedAt:    utils.GetFullDate(log.CreatedAt),
+		ActorID:      log.ActorID,
+		APIKeyID:     log.APIKeyID,
+		OnBehalfOfID: log.OnBehalfOfID,
+		Action:       log.Action,
+		EntityType:   log.EntityType,
+		EntityID:     log.EntityID,
+		Before:       json.RawMessage(log.Before),
+		After:        json.RawMessage(log.After),
+		IP:           log.IP,
+	}
+	if log.Actor.ID != 0 {
+		logAPI.Actor = UserToAPI(log.Actor)
+	}
+	return logAPI
+}
+
+func AuditLogsToAPI(logs []AuditLog) []types.AuditLog {
+	logsAPI := make([]types.AuditLog, 0)
+	for _, log := range logs {
+		logsAPI = append(logsAPI, AuditLogToAPI(log))
+	}
+	return logsAPI
+}
+
+// ProtectAuditLogs crea el trigger que impide modificar o eliminar los registros de auditoría.
+func ProtectAuditLogs() error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'los registros de auditoría no se pueden modificar ni eliminar';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs`,
+		`CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+		FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`,
+	}
+	for _, statement := range statements {
+		if err := db.DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordAudit registra una acción, before y after son el estado de la entidad antes y después del cambio.
+func RecordAudit(entry AuditLog, before, after interface{}) error {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	entry.Before = string(beforeJSON)
+	entry.After = string(afterJSON)
+	return db.DB.Create(&entry).Error
+}
+
+// GetAuditLogs recupera los registros de auditoría paginados, los más recientes primero.
+func GetAuditLogs(paginated *types.Paginated, filter *types.AuditFilter) ([]AuditLog, *types.PagintaedDetails, error) {
+	var logs []AuditLog
+	var paginatedDetails types.PagintaedDetails
+
+	result := auditQuery(filter).Count(&paginatedDetails.TotalItems)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	paginatedDetails.Page = paginated.Page
+	paginatedDetails.TotalPage = int64(math.Ceil(float64(paginatedDetails.TotalItems) / float64(paginated.Limit)))
+
+	result = auditQuery(filter).
+		Preload("Actor").
+		Order("id desc").
+		Limit(paginated.Limit).
+		Offset((paginated.Page - 1) * paginated.Limit).
+		Find(&logs)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	paginatedDetails.ItemsPerPage = len(logs)
+
+	return logs, &paginatedDetails, nil
+}
+
+func auditQuery(filter *types.AuditFilter) *gorm.DB {
+	query := db.DB.Model(&AuditLog{})
+
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.OnBehalfOfID != 0 {
+		query = query.Where("on_behalf_of_id = ?", filter.OnBehalfOfID)
+	}
+	if filter.APIKeyID != 0 {
+		query = query.Where("api_key_id = ?", filter.APIKeyID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if from, _ := utils.ParseDateOrNull(filter.From); from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to, _ := utils.ParseDateOrNull(filter.To); to != nil {
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	return query
+}
+
+// AuditUserStates recupera el estado de los usuarios que se guarda en la auditoría.
+func AuditUserStates(userIDs ...uint) map[uint]map[string]interface{} {
+	var users []User
+	states := make(map[uint]map[string]interface{})
+
+	result := db.DB.Select("id", "status", "type_user", "failed_login_attempts", "locked_until").
+		Where("id IN ?", userIDs).
+		Find(&users)
+	if result.Error != nil {
+		return states
+	}
+
+	for _, user := range users {
+		states[user.ID] = map[string]interface{}{
+			"status":                user.Status,
+			"type_user":             user.TypeUser,
+			"failed_login_attempts": user.FailedLoginAttempts,
+			"locked_until":          utils.GetFullDateOrNull(user.LockedUntil),
+		}
+	}
+	return states
+}
+
+// AuditTestState recupera la calificación del test que se guarda en la auditoría.
+func AuditTestState(testID uint) map[string]interface{} {
+	var test TestModule
+	result := db.DB.Select("id", "user_id", "module_id", "qualification", "finished").First(&test, testID)
+	if result.Error != nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"user_id":       test.UserID,
+		"module_id":     test.ModuleID,
+		"qualification": test.Qualification,
+		"finished":      utils.GetFullDateOrNull(test.Finished),
+	}
+}
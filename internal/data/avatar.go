@@ -0,0 +1,103 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PublicHost url pública de la API (APP_HOST), se asigna al iniciar y se usa para completar las urls de los avatares.
+var PublicHost string
+
+// prefijo de las urls de los avatares subidos por los usuarios.
+const uploadedAvatarPrefix = "/api/uploads/avatars/"
+
+// AvatarURL url pública del avatar del usuario. Sin foto se usa el avatar con las iniciales
+// generado por la API, las urls externas (Google) se dejan igual.
+func AvatarURL(userID uint, urlAvatar string) string {
+	urlAvatar = NormalizeAvatarURL(urlAvatar)
+	switch {
+	case urlAvatar == "":
+		return fmt.Sprintf("%s/api/avatars/%d", PublicHost, userID)
+	case strings.HasPrefix(urlAvatar, "http"):
+		return urlAvatar
+	default:
+		return PublicHost + urlAvatar
+	}
+}
+
+// NormalizeAvatarURL prepara la url del avatar para guardarla: las urls de la API se guardan sin el host
+// y los avatares generados (los de la API y los de ui-avatars) no se guardan.
+func NormalizeAvatarURL(urlAvatar string) string {
+	urlAvatar = strings.TrimSpace(urlAvatar)
+	if PublicHost != "" {
+		urlAvatar = strings.TrimPrefix(urlAvatar, PublicHost)
+	}
+	if strings.HasPrefix(urlAvatar, "/api/avatars/") || strings.HasPrefix(urlAvatar, "https://ui-avatars.com/") {
+		return ""
+	}
+	return urlAvatar
+}
+
+// UploadedAvatarURL url del avatar subido en el tamaño indicado, retorna false si el usuario no subió una foto.
+func UploadedAvatarURL(urlAvatar string, size int) (string, bool) {
+	if !strings.HasPrefix(urlAvatar, uploadedAvatarPrefix) {
+		return "", false
+	}
+	suffix := fmt.Sprintf("_%d.jpg", utils.AvatarLargestSize)
+	return strings.TrimSuffix(urlAvatar, suffix) + fmt.Sprintf("_%d.jpg", size), true
+}
+
+// GetAvatarUser recupera los datos del usuario que se usan para generar el avatar.
+func GetAvatarUser(userID uint) (*User, error) {
+	var user User
+	result := db.DB.Select("id", "first_name", "last_name", "url_avatar").First(&user, userID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// SetUserAvatar guarda la foto subida por el usuario, name es el nombre de los archivos sin el tamaño.
+func SetUserAvatar(userID uint, name string) (string, error) {
+	urlAvatar := fmt.Sprintf("%s%d/%s_%d.jpg", uploadedAvatarPrefix, userID, name, utils.AvatarLargestSize)
+	result := db.DB.Model(&User{}).Where("id = ?", userID).Update("url_avatar", urlAvatar)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return urlAvatar, nil
+}
+
+// DeleteUserAvatar elimina la foto del usuario, vuelve a usar el avatar con las iniciales.
+func DeleteUserAvatar(userID uint) error {
+	result := db.DB.Model(&User{}).Where("id = ?", userID).Update("url_avatar", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	return RemoveAvatarFiles(userID, "")
+}
+
+// RemoveAvatarFiles elimina las fotos del usuario, keep es el nombre de la foto que se conserva.
+func RemoveAvatarFiles(userID uint, keep string) error {
+	dir := utils.AvatarUserDir(userID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if keep != "" && strings.HasPrefix(entry.Name(), keep+"_") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
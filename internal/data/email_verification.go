@@ -0,0 +1,95 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerification token de un solo uso para verificar el correo del usuario.
+// Solo se guarda el hash del token.
+type EmailVerification struct {
+	gorm.Model
+	UserID    uint
+	User      User
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+func (EmailVerification) TableName() string {
+	return "email_verifications"
+}
+
+// SaveEmailVerification registra un nuevo token de verificación, los tokens anteriores del usuario se invalidan.
+func SaveEmailVerification(userID uint, tokenHash string, expiresAt time.Time) error {
+	tx := db.DB.Begin()
+
+	result := tx.Model(&EmailVerification{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+
+	result = tx.Create(&EmailVerification{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// VerifyEmail marca el token como utilizado y actualiza el estado del usuario,
+// los estudiantes quedan activos y los profesores y administradores pendientes de aprobación.
+func VerifyEmail(tokenHash string) (*User, error) {
+	var verification EmailVerification
+	result := db.DB.Where("token_hash = ?", tokenHash).First(&verification)
+	if result.Error != nil {
+		return nil, errors.New("el token no es valido")
+	}
+
+	if verification.UsedAt != nil {
+		return nil, errors.New("el token ya fue utilizado")
+	}
+
+	if time.Now().After(verification.ExpiresAt) {
+		return nil, errors.New("el token ha expirado")
+	}
+
+	var user User
+	result = db.DB.First(&user, verification.UserID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	tx := db.DB.Begin()
+
+	result = tx.Model(&EmailVerification{}).Where("id = ?", verification.ID).Update("used_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+
+	if user.Status == PendingVerification {
+		user.Status = initialStatus(user.TypeUser)
+		result = tx.Model(&User{}).Where("id = ?", user.ID).Update("status", user.Status)
+		if result.Error != nil {
+			tx.Rollback()
+			return nil, result.Error
+		}
+	}
+
+	tx.Commit()
+
+	return &user, nil
+}
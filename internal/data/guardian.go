@@ -0,0 +1,281 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GuardianLink vínculo entre un representante y un estudiante.
+// El representante envía la invitación y la acepta el estudiante o uno de sus profesores.
+type GuardianLink struct {
+	gorm.Model
+	GuardianID  uint
+	Guardian    User `gorm:"foreignKey:GuardianID"`
+	StudentID   uint
+	Student     User `gorm:"foreignKey:StudentID"`
+	Status      GuardianLinkStatus
+	DecidedByID *uint
+}
+
+type GuardianLinkStatus string
+
+const (
+	GuardianLinkPending  GuardianLinkStatus = "pending"
+	GuardianLinkAccepted GuardianLinkStatus = "accepted"
+	GuardianLinkRejected GuardianLinkStatus = "rejected"
+)
+
+// ErrNotAllowed el usuario no puede responder la invitación.
+var ErrNotAllowed = errors.New("no autorizado")
+
+func (GuardianLink) TableName() string {
+	return "guardian_links"
+}
+
+func GuardianLinkToAPI(link GuardianLink) types.GuardianLink {
+	linkAPI := types.GuardianLink{
+		ID:        link.ID,
+		CreatedAt: utils.GetFullDate(link.CreatedAt),
+		Status:    string(link.Status),
+	}
+	if link.Guardian.ID != 0 {
+		linkAPI.Guardian = UserToAPI(link.Guardian)
+	}
+	if link.Student.ID != 0 {
+		linkAPI.Student = UserToAPI(link.Student)
+	}
+	return linkAPI
+}
+
+func GuardianLinksToAPI(links []GuardianLink) []types.GuardianLink {
+	linksAPI := make([]types.GuardianLink, 0)
+	for _, link := range links {
+		linksAPI = append(linksAPI, GuardianLinkToAPI(link))
+	}
+	return linksAPI
+}
+
+// RequestGuardianLink registra la invitación del representante para vincularse con el estudiante. Si el correo no
+// es de un estudiante o ya existe la invitación no se registra nada y el link es nil, el representante recibe la
+// misma respuesta para que no pueda averiguar qué correos son de estudiantes.
+func RequestGuardianLink(guardianID uint, studentEmail string) (*GuardianLink, error) {
+	var student User
+	result := db.DB.Where("LOWER(email) = ? AND type_user = ?", strings.ToLower(strings.TrimSpace(studentEmail)), Student).First(&student)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var count int64
+	db.DB.Model(&GuardianLink{}).
+		Where("guardian_id = ? AND student_id = ? AND status IN ?", guardianID, student.ID, []GuardianLinkStatus{GuardianLinkPending, GuardianLinkAccepted}).
+		Count(&count)
+	if count > 0 {
+		return nil, nil
+	}
+
+	link := GuardianLink{
+		GuardianID: guardianID,
+		StudentID:  student.ID,
+		Status:     GuardianLinkPending,
+	}
+	result = db.DB.Create(&link)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	result = db.DB.Preload("Guardian").Preload("Student").First(&link, link.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &link, nil
+}
+
+// GetGuardianLinks recupera los vínculos del representante que el estudiante ya respondió, las invitaciones
+// pendientes no se listan porque revelarían qué correos son de estudiantes.
+func GetGuardianLinks(guardianID uint) ([]GuardianLink, error) {
+	var links []GuardianLink
+	result := db.DB.Preload("Student").Where("guardian_id = ? AND status <> ?", guardianID, GuardianLinkPending).Order("created_at desc").Find(&links)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return links, nil
+}
+
+// GetPendingGuardianInvitations recupera las invitaciones pendientes dirigidas al estudiante,
+// con asTeacher también las de los estudiantes matriculados en las clases del profesor.
+func GetPendingGuardianInvitations(userID uint, asTeacher bool) ([]GuardianLink, error) {
+	var links []GuardianLink
+	tx := db.DB.Preload("Guardian").Preload("Student").Where("status = ?", GuardianLinkPending)
+	if asTeacher {
+		tx = tx.Where("student_id = ? OR student_id IN (?)", userID, teacherStudentsQuery(userID))
+	} else {
+		tx = tx.Where("student_id = ?", userID)
+	}
+
+	result := tx.Order("created_at desc").Find(&links)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return links, nil
+}
+
+// DecideGuardianLink acepta o rechaza una invitación pendiente.
+// Puede decidir el estudiante, un profesor del estudiante si asTeacher o cualquier invitación si manageAll.
+func DecideGuardianLink(linkID, userID uint, accepted, asTeacher, manageAll bool) (*GuardianLink, error) {
+	var link GuardianLink
+	result := db.DB.Preload("Guardian").Preload("Student").First(&link, linkID)
+	if result.Error != nil {
+		return nil, ErrResourceNotFound
+	}
+
+	if link.Status != GuardianLinkPending {
+		return nil, errors.New("la invitación ya fue respondida")
+	}
+
+	allowed := manageAll || link.StudentID == userID
+	if !allowed && asTeacher {
+		allowed = isTeacherOfStudent(userID, link.StudentID)
+	}
+	if !allowed {
+		return nil, ErrNotAllowed
+	}
+
+	link.Status = GuardianLinkRejected
+	if accepted {
+		link.Status = GuardianLinkAccepted
+	}
+	link.DecidedByID = &userID
+
+	result = db.DB.Model(&link).Select("status", "decided_by_id").Updates(&link)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &link, nil
+}
+
+// DeleteGuardianLink elimina el vínculo, lo puede eliminar el representante o el estudiante.
+func DeleteGuardianLink(linkID, userID uint) error {
+	result := db.DB.Where("id = ? AND (guardian_id = ? OR student_id = ?)", linkID, userID, userID).Delete(&GuardianLink{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrResourceNotFound
+	}
+	return nil
+}
+
+// IsGuardianOf revisa si el representante tiene un vínculo aceptado con el estudiante.
+func IsGuardianOf(guardianID, studentID uint) (bool, error) {
+	var student User
+	result := db.DB.Select("id").First(&student, studentID)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+
+	var count int64
+	result = db.DB.Model(&GuardianLink{}).
+		Where("guardian_id = ? AND student_id = ? AND status = ?", guardianID, studentID, GuardianLinkAccepted).
+		Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+// GetGuardianStudents recupera los estudiantes vinculados al representante.
+func GetGuardianStudents(guardianID uint) ([]User, error) {
+	var students []User
+	result := db.DB.
+		Joins("JOIN guardian_links ON guardian_links.student_id = users.id").
+		Where("guardian_links.guardian_id = ? AND guardian_links.status = ? AND guardian_links.deleted_at IS NULL", guardianID, GuardianLinkAccepted).
+		Find(&students)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return students, nil
+}
+
+// GetFinishedTestsForStudent recupera el historial de test finalizados del estudiante, los test en curso no se incluyen.
+func GetFinishedTestsForStudent(studentID uint) ([]TestModule, error) {
+	var tests []TestModule
+	result := db.DB.
+		Preload("Module.CreatedBy").
+		Preload("ModuleVersion").
+		Where("user_id = ? AND finished IS NOT NULL", studentID).
+		Order("finished desc").
+		Find(&tests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return tests, nil
+}
+
+// GetStudentQualifications resume las calificaciones de los test finalizados del estudiante por módulo.
+func GetStudentQualifications(studentID uint) ([]types.StudentQualification, error) {
+	var rows []struct {
+		ModuleID      uint
+		TestsFinished int
+		Best          float32
+		Average       float32
+	}
+	result := db.DB.Model(&TestModule{}).
+		Select("module_id, count(*) as tests_finished, max(qualification) as best, ROUND(avg(qualification)::numeric, 2) as average").
+		Where("user_id = ? AND finished IS NOT NULL", studentID).
+		Group("module_id").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	moduleIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		moduleIDs = append(moduleIDs, row.ModuleID)
+	}
+
+	var modules []Module
+	result = db.DB.Preload("CreatedBy").Where("id IN ?", moduleIDs).Find(&modules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	modulesByID := make(map[uint]Module)
+	for _, module := range modules {
+		modulesByID[module.ID] = module
+	}
+
+	qualifications := make([]types.StudentQualification, 0, len(rows))
+	for _, row := range rows {
+		qualifications = append(qualifications, types.StudentQualification{
+			Module:               ModuleToApi(modulesByID[row.ModuleID]),
+			TestsFinished:        row.TestsFinished,
+			BestQualification:    row.Best,
+			AverageQualification: row.Average,
+		})
+	}
+	return qualifications, nil
+}
+
+// isTeacherOfStudent revisa si el estudiante está matriculado en una clase del profesor.
+func isTeacherOfStudent(teacherID, studentID uint) bool {
+	var count int64
+	db.DB.Model(&Matricula{}).
+		Where("user_id = ? AND class_id IN (?)", studentID, teacherClassesQuery(teacherID)).
+		Count(&count)
+	return count > 0
+}
+
+func teacherClassesQuery(teacherID uint) *gorm.DB {
+	return db.DB.Model(&Class{}).Select("id").Where("teacher_id = ? OR create_by_id = ?", teacherID, teacherID)
+}
+
+func teacherStudentsQuery(teacherID uint) *gorm.DB {
+	return db.DB.Model(&Matricula{}).Select("user_id").Where("class_id IN (?)", teacherClassesQuery(teacherID))
+}
@@ -0,0 +1,472 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuestionMaxScore puntaje de una respuesta correcta, la calificación de un test es la suma de sus respuestas.
+const QuestionMaxScore = 10
+
+var (
+	ErrUnlockCycle          = errors.New("las reglas de desbloqueo forman un ciclo, un módulo terminaría dependiendo de sí mismo")
+	ErrRepeatedPathModule   = errors.New("un módulo no se puede repetir en la ruta")
+	ErrSelfPrerequisite     = errors.New("un módulo no puede ser prerrequisito de sí mismo")
+	ErrRepeatedPrerequisite = errors.New("el módulo requerido está repetido")
+)
+
+// LearningPath ruta de aprendizaje con módulos ordenados, cada módulo se desbloquea al aprobar el anterior.
+type LearningPath struct {
+	gorm.Model
+	CreatedByID uint
+	CreatedBy   User `gorm:"foreignKey:CreatedByID"`
+	Title       string
+	Description string
+	// MinQualification porcentaje (0 a 100) que se debe obtener en un módulo para desbloquear el siguiente.
+	MinQualification float32
+	Modules          []LearningPathModule
+}
+
+// LearningPathModule posición del módulo en la ruta, se reemplazan todos al actualizar la ruta.
+type LearningPathModule struct {
+	ID             uint `gorm:"primarykey"`
+	LearningPathID uint `gorm:"uniqueIndex:idx_learning_path_module;uniqueIndex:idx_learning_path_position"`
+	ModuleID       uint `gorm:"uniqueIndex:idx_learning_path_module;index"`
+	Module         Module
+	Position       int `gorm:"uniqueIndex:idx_learning_path_position"`
+}
+
+// ModulePrerequisite módulo que el estudiante debe aprobar antes de realizar los test de ModuleID.
+type ModulePrerequisite struct {
+	ID               uint `gorm:"primarykey"`
+	CreatedAt        time.Time
+	ModuleID         uint `gorm:"uniqueIndex:idx_module_prerequisite"`
+	RequiredModuleID uint `gorm:"uniqueIndex:idx_module_prerequisite"`
+	RequiredModule   Module
+	MinQualification float32
+}
+
+// unlockRule condición para desbloquear un módulo, LearningPathID es 0 si es un prerrequisito del módulo.
+type unlockRule struct {
+	ModuleID         uint
+	RequiredModuleID uint
+	LearningPathID   uint
+	MinQualification float32
+}
+
+// cada módulo de una ruta depende del módulo en la posición anterior.
+const learningPathRulesSQL = `SELECT lpm.module_id, prev.module_id AS required_module_id, lp.id AS learning_path_id, lp.min_qualification
+FROM learning_path_modules lpm
+JOIN learning_path_modules prev ON prev.learning_path_id = lpm.learning_path_id AND prev.position = lpm.position - 1
+JOIN learning_paths lp ON lp.id = lpm.learning_path_id AND lp.deleted_at IS NULL`
+
+// LearningPathToAPI convierte la ruta para la API REST, con draft se muestran los borradores de los módulos y si no
+// solo los módulos publicados. locks son las condiciones pendientes del usuario que consulta.
+func LearningPathToAPI(path LearningPath, draft bool, locks map[uint][]types.ModuleUnlockCondition) types.LearningPath {
+	pathAPI := types.LearningPath{
+		ID:               path.ID,
+		CreatedAt:        utils.GetDate(path.CreatedAt),
+		CreatedBy:        *UserToAPI(path.CreatedBy),
+		Title:            path.Title,
+		Description:      path.Description,
+		MinQualification: path.MinQualification,
+		Modules:          make([]types.LearningPathModule, 0, len(path.Modules)),
+	}
+	for _, pathModule := range path.Modules {
+		// los módulos eliminados no se muestran.
+		if pathModule.Module.ID == 0 {
+			continue
+		}
+		module := ModuleToApi(pathModule.Module)
+		if !draft {
+			if pathModule.Module.PublishedVersionID == nil {
+				continue
+			}
+			module = PublishedModuleToApi(pathModule.Module)
+		}
+		conditions := locks[pathModule.ModuleID]
+		if conditions == nil {
+			conditions = make([]types.ModuleUnlockCondition, 0)
+		}
+		pathAPI.Modules = append(pathAPI.Modules, types.LearningPathModule{
+			Position:        pathModule.Position,
+			Module:          module,
+			Locked:          len(conditions) > 0,
+			UnmetConditions: conditions,
+		})
+	}
+	return pathAPI
+}
+
+// learningPathModules crea las posiciones de los módulos en el orden indicado.
+func learningPathModules(pathID uint, moduleIDs []uint) ([]LearningPathModule, []unlockRule, error) {
+	modules := make([]LearningPathModule, 0, len(moduleIDs))
+	rules := make([]unlockRule, 0, len(moduleIDs))
+	seen := make(map[uint]bool)
+	for i, moduleID := range moduleIDs {
+		if seen[moduleID] {
+			return nil, nil, ErrRepeatedPathModule
+		}
+		seen[moduleID] = true
+		modules = append(modules, LearningPathModule{LearningPathID: pathID, ModuleID: moduleID, Position: i + 1})
+		if i > 0 {
+			rules = append(rules, unlockRule{ModuleID: moduleID, RequiredModuleID: moduleIDs[i-1]})
+		}
+	}
+	return modules, rules, nil
+}
+
+// CreateLearningPath crea la ruta con los módulos en el orden indicado.
+func CreateLearningPath(ownerID uint, req types.ReqLearningPath) (*LearningPath, error) {
+	path := LearningPath{
+		CreatedByID:      ownerID,
+		Title:            req.Title,
+		Description:      req.Description,
+		MinQualification: req.MinQualification,
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		modules, rules, err := learningPathModules(0, req.ModuleIDs)
+		if err != nil {
+			return err
+		}
+		if err := checkUnlockCycles(tx, 0, 0, rules); err != nil {
+			return err
+		}
+		if result := tx.Create(&path); result.Error != nil {
+			return result.Error
+		}
+		for i := range modules {
+			modules[i].LearningPathID = path.ID
+		}
+		return tx.Create(&modules).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetLearningPath(path.ID)
+}
+
+// UpdateLearningPath actualiza la ruta y reemplaza el orden de sus módulos.
+func UpdateLearningPath(pathID uint, req types.ReqLearningPath) (*LearningPath, error) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		modules, rules, err := learningPathModules(pathID, req.ModuleIDs)
+		if err != nil {
+			return err
+		}
+		if err := checkUnlockCycles(tx, 0, pathID, rules); err != nil {
+			return err
+		}
+		result := tx.Model(&LearningPath{}).Where("id = ?", pathID).Updates(map[string]interface{}{
+			"title":             req.Title,
+			"description":       req.Description,
+			"min_qualification": req.MinQualification,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("learning_path_id = ?", pathID).Delete(&LearningPathModule{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Create(&modules).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetLearningPath(pathID)
+}
+
+// DeleteLearningPath elimina la ruta, sus módulos quedan desbloqueados salvo por sus prerrequisitos.
+func DeleteLearningPath(pathID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("learning_path_id = ?", pathID).Delete(&LearningPathModule{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&LearningPath{}, pathID).Error
+	})
+}
+
+func preloadLearningPath(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("CreatedBy").
+		Preload("Modules", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") }).
+		Preload("Modules.Module.CreatedBy").
+		Preload("Modules.Module.PublishedVersion")
+}
+
+// GetLearningPath recupera la ruta con sus módulos ordenados.
+func GetLearningPath(pathID uint) (*LearningPath, error) {
+	var path LearningPath
+	result := preloadLearningPath(db.DB).First(&path, pathID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &path, nil
+}
+
+// GetLearningPaths recupera las rutas, con ownerID solo las rutas del profesor.
+func GetLearningPaths(ownerID uint) ([]LearningPath, error) {
+	paths := make([]LearningPath, 0)
+	query := preloadLearningPath(db.DB).Order("created_at DESC")
+	if ownerID != 0 {
+		query = query.Where("created_by_id = ?", ownerID)
+	}
+	result := query.Find(&paths)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return paths, nil
+}
+
+// GetModulePrerequisites recupera los prerrequisitos del módulo.
+func GetModulePrerequisites(moduleID uint) ([]types.ModulePrerequisite, error) {
+	var prerequisites []ModulePrerequisite
+	result := db.DB.Preload("RequiredModule.PublishedVersion").Where("module_id = ?", moduleID).Order("id").Find(&prerequisites)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	prerequisitesAPI := make([]types.ModulePrerequisite, 0, len(prerequisites))
+	for _, prerequisite := range prerequisites {
+		if prerequisite.RequiredModule.ID == 0 {
+			continue
+		}
+		prerequisitesAPI = append(prerequisitesAPI, types.ModulePrerequisite{
+			RequiredModuleID:    prerequisite.RequiredModuleID,
+			RequiredModuleTitle: PublishedModuleToApi(prerequisite.RequiredModule).Title,
+			MinQualification:    prerequisite.MinQualification,
+		})
+	}
+	return prerequisitesAPI, nil
+}
+
+// SetModulePrerequisites reemplaza los prerrequisitos del módulo, los módulos requeridos deben estar publicados.
+func SetModulePrerequisites(moduleID uint, reqs []types.ReqModulePrerequisite) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		prerequisites := make([]ModulePrerequisite, 0, len(reqs))
+		rules := make([]unlockRule, 0, len(reqs))
+		seen := make(map[uint]bool)
+		for _, req := range reqs {
+			if req.RequiredModuleID == moduleID {
+				return ErrSelfPrerequisite
+			}
+			if seen[req.RequiredModuleID] {
+				return ErrRepeatedPrerequisite
+			}
+			seen[req.RequiredModuleID] = true
+
+			var required Module
+			result := tx.Select("id", "published_version_id").First(&required, req.RequiredModuleID)
+			if result.Error != nil {
+				return notFound(result.Error)
+			}
+			if required.PublishedVersionID == nil {
+				return fmt.Errorf("%w (módulo requerido %d)", ErrModuleNotPublished, required.ID)
+			}
+
+			prerequisites = append(prerequisites, ModulePrerequisite{
+				ModuleID:         moduleID,
+				RequiredModuleID: req.RequiredModuleID,
+				MinQualification: req.MinQualification,
+			})
+			rules = append(rules, unlockRule{ModuleID: moduleID, RequiredModuleID: req.RequiredModuleID})
+		}
+
+		if err := checkUnlockCycles(tx, moduleID, 0, rules); err != nil {
+			return err
+		}
+		if result := tx.Where("module_id = ?", moduleID).Delete(&ModulePrerequisite{}); result.Error != nil {
+			return result.Error
+		}
+		if len(prerequisites) == 0 {
+			return nil
+		}
+		return tx.Create(&prerequisites).Error
+	})
+}
+
+// checkUnlockCycles revisa que las reglas nuevas junto con las existentes no formen un ciclo. Se omiten los
+// prerrequisitos de skipModuleID y la ruta skipPathID porque se van a reemplazar.
+func checkUnlockCycles(tx *gorm.DB, skipModuleID, skipPathID uint, rules []unlockRule) error {
+	var existing []unlockRule
+	result := tx.Model(&ModulePrerequisite{}).Select("module_id", "required_module_id").Where("module_id <> ?", skipModuleID).Scan(&existing)
+	if result.Error != nil {
+		return result.Error
+	}
+	var pathRules []unlockRule
+	result = tx.Raw(learningPathRulesSQL+" WHERE lpm.learning_path_id <> ?", skipPathID).Scan(&pathRules)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	graph := make(map[uint][]uint)
+	for _, list := range [][]unlockRule{existing, pathRules, rules} {
+		for _, rule := range list {
+			graph[rule.ModuleID] = append(graph[rule.ModuleID], rule.RequiredModuleID)
+		}
+	}
+
+	// recorrido en profundidad, un módulo en visita que se vuelve a alcanzar indica un ciclo.
+	const visiting, visited = 1, 2
+	state := make(map[uint]int)
+	var visit func(moduleID uint) bool
+	visit = func(moduleID uint) bool {
+		switch state[moduleID] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+		state[moduleID] = visiting
+		for _, required := range graph[moduleID] {
+			if visit(required) {
+				return true
+			}
+		}
+		state[moduleID] = visited
+		return false
+	}
+	for moduleID := range graph {
+		if visit(moduleID) {
+			return ErrUnlockCycle
+		}
+	}
+	return nil
+}
+
+// bestQualifications mejor porcentaje de los test terminados por el usuario en cada módulo.
+func bestQualifications(userID uint, moduleIDs []uint) (map[uint]float32, error) {
+	var rows []struct {
+		ModuleID uint
+		Best     float32
+	}
+	result := db.DB.Raw(`SELECT t.module_id, ROUND(MAX(t.qualification * 100.0 / (a.total * ?))::numeric, 2) AS best
+FROM test_modules t
+JOIN (SELECT test_module_id, COUNT(*) AS total FROM answer_users WHERE deleted_at IS NULL GROUP BY test_module_id) a ON a.test_module_id = t.id
+WHERE t.user_id = ? AND t.finished IS NOT NULL AND t.deleted_at IS NULL AND t.module_id IN ?
+GROUP BY t.module_id`, QuestionMaxScore, userID, moduleIDs).Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	best := make(map[uint]float32, len(rows))
+	for _, row := range rows {
+		best[row.ModuleID] = row.Best
+	}
+	return best, nil
+}
+
+// ModuleLocks condiciones que el usuario aún no cumple en cada módulo, los módulos sin condiciones pendientes están desbloqueados.
+func ModuleLocks(userID uint, moduleIDs []uint) (map[uint][]types.ModuleUnlockCondition, error) {
+	locks := make(map[uint][]types.ModuleUnlockCondition)
+	if len(moduleIDs) == 0 {
+		return locks, nil
+	}
+
+	var rules []unlockRule
+	result := db.DB.Model(&ModulePrerequisite{}).Select("module_id", "required_module_id", "min_qualification").Where("module_id IN ?", moduleIDs).Scan(&rules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	var pathRules []unlockRule
+	result = db.DB.Raw(learningPathRulesSQL+" WHERE lpm.module_id IN ?", moduleIDs).Scan(&pathRules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	rules = append(rules, pathRules...)
+	if len(rules) == 0 {
+		return locks, nil
+	}
+
+	requiredIDs := make([]uint, 0, len(rules))
+	for _, rule := range rules {
+		requiredIDs = append(requiredIDs, rule.RequiredModuleID)
+	}
+
+	// los módulos requeridos que se eliminaron ya no bloquean.
+	var required []Module
+	result = db.DB.Preload("PublishedVersion").Where("id IN ?", requiredIDs).Find(&required)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	titles := make(map[uint]string, len(required))
+	for _, module := range required {
+		titles[module.ID] = PublishedModuleToApi(module).Title
+	}
+
+	best, err := bestQualifications(userID, requiredIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		title, ok := titles[rule.RequiredModuleID]
+		if !ok {
+			continue
+		}
+		qualification, finished := best[rule.RequiredModuleID]
+		if finished && qualification >= rule.MinQualification {
+			continue
+		}
+
+		condition := types.ModuleUnlockCondition{
+			RequiredModuleID:    rule.RequiredModuleID,
+			RequiredModuleTitle: title,
+			MinQualification:    rule.MinQualification,
+		}
+		if finished {
+			condition.BestQualification = &qualification
+		}
+		if rule.LearningPathID != 0 {
+			pathID := rule.LearningPathID
+			condition.LearningPathID = &pathID
+		}
+		switch {
+		case rule.MinQualification == 0:
+			condition.Message = fmt.Sprintf("Termina un test del módulo %q", title)
+		case finished:
+			condition.Message = fmt.Sprintf("Aprueba el módulo %q con al menos %.0f%%, tu mejor calificación es %.0f%%", title, rule.MinQualification, qualification)
+		default:
+			condition.Message = fmt.Sprintf("Aprueba el módulo %q con al menos %.0f%%", title, rule.MinQualification)
+		}
+		locks[rule.ModuleID] = append(locks[rule.ModuleID], condition)
+	}
+	return locks, nil
+}
+
+// ModuleUnmetConditions condiciones pendientes del usuario para realizar los test del módulo.
+func ModuleUnmetConditions(userID, moduleID uint) ([]types.ModuleUnlockCondition, error) {
+	locks, err := ModuleLocks(userID, []uint{moduleID})
+	if err != nil {
+		return nil, err
+	}
+	return locks[moduleID], nil
+}
+
+// ApplyModuleLocks agrega a los módulos el estado de bloqueo del usuario.
+func ApplyModuleLocks(userID uint, modules []types.ModuleUser) error {
+	moduleIDs := make([]uint, 0, len(modules))
+	for _, module := range modules {
+		moduleIDs = append(moduleIDs, module.ID)
+	}
+
+	locks, err := ModuleLocks(userID, moduleIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range modules {
+		conditions := locks[modules[i].ID]
+		if conditions == nil {
+			conditions = make([]types.ModuleUnlockCondition, 0)
+		}
+		modules[i].Locked = len(conditions) > 0
+		modules[i].UnmetConditions = conditions
+	}
+	return nil
+}
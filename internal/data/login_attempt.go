@@ -0,0 +1,137 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAccountLocked la cuenta está bloqueada temporalmente.
+	ErrAccountLocked = errors.New("La cuenta está bloqueada temporalmente por demasiados intentos fallidos")
+	// ErrAccountLockedNow la cuenta se bloqueó con el intento actual, se debe notificar al usuario.
+	ErrAccountLockedNow = errors.New("La cuenta fue bloqueada temporalmente por demasiados intentos fallidos")
+	// ErrLoginTooSoon el usuario debe esperar antes de volver a intentar.
+	ErrLoginTooSoon = errors.New("Espera unos segundos antes de volver a intentar")
+)
+
+// LoginPolicy configuración de la protección contra ataques de fuerza bruta.
+type LoginPolicy struct {
+	MaxAttempts int
+	Lockout     time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// checkLoginAllowed revisa si el usuario está bloqueado o si debe esperar antes de volver a intentar.
+func checkLoginAllowed(user *User, policy LoginPolicy) error {
+	now := time.Now()
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return ErrAccountLocked
+	}
+
+	if user.FailedLoginAttempts > 0 && user.LastFailedLoginAt != nil {
+		if now.Before(user.LastFailedLoginAt.Add(loginDelay(user.FailedLoginAttempts, policy))) {
+			return ErrLoginTooSoon
+		}
+	}
+
+	return nil
+}
+
+// loginDelay calcula la espera progresiva según los intentos fallidos.
+func loginDelay(attempts int, policy LoginPolicy) time.Duration {
+	delay := time.Duration(float64(policy.BaseDelay) * math.Pow(2, float64(attempts-1)))
+	if delay > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+	return delay
+}
+
+// registerFailedLogin incrementa los intentos fallidos y bloquea la cuenta al llegar al límite. El contador se lee
+// con la fila bloqueada (SELECT ... FOR UPDATE) para que los intentos en paralelo no se pierdan.
+func registerFailedLogin(user *User, policy LoginPolicy) error {
+	locked := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var current User
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_login_attempts", "locked_until").
+			First(&current, user.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		now := time.Now()
+		attempts := current.FailedLoginAttempts
+		lockedUntil := current.LockedUntil
+
+		// un bloqueo vencido reinicia el contador.
+		if lockedUntil != nil && now.After(*lockedUntil) {
+			attempts = 0
+			lockedUntil = nil
+		}
+
+		attempts++
+		if attempts >= policy.MaxAttempts {
+			until := now.Add(policy.Lockout)
+			lockedUntil = &until
+			locked = true
+		}
+
+		result = tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"failed_login_attempts": attempts,
+			"last_failed_login_at":  now,
+			"locked_until":          lockedUntil,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		user.FailedLoginAttempts = attempts
+		user.LastFailedLoginAt = &now
+		user.LockedUntil = lockedUntil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		return ErrAccountLockedNow
+	}
+	return nil
+}
+
+// resetFailedLogins reinicia los intentos fallidos después de un inicio de sesión correcto.
+func resetFailedLogins(user *User) error {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return nil
+	}
+
+	result := db.DB.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	})
+	return result.Error
+}
+
+// UnlockUser desbloquea la cuenta de un usuario bloqueada por intentos fallidos.
+func UnlockUser(userID uint) error {
+	var user User
+	result := db.DB.Select("id").First(&user, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	result = db.DB.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	})
+	return result.Error
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
@@ -27,6 +28,14 @@ type Module struct {
 	PointsToEarn     int
 	Index            int
 	IsPublic         bool
+	// Tags etiquetas del módulo, no forman parte de las versiones publicadas.
+	Tags pq.StringArray `gorm:"type:text[]"`
+	// PublishedVersionID versión que ven los estudiantes, los campos del módulo son el borrador.
+	PublishedVersionID *uint
+	PublishedVersion   *ModuleVersion `gorm:"foreignKey:PublishedVersionID"`
+	// SourceModuleID módulo del que se clonó, se guarda para dar crédito al autor original.
+	SourceModuleID *uint
+	SourceModule   *Module `gorm:"foreignKey:SourceModuleID"`
 }
 
 type Difficulty string
@@ -56,6 +65,14 @@ func ModuleToApi(module Module) types.Module {
 	if len(module.Code) > 8 {
 		module.Code = module.Code[:8]
 	}
+	publishedVersion := 0
+	if module.PublishedVersion != nil {
+		publishedVersion = module.PublishedVersion.Version
+	}
+	tags := []string(module.Tags)
+	if tags == nil {
+		tags = make([]string, 0)
+	}
 	return types.Module{
 		ID:        module.ID,
 		CreatedAt: utils.GetDate(module.CreatedAt),
@@ -65,7 +82,7 @@ func ModuleToApi(module Module) types.Module {
 			FirstName:            module.CreatedBy.FirstName,
 			LastName:             module.CreatedBy.LastName,
 			Email:                module.CreatedBy.Email,
-			URLAvatar:            module.CreatedBy.URLAvatar,
+			URLAvatar:            AvatarURL(module.CreatedBy.ID, module.CreatedBy.URLAvatar),
 			Status:               string(module.CreatedBy.Status),
 			TypeUser:             string(module.CreatedBy.TypeUser),
 			PerfilUpdateRequired: module.CreatedBy.PerfilUpdateRequired,
@@ -79,6 +96,9 @@ func ModuleToApi(module Module) types.Module {
 		PointsToEarn:     module.PointsToEarn,
 		Index:            module.Index,
 		IsPublic:         module.IsPublic,
+		Tags:             tags,
+		PublishedVersion: publishedVersion,
+		SourceModuleID:   module.SourceModuleID,
 	}
 }
 
@@ -108,6 +128,7 @@ func RegisterModuleForTeacher(module *types.Module, userid uint) (types.Module,
 		PointsToEarn:     module.PointsToEarn,
 		Index:            module.Index,
 		IsPublic:         module.IsPublic,
+		Tags:             pq.StringArray(types.NormalizeTags(module.Tags)),
 	}
 
 	// guardamos el módulo en la db
@@ -126,6 +147,88 @@ func RegisterModuleForTeacher(module *types.Module, userid uint) (types.Module,
 
 }
 
+// CloneModule copia el módulo con sus preguntas y respuestas correctas para el usuario, con un código nuevo.
+// Si fromDraft es verdadero se copia el borrador, si no se copia la versión publicada.
+func CloneModule(sourceID uint, fromDraft bool, ownerID uint, req types.ReqCloneModule) (*Module, error) {
+	var clone Module
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var source Module
+		result := tx.Preload("PublishedVersion").First(&source, sourceID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var questions []Question
+		query := tx.Preload("CorrectAnswer").Order("created_at")
+		if fromDraft {
+			query = query.Where("module_id = ?", source.ID)
+		} else {
+			if source.PublishedVersion == nil {
+				return ErrModuleNotPublished
+			}
+			query = query.Where("module_version_id = ?", source.PublishedVersion.ID)
+
+			// se copian los datos que ven los estudiantes, no el borrador.
+			source.Title = source.PublishedVersion.Title
+			source.ShortDescription = source.PublishedVersion.ShortDescription
+			source.TextRoot = source.PublishedVersion.TextRoot
+			source.ImgBackURL = source.PublishedVersion.ImgBackURL
+			source.Difficulty = source.PublishedVersion.Difficulty
+			source.PointsToEarn = source.PublishedVersion.PointsToEarn
+		}
+		if result := query.Find(&questions); result.Error != nil {
+			return result.Error
+		}
+
+		clone = Module{
+			CreatedByID:      ownerID,
+			Code:             uuid.NewString()[0:8],
+			Title:            req.Title,
+			ShortDescription: source.ShortDescription,
+			TextRoot:         source.TextRoot,
+			ImgBackURL:       source.ImgBackURL,
+			Difficulty:       source.Difficulty,
+			PointsToEarn:     source.PointsToEarn,
+			Index:            source.Index,
+			IsPublic:         source.IsPublic && !req.Private,
+			Tags:             source.Tags,
+		}
+		if clone.Title == "" {
+			clone.Title = fmt.Sprintf("%s (copia)", source.Title)
+		}
+		if req.RecordSource {
+			clone.SourceModuleID = &source.ID
+		}
+		if result := tx.Create(&clone); result.Error != nil {
+			return result.Error
+		}
+
+		for _, question := range questions {
+			draft := copyQuestion(question)
+			draft.ModuleID = &clone.ID
+			if result := tx.Create(&draft); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		// sin preguntas no hay nada que publicar, la copia queda como borrador.
+		if req.Draft || len(questions) == 0 {
+			return nil
+		}
+		_, err := createModuleVersion(tx, clone, 1, ownerID, fmt.Sprintf("Copia del módulo %s", source.Title), questions)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.DB.Preload("CreatedBy").Preload("PublishedVersion").First(&clone, clone.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &clone, nil
+}
+
 func UpdateModule(module *types.Module) (*Module, error) {
 	data := map[string]interface{}{
 		"title":             module.Title,
@@ -137,6 +240,10 @@ func UpdateModule(module *types.Module) (*Module, error) {
 		"index":             module.Index,
 		"is_public":         module.IsPublic,
 	}
+	// sin el campo tags se conservan las etiquetas actuales.
+	if module.Tags != nil {
+		data["tags"] = pq.StringArray(types.NormalizeTags(module.Tags))
+	}
 
 	result := db.DB.Model(&Module{}).Where("id = ?", module.ID).Updates(data)
 	if result.Error != nil {
@@ -144,7 +251,7 @@ func UpdateModule(module *types.Module) (*Module, error) {
 	}
 
 	var moduleData Module
-	result = db.DB.Preload("CreatedBy").First(&moduleData, module.ID)
+	result = db.DB.Preload("CreatedBy").Preload("PublishedVersion").First(&moduleData, module.ID)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -152,25 +259,25 @@ func UpdateModule(module *types.Module) (*Module, error) {
 	return &moduleData, nil
 }
 
-// GetModulesForTeacher Se encarga de traer los módulos creados por el profesor.
-func GetModulesForTeacher(paginated *types.Paginated, userid uint) ([]Module, *types.PagintaedDetails, error) {
+// GetModulesForTeacher Se encarga de traer los módulos creados por el profesor, con las etiquetas de la búsqueda como facetas.
+func GetModulesForTeacher(paginated *types.Paginated, filter *types.ModuleFilter, userid uint) ([]Module, *types.PagintaedDetails, []types.TagFacet, error) {
 
 	var modules []Module
 	var paginatedDetails types.PagintaedDetails
 
 	// Calcular los detalles de la paginación.
 	db.DB.
-		Table("modules").
-		Where("title LIKE ?", "%"+paginated.Query+"%").
-		Where("created_by_id = ?", userid).Count(&paginatedDetails.TotalItems)
+		Model(&Module{}).
+		Scopes(teacherModules(userid), moduleSearch(paginated, filter)).
+		Count(&paginatedDetails.TotalItems)
 	paginatedDetails.Page = paginated.Page
 	paginatedDetails.TotalPage = int64(math.Ceil(float64(paginatedDetails.TotalItems) / float64(paginated.Limit)))
 
 	result := db.DB.
 		Preload("CreatedBy").
-		Where("title LIKE ?", "%"+paginated.Query+"%").
-		Where("created_by_id = ?", userid).
-		Order(fmt.Sprintf("%s %s", paginated.Sort, paginated.Order)).
+		Preload("PublishedVersion").
+		Scopes(teacherModules(userid), moduleSearch(paginated, filter)).
+		Scopes(moduleOrder(paginated)).
 		Limit(paginated.Limit).
 		Offset((paginated.Page - 1) * paginated.Limit).
 		Find(&modules)
@@ -182,12 +289,17 @@ func GetModulesForTeacher(paginated *types.Paginated, userid uint) ([]Module, *t
 		var pgErr *pgconn.PgError
 		if errors.As(result.Error, &pgErr) {
 			if pgErr.Code == "42703" {
-				return nil, nil, fmt.Errorf("columna inexistente: %s", paginated.Sort)
+				return nil, nil, nil, fmt.Errorf("columna inexistente: %s", paginated.Sort)
 			}
 		}
-		return nil, nil, result.Error
+		return nil, nil, nil, result.Error
 	}
-	return modules, &paginatedDetails, nil
+
+	facets, err := moduleTagFacets(teacherModules(userid), paginated, filter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return modules, &paginatedDetails, facets, nil
 
 }
 
@@ -205,6 +317,7 @@ func GetModuleForStudent(paginated *types.Paginated, userid uint) ([]Module, *ty
 
 	result := db.DB.Model(&Module{}).
 		Preload("CreatedBy").
+		Preload("PublishedVersion").
 		Joins("JOIN subscriptions ON subscriptions.module_id = modules.id").
 		Where("subscriptions.user_id = ?", userid).
 		Order(fmt.Sprintf("%s %s", paginated.Sort, paginated.Order)).
@@ -225,13 +338,19 @@ func GetModuleForStudent(paginated *types.Paginated, userid uint) ([]Module, *ty
 	return modules, &paginatedDetails, nil
 }
 
-// GetModule Se encarga de traer todos los módulos, sin importar quien los haya creado.
-func GetModule(paginated *types.Paginated) (modules []Module, details types.PagintaedDetails, err error) {
+// GetModule Se encarga de traer todos los módulos, sin importar quien los haya creado. Sin drafts solo se
+// recuperan los módulos públicos con una versión publicada.
+func GetModule(paginated *types.Paginated, filter *types.ModuleFilter, drafts bool) (modules []Module, details types.PagintaedDetails, facets []types.TagFacet, err error) {
+
+	scope := publishedModules
+	if drafts {
+		scope = allModules
+	}
 
 	// cantidad total de módulos.
 	db.DB.
-		Table("modules").
-		Where("title LIKE ?", "%"+paginated.Query+"%").
+		Model(&Module{}).
+		Scopes(scope, moduleSearch(paginated, filter)).
 		Count(&details.TotalItems)
 
 	// pagina actual y total de paginas.
@@ -241,8 +360,9 @@ func GetModule(paginated *types.Paginated) (modules []Module, details types.Pagi
 	// Recuperamos los módulos
 	result := db.DB.
 		Preload("CreatedBy").
-		Where("title LIKE ?", "%"+paginated.Query+"%").
-		Order(fmt.Sprintf("%s %s", paginated.Sort, paginated.Order)).
+		Preload("PublishedVersion").
+		Scopes(scope, moduleSearch(paginated, filter)).
+		Scopes(moduleOrder(paginated)).
 		Limit(paginated.Limit).
 		Offset((paginated.Page - 1) * paginated.Limit).
 		Find(&modules)
@@ -254,13 +374,17 @@ func GetModule(paginated *types.Paginated) (modules []Module, details types.Pagi
 		var pgErr *pgconn.PgError
 		if errors.As(result.Error, &pgErr) {
 			if pgErr.Code == "42703" {
-				return nil, details, fmt.Errorf("columna inexistente: %s", paginated.Sort)
+				return nil, details, nil, fmt.Errorf("columna inexistente: %s", paginated.Sort)
 			}
 		}
-		return nil, details, result.Error
+		return nil, details, nil, result.Error
 	}
 
-	return modules, details, nil
+	facets, err = moduleTagFacets(scope, paginated, filter)
+	if err != nil {
+		return nil, details, nil, err
+	}
+	return modules, details, facets, nil
 
 }
 
@@ -284,18 +408,18 @@ func ModuleUserSubToApi(modules []ModuleUserSub) []types.ModuleUser {
 
 func ModuleUserToApi(module ModuleUserSub) types.ModuleUser {
 	return types.ModuleUser{
-		Module:       ModuleToApi(module.Module),
+		Module:       PublishedModuleToApi(module.Module),
 		IsSubscribed: module.IsSubscribed,
 	}
 }
 
 // GetModuleWithUserSubscription Retorna todos los módulos y además tiene un campo para saber si el usuario está suscrito a ese módulo
-func GetModuleWithUserSubscription(paginated *types.Paginated, userid uint) (moduleUser []ModuleUserSub, details types.PagintaedDetails, err error) {
+func GetModuleWithUserSubscription(paginated *types.Paginated, filter *types.ModuleFilter, userid uint) (moduleUser []ModuleUserSub, details types.PagintaedDetails, facets []types.TagFacet, err error) {
 
-	// cantidad total de módulos.
+	// cantidad total de módulos, los estudiantes solo ven los módulos publicados.
 	db.DB.
-		Table("modules").
-		Where("title LIKE ? AND is_public = true", "%"+paginated.Query+"%").
+		Model(&Module{}).
+		Scopes(publishedModules, moduleSearch(paginated, filter)).
 		Count(&details.TotalItems)
 
 	// pagina actual y total de paginas.
@@ -306,11 +430,12 @@ func GetModuleWithUserSubscription(paginated *types.Paginated, userid uint) (mod
 	result := db.DB.
 		Table("modules").
 		Preload("CreatedBy").
+		Preload("PublishedVersion").
 		Select("modules.* ", "subscriptions.user_id IS NOT NULL as is_subscribed").
 		Joins("LEFT JOIN subscriptions ON subscriptions.module_id = modules.id").
-		Where("title LIKE ? AND is_public = true", "%"+paginated.Query+"%").
+		Scopes(publishedModules, moduleSearch(paginated, filter)).
 		Where("subscriptions.user_id = ? or subscriptions.user_id is null ", userid). // where s.user_id = 3 or s.user_id is null
-		Order(fmt.Sprintf("%s %s", paginated.Sort, paginated.Order)).
+		Scopes(moduleOrder(paginated)).
 		Limit(paginated.Limit).
 		Offset((paginated.Page - 1) * paginated.Limit).
 		Find(&moduleUser)
@@ -322,13 +447,17 @@ func GetModuleWithUserSubscription(paginated *types.Paginated, userid uint) (mod
 		var pgErr *pgconn.PgError
 		if errors.As(result.Error, &pgErr) {
 			if pgErr.Code == "42703" {
-				return nil, details, fmt.Errorf("columna inexistente: %s", paginated.Sort)
+				return nil, details, nil, fmt.Errorf("columna inexistente: %s", paginated.Sort)
 			}
 		}
-		return nil, details, result.Error
+		return nil, details, nil, result.Error
 	}
 
-	return moduleUser, details, nil
+	facets, err = moduleTagFacets(publishedModules, paginated, filter)
+	if err != nil {
+		return nil, details, nil, err
+	}
+	return moduleUser, details, facets, nil
 
 }
 
@@ -348,7 +477,7 @@ func GetStudentsByModule(moduleID uint) ([]User, error) {
 
 func ModuleByID(id uint) (*Module, error) {
 	var module Module
-	result := db.DB.Preload("CreatedBy").First(&module, id)
+	result := db.DB.Preload("CreatedBy").Preload("PublishedVersion").First(&module, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -370,9 +499,7 @@ func StudentPointsList(start, end time.Time, limit int) ([]types.PointsUserForMo
 	}
 
 	for i := range pointsList {
-		if pointsList[i].URLAvatar == "" {
-			pointsList[i].URLAvatar = fmt.Sprintf("https://ui-avatars.com/api/?name=%s&background=5952A2&color=fff&size=128", pointsList[i].FirstName+pointsList[i].LastName)
-		}
+		pointsList[i].URLAvatar = AvatarURL(pointsList[i].UserID, pointsList[i].URLAvatar)
 	}
 	return pointsList, nil
 }
@@ -0,0 +1,276 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// UploadsDir carpeta de los archivos subidos, se publica en /api/uploads.
+const UploadsDir = "./uploads"
+
+// las imágenes más grandes se dejan referenciadas por su url en lugar de incluirlas en el paquete.
+const moduleBundleMaxImageSize = 2 << 20
+
+// extensiones de las imágenes que se aceptan en los paquetes.
+var moduleBundleImageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// localUploadPath devuelve la ruta del archivo si la url apunta a un archivo subido a esta instalación.
+func localUploadPath(url string) (string, bool) {
+	index := strings.Index(url, "/api/uploads/")
+	if index < 0 {
+		return "", false
+	}
+	if index > 0 && !strings.HasPrefix(url, PublicHost+"/") {
+		return "", false
+	}
+
+	relative := path.Clean("/" + url[index+len("/api/uploads/"):])
+	return filepath.Join(UploadsDir, filepath.FromSlash(relative)), true
+}
+
+// bundleQuestion convierte la pregunta para el paquete sin desordenar las opciones, el id de la pregunta de origen se
+// conserva para indicarlo en el reporte de conflictos al importar (SourceID).
+func bundleQuestion(question Question) types.Question {
+	return types.Question{
+		ID:           question.ID,
+		TextRoot:     question.TextRoot,
+		Difficulty:   question.Difficulty,
+		TypeQuestion: string(question.TypeQuestion),
+		Options: types.Options{
+			SelectMode:     string(question.Options.SelectMode),
+			TextOptions:    question.Options.TextOptions,
+			TextToComplete: question.Options.TextToComplete,
+			Hind:           question.Options.Hind,
+		},
+		CorrectAnswer: &types.Answer{
+			TrueOrFalse:    question.CorrectAnswer.TrueOrFalse,
+			TextOptions:    question.CorrectAnswer.TextOptions,
+			TextToComplete: question.CorrectAnswer.TextToComplete,
+		},
+	}
+}
+
+// ExportModule genera el paquete con el borrador del módulo, sus preguntas y las imágenes subidas a esta instalación.
+func ExportModule(moduleID uint) (*types.ModuleBundle, error) {
+	module, err := ModuleByID(moduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []Question
+	result := db.DB.Preload("CorrectAnswer").Where("module_id = ?", moduleID).Order("created_at").Find(&questions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	bundle := types.ModuleBundle{
+		Format:     types.ModuleBundleFormat,
+		Version:    types.ModuleBundleVersion,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Source: types.ModuleBundleSource{
+			Host:     PublicHost,
+			ModuleID: module.ID,
+			Code:     module.Code,
+			Author:   strings.TrimSpace(module.CreatedBy.FirstName + " " + module.CreatedBy.LastName),
+		},
+		Module: types.ModuleBundleModule{
+			Title:            module.Title,
+			ShortDescription: module.ShortDescription,
+			TextRoot:         module.TextRoot,
+			ImgBackURL:       module.ImgBackURL,
+			Difficulty:       string(module.Difficulty),
+			PointsToEarn:     module.PointsToEarn,
+			Index:            module.Index,
+			Tags:             module.Tags,
+		},
+		Questions: make([]types.Question, 0, len(questions)),
+		Images:    make([]types.ModuleBundleImage, 0),
+	}
+	for _, question := range questions {
+		bundle.Questions = append(bundle.Questions, bundleQuestion(question))
+	}
+
+	if filePath, ok := localUploadPath(module.ImgBackURL); ok {
+		content, err := os.ReadFile(filePath)
+		if err == nil && len(content) <= moduleBundleMaxImageSize {
+			bundle.Images = append(bundle.Images, types.ModuleBundleImage{
+				Ref:         module.ImgBackURL,
+				FileName:    filepath.Base(filePath),
+				ContentType: http.DetectContentType(content),
+				Data:        content,
+			})
+		}
+	}
+
+	return &bundle, nil
+}
+
+// ImportModule valida el paquete y crea el módulo como borrador del usuario, con ids nuevos para el módulo y las preguntas.
+// Si hay conflictos de tipo error no se crea nada, con dryRun solo se validan los datos.
+func ImportModule(bundle types.ModuleBundle, ownerID uint, dryRun bool) (*types.ModuleImportResult, error) {
+	report := types.ModuleImportResult{
+		DryRun:      dryRun,
+		QuestionIDs: make(map[uint]uint),
+		Conflicts:   make([]types.ModuleImportConflict, 0),
+	}
+	conflict := func(severity string, question *int, sourceID uint, message string) {
+		report.Conflicts = append(report.Conflicts, types.ModuleImportConflict{
+			Severity: severity,
+			Question: question,
+			SourceID: sourceID,
+			Message:  message,
+		})
+	}
+
+	if err := bundle.ValidateFormat(); err != nil {
+		conflict(types.ConflictError, nil, 0, err.Error())
+		return &report, nil
+	}
+
+	module := types.Module{
+		Title:            bundle.Module.Title,
+		ShortDescription: bundle.Module.ShortDescription,
+		TextRoot:         bundle.Module.TextRoot,
+		ImgBackURL:       bundle.Module.ImgBackURL,
+		Difficulty:       bundle.Module.Difficulty,
+		PointsToEarn:     bundle.Module.PointsToEarn,
+		Index:            bundle.Module.Index,
+		Tags:             types.NormalizeTags(bundle.Module.Tags),
+	}
+	if resp, err := types.Validate(&module); err != nil {
+		for _, field := range resp {
+			conflict(types.ConflictError, nil, 0, fmt.Sprintf("el campo %s del módulo no es válido (%s)", field.Field, field.Tag))
+		}
+	}
+
+	// la imagen del módulo se guarda en esta instalación si viene en el paquete.
+	var image *types.ModuleBundleImage
+	for i := range bundle.Images {
+		if bundle.Images[i].Ref == bundle.Module.ImgBackURL {
+			image = &bundle.Images[i]
+		}
+	}
+	if image != nil {
+		if _, ok := moduleBundleImageTypes[http.DetectContentType(image.Data)]; !ok || len(image.Data) > moduleBundleMaxImageSize {
+			conflict(types.ConflictError, nil, 0, fmt.Sprintf("la imagen %s no es válida", image.FileName))
+			image = nil
+		}
+	} else if bundle.Module.ImgBackURL != "" {
+		conflict(types.ConflictWarning, nil, 0, "la imagen del módulo no viene en el paquete, se mantiene su url original")
+	}
+
+	var sameTitle int64
+	db.DB.Model(&Module{}).Where("created_by_id = ? AND title = ?", ownerID, module.Title).Count(&sameTitle)
+	if sameTitle > 0 {
+		conflict(types.ConflictWarning, nil, 0, fmt.Sprintf("ya tienes un módulo con el título %q, se creará otro módulo", module.Title))
+	}
+
+	questions := make([]types.Question, 0, len(bundle.Questions))
+	sourceIDs := make([]uint, 0, len(bundle.Questions))
+	seen := make(map[string]bool)
+	for i := range bundle.Questions {
+		question := bundle.Questions[i]
+		index := i
+		if err := types.ValidateQuestion(&question); err != nil {
+			conflict(types.ConflictError, &index, question.ID, err.Error())
+			continue
+		}
+		key := types.QuestionKey(question)
+		if seen[key] {
+			conflict(types.ConflictWarning, &index, question.ID, "la pregunta está repetida en el paquete, se omite")
+			continue
+		}
+		seen[key] = true
+		questions = append(questions, question)
+		sourceIDs = append(sourceIDs, question.ID)
+	}
+	if len(bundle.Questions) == 0 {
+		conflict(types.ConflictWarning, nil, 0, "el paquete no tiene preguntas")
+	}
+
+	if dryRun || report.HasErrors() {
+		return &report, nil
+	}
+
+	var imagePath string
+	if image != nil {
+		dir := filepath.Join(UploadsDir, "modules")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		imagePath = filepath.Join(dir, uuid.NewString()+moduleBundleImageTypes[http.DetectContentType(image.Data)])
+		if err := os.WriteFile(imagePath, image.Data, 0644); err != nil {
+			return nil, err
+		}
+		module.ImgBackURL = fmt.Sprintf("%s/api/%s", PublicHost, filepath.ToSlash(filepath.Clean(imagePath)))
+	}
+
+	var moduleDB Module
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		moduleDB = Module{
+			CreatedByID:      ownerID,
+			Code:             uuid.NewString()[0:8],
+			Title:            module.Title,
+			ShortDescription: module.ShortDescription,
+			TextRoot:         module.TextRoot,
+			ImgBackURL:       module.ImgBackURL,
+			Difficulty:       Difficulty(module.Difficulty),
+			PointsToEarn:     module.PointsToEarn,
+			Index:            module.Index,
+			Tags:             pq.StringArray(module.Tags),
+		}
+		if result := tx.Create(&moduleDB); result.Error != nil {
+			return result.Error
+		}
+
+		for i, questionAPI := range questions {
+			question := questionFromAPI(questionAPI)
+			question.ModuleID = &moduleDB.ID
+			if result := tx.Create(&question); result.Error != nil {
+				return result.Error
+			}
+			if sourceIDs[i] != 0 {
+				report.QuestionIDs[sourceIDs[i]] = question.ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if imagePath != "" {
+			_ = os.Remove(imagePath)
+		}
+		return nil, err
+	}
+
+	result := db.DB.Preload("CreatedBy").First(&moduleDB, moduleDB.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	moduleAPI := ModuleToApi(moduleDB)
+	report.Module = &moduleAPI
+	report.Imported = true
+	report.QuestionsImported = len(questions)
+	return &report, nil
+}
+
+// ModuleBundleFileName nombre del archivo con el que se descarga el paquete.
+func ModuleBundleFileName(bundle *types.ModuleBundle) string {
+	return fmt.Sprintf("modulo_%s_%s.json", bundle.Source.Code, time.Now().Format("20060102"))
+}
@@ -0,0 +1,367 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCollaboratorIsOwner  = errors.New("el dueño del módulo no puede ser colaborador")
+	ErrCollaboratorExists   = errors.New("el profesor ya es colaborador del módulo o tiene una invitación pendiente")
+	ErrCollaboratorNotFound = errors.New("el colaborador no existe")
+	ErrNotActiveTeacher     = errors.New("el usuario no es un profesor activo")
+	ErrInvalidTransfer      = errors.New("el módulo solo se puede transferir a otro profesor activo")
+)
+
+// ModuleCollaborator profesor invitado a un módulo con el rol de editor o lector, AcceptedAt es nil mientras la
+// invitación está pendiente. Se elimina físicamente al quitar al colaborador o al rechazar la invitación.
+type ModuleCollaborator struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ModuleID    uint `gorm:"uniqueIndex:idx_module_collaborator"`
+	Module      Module
+	UserID      uint `gorm:"uniqueIndex:idx_module_collaborator;index"`
+	User        User
+	Role        string
+	InvitedByID uint
+	InvitedBy   User `gorm:"foreignKey:InvitedByID"`
+	AcceptedAt  *time.Time
+}
+
+// ModuleCollaboratorToAPI convierte el colaborador para la API REST.
+func ModuleCollaboratorToAPI(collaborator ModuleCollaborator) types.ModuleCollaborator {
+	return types.ModuleCollaborator{
+		ID:         collaborator.ID,
+		ModuleID:   collaborator.ModuleID,
+		User:       *UserToAPI(collaborator.User),
+		Role:       collaborator.Role,
+		InvitedBy:  *UserToAPI(collaborator.InvitedBy),
+		Accepted:   collaborator.AcceptedAt != nil,
+		InvitedAt:  utils.GetDate(collaborator.CreatedAt),
+		AcceptedAt: utils.GetFullDateOrNull(collaborator.AcceptedAt),
+	}
+}
+
+// ModuleRoleOf rol del usuario en el módulo: owner, editor, viewer o vacío si no tiene acceso.
+// Las invitaciones pendientes no dan acceso.
+func ModuleRoleOf(userID, moduleID uint) (string, error) {
+	var module Module
+	result := db.DB.Select("id", "created_by_id").First(&module, moduleID)
+	if result.Error != nil {
+		return "", notFound(result.Error)
+	}
+	if module.CreatedByID == userID {
+		return types.ModuleRoleOwner, nil
+	}
+
+	var collaborator ModuleCollaborator
+	result = db.DB.Select("id", "role").
+		Where("module_id = ? AND user_id = ? AND accepted_at IS NOT NULL", moduleID, userID).
+		Limit(1).Find(&collaborator)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return collaborator.Role, nil
+}
+
+// ModuleRolesForUser rol del usuario en cada uno de los módulos, los módulos sin acceso no se incluyen.
+func ModuleRolesForUser(userID uint, moduleIDs []uint) (map[uint]string, error) {
+	roles := make(map[uint]string, len(moduleIDs))
+	if len(moduleIDs) == 0 {
+		return roles, nil
+	}
+
+	var modules []Module
+	if err := db.DB.Select("id").Where("id IN ? AND created_by_id = ?", moduleIDs, userID).Find(&modules).Error; err != nil {
+		return nil, err
+	}
+	for _, module := range modules {
+		roles[module.ID] = types.ModuleRoleOwner
+	}
+
+	var collaborators []ModuleCollaborator
+	result := db.DB.Select("module_id", "role").
+		Where("module_id IN ? AND user_id = ? AND accepted_at IS NOT NULL", moduleIDs, userID).
+		Find(&collaborators)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, collaborator := range collaborators {
+		if _, ok := roles[collaborator.ModuleID]; !ok {
+			roles[collaborator.ModuleID] = collaborator.Role
+		}
+	}
+	return roles, nil
+}
+
+// IsModuleOwner revisa si el usuario es el dueño del módulo, solo el dueño gestiona los colaboradores.
+func IsModuleOwner(userID, moduleID uint) (bool, error) {
+	role, err := ModuleRoleOf(userID, moduleID)
+	if err != nil {
+		return false, err
+	}
+	return role == types.ModuleRoleOwner, nil
+}
+
+// CanViewModule revisa si el usuario puede ver el borrador del módulo, el dueño y cualquier colaborador.
+func CanViewModule(userID, moduleID uint) (bool, error) {
+	role, err := ModuleRoleOf(userID, moduleID)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
+}
+
+// GetModuleCollaborators lista los colaboradores del módulo, también los que no han aceptado la invitación.
+func GetModuleCollaborators(moduleID uint) ([]ModuleCollaborator, error) {
+	var collaborators []ModuleCollaborator
+	result := db.DB.Preload("User").Preload("InvitedBy").
+		Where("module_id = ?", moduleID).
+		Order("id asc").
+		Find(&collaborators)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return collaborators, nil
+}
+
+// activeTeacher revisa que el usuario esté activo y que su rol permita editar módulos.
+func activeTeacher(userID uint) (*User, error) {
+	var user User
+	result := db.DB.First(&user, userID)
+	if result.Error != nil {
+		return nil, notFound(result.Error)
+	}
+	if user.Status != Actived {
+		return nil, ErrNotActiveTeacher
+	}
+
+	permissions, err := GetRolePermissions(string(user.TypeUser))
+	if err != nil {
+		return nil, err
+	}
+	for _, permission := range permissions {
+		if permission == PermModuleEdit {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotActiveTeacher
+}
+
+// InviteCollaborator invita al profesor al módulo, la invitación queda pendiente hasta que el profesor la acepte.
+func InviteCollaborator(moduleID, invitedByID, userID uint, role string) (*ModuleCollaborator, error) {
+	var module Module
+	result := db.DB.Select("id", "created_by_id").First(&module, moduleID)
+	if result.Error != nil {
+		return nil, notFound(result.Error)
+	}
+	if module.CreatedByID == userID {
+		return nil, ErrCollaboratorIsOwner
+	}
+	if _, err := activeTeacher(userID); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := db.DB.Model(&ModuleCollaborator{}).Where("module_id = ? AND user_id = ?", moduleID, userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrCollaboratorExists
+	}
+
+	collaborator := ModuleCollaborator{
+		ModuleID:    moduleID,
+		UserID:      userID,
+		Role:        role,
+		InvitedByID: invitedByID,
+	}
+	if err := db.DB.Create(&collaborator).Error; err != nil {
+		return nil, err
+	}
+	return GetModuleCollaborator(moduleID, collaborator.ID)
+}
+
+// GetModuleCollaborator recupera el colaborador del módulo.
+func GetModuleCollaborator(moduleID, collaboratorID uint) (*ModuleCollaborator, error) {
+	var collaborator ModuleCollaborator
+	result := db.DB.Preload("User").Preload("InvitedBy").
+		Where("id = ? AND module_id = ?", collaboratorID, moduleID).
+		First(&collaborator)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrCollaboratorNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &collaborator, nil
+}
+
+// UpdateCollaboratorRole cambia el rol del colaborador entre editor y lector.
+func UpdateCollaboratorRole(moduleID, collaboratorID uint, role string) (*ModuleCollaborator, error) {
+	collaborator, err := GetModuleCollaborator(moduleID, collaboratorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.DB.Model(collaborator).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return collaborator, nil
+}
+
+// RemoveCollaborator quita al colaborador del módulo o cancela su invitación.
+func RemoveCollaborator(moduleID, collaboratorID uint) error {
+	result := db.DB.Where("id = ? AND module_id = ?", collaboratorID, moduleID).Delete(&ModuleCollaborator{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCollaboratorNotFound
+	}
+	return nil
+}
+
+// GetPendingInvitations invitaciones del profesor que aún no ha aceptado.
+func GetPendingInvitations(userID uint) ([]ModuleCollaborator, error) {
+	var invitations []ModuleCollaborator
+	result := db.DB.Preload("Module").Preload("Module.CreatedBy").Preload("InvitedBy").
+		Joins("JOIN modules ON modules.id = module_collaborators.module_id AND modules.deleted_at IS NULL").
+		Where("module_collaborators.user_id = ? AND module_collaborators.accepted_at IS NULL", userID).
+		Order("module_collaborators.id desc").
+		Find(&invitations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return invitations, nil
+}
+
+// ModuleInvitationToAPI convierte la invitación pendiente para la API REST.
+func ModuleInvitationToAPI(invitation ModuleCollaborator) types.ModuleInvitation {
+	return types.ModuleInvitation{
+		ID:        invitation.ID,
+		Module:    ModuleToApi(invitation.Module),
+		Role:      invitation.Role,
+		InvitedBy: *UserToAPI(invitation.InvitedBy),
+		InvitedAt: utils.GetDate(invitation.CreatedAt),
+	}
+}
+
+// AcceptInvitation el profesor acepta la invitación y empieza a ver el módulo en su listado.
+func AcceptInvitation(userID, invitationID uint) (*ModuleCollaborator, error) {
+	var invitation ModuleCollaborator
+	result := db.DB.Where("id = ? AND user_id = ? AND accepted_at IS NULL", invitationID, userID).First(&invitation)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrCollaboratorNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := db.DB.Model(&invitation).Update("accepted_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return GetModuleCollaborator(invitation.ModuleID, invitation.ID)
+}
+
+// LeaveCollaboration el profesor rechaza la invitación o deja de colaborar en el módulo.
+func LeaveCollaboration(userID, invitationID uint) (*ModuleCollaborator, error) {
+	var collaborator ModuleCollaborator
+	result := db.DB.Where("id = ? AND user_id = ?", invitationID, userID).First(&collaborator)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrCollaboratorNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := db.DB.Delete(&collaborator).Error; err != nil {
+		return nil, err
+	}
+	return &collaborator, nil
+}
+
+// TransferModuleOwnership transfiere el módulo a otro profesor activo. Si el nuevo dueño era colaborador deja de
+// serlo, con keepPrevious el dueño anterior queda como editor.
+func TransferModuleOwnership(moduleID, newOwnerID uint, keepPrevious bool) (*Module, error) {
+	var module Module
+	result := db.DB.Select("id", "created_by_id").First(&module, moduleID)
+	if result.Error != nil {
+		return nil, notFound(result.Error)
+	}
+	if module.CreatedByID == newOwnerID {
+		return nil, ErrInvalidTransfer
+	}
+	if _, err := activeTeacher(newOwnerID); err != nil {
+		if errors.Is(err, ErrNotActiveTeacher) || errors.Is(err, ErrResourceNotFound) {
+			return nil, ErrInvalidTransfer
+		}
+		return nil, err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return transferModule(tx, module.ID, module.CreatedByID, newOwnerID, keepPrevious)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	module.CreatedByID = newOwnerID
+	return &module, nil
+}
+
+// transferModule cambia el dueño del módulo dentro de la transacción.
+func transferModule(tx *gorm.DB, moduleID, previousOwnerID, newOwnerID uint, keepPrevious bool) error {
+	if err := tx.Model(&Module{}).Where("id = ?", moduleID).Update("created_by_id", newOwnerID).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("module_id = ? AND user_id = ?", moduleID, newOwnerID).Delete(&ModuleCollaborator{}).Error; err != nil {
+		return err
+	}
+	if !keepPrevious {
+		return nil
+	}
+
+	now := time.Now()
+	return tx.Create(&ModuleCollaborator{
+		ModuleID:    moduleID,
+		UserID:      previousOwnerID,
+		Role:        types.ModuleRoleEditor,
+		InvitedByID: newOwnerID,
+		AcceptedAt:  &now,
+	}).Error
+}
+
+// transferModulesOnLeave cuando un profesor deja la plataforma (se bloquea o se elimina su cuenta) cada uno de sus
+// módulos pasa al editor más antiguo, los módulos sin editores se quedan con el usuario y un administrador los
+// puede transferir. También se quitan sus colaboraciones.
+func transferModulesOnLeave(tx *gorm.DB, userID uint) error {
+	var modules []Module
+	if err := tx.Select("id").Where("created_by_id = ?", userID).Find(&modules).Error; err != nil {
+		return err
+	}
+
+	for _, module := range modules {
+		var editor ModuleCollaborator
+		result := tx.Where("module_id = ? AND role = ? AND accepted_at IS NOT NULL", module.ID, types.ModuleRoleEditor).
+			Order("accepted_at asc, id asc").
+			Limit(1).
+			Find(&editor)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := transferModule(tx, module.ID, userID, editor.UserID, false); err != nil {
+			return err
+		}
+	}
+
+	return tx.Where("user_id = ?", userID).Delete(&ModuleCollaborator{}).Error
+}
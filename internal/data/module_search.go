@@ -0,0 +1,141 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SortRelevance ordena los módulos por la relevancia de la búsqueda, es el orden por defecto cuando se busca.
+const SortRelevance = "relevance"
+
+// configuración de búsqueda en español que además ignora las tildes.
+const moduleSearchConfig = "es_unaccent"
+
+// documento de búsqueda del módulo, se indexa con la misma expresión.
+const moduleSearchDocument = "module_search_document(modules.title, modules.short_description, modules.text_root, modules.tags)"
+
+// cantidad de términos de la búsqueda y de etiquetas que se devuelven como facetas.
+const (
+	moduleSearchMaxTerms = 10
+	moduleTagFacetsLimit = 30
+)
+
+// SetupModuleSearch crea la configuración de búsqueda en español sin tildes, la función que arma el documento
+// del módulo (título y etiquetas pesan más que la descripción y el texto) y los índices.
+func SetupModuleSearch() error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS unaccent`,
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'es_unaccent') THEN
+				CREATE TEXT SEARCH CONFIGURATION es_unaccent (COPY = spanish);
+				ALTER TEXT SEARCH CONFIGURATION es_unaccent ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+			END IF;
+		END
+		$$`,
+		`CREATE OR REPLACE FUNCTION module_search_document(title text, short_description text, text_root text, tags text[]) RETURNS tsvector AS $$
+			SELECT setweight(to_tsvector('es_unaccent', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('es_unaccent', coalesce(array_to_string(tags, ' '), '')), 'A') ||
+				setweight(to_tsvector('es_unaccent', coalesce(short_description, '')), 'B') ||
+				setweight(to_tsvector('es_unaccent', coalesce(text_root, '')), 'C')
+		$$ LANGUAGE sql IMMUTABLE`,
+		`CREATE INDEX IF NOT EXISTS idx_modules_search ON modules USING GIN (` + moduleSearchDocument + `)`,
+		`CREATE INDEX IF NOT EXISTS idx_modules_tags ON modules USING GIN (tags)`,
+	}
+	for _, statement := range statements {
+		if err := db.DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moduleSearchQuery convierte el texto buscado en una consulta por prefijos para to_tsquery,
+// "acentuacion agud" queda como "acentuacion:* & agud:*". Se descartan los signos para no romper la sintaxis.
+func moduleSearchQuery(text string) string {
+	terms := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > moduleSearchMaxTerms {
+		terms = terms[:moduleSearchMaxTerms]
+	}
+	for i := range terms {
+		terms[i] += ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// moduleSearch filtra los módulos por el texto buscado y por las etiquetas.
+func moduleSearch(paginated *types.Paginated, filter *types.ModuleFilter) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if query := moduleSearchQuery(paginated.Query); query != "" {
+			tx = tx.Where(moduleSearchDocument+" @@ to_tsquery('"+moduleSearchConfig+"', ?)", query)
+		}
+		if tags := filter.TagList(); len(tags) > 0 {
+			tx = tx.Where("modules.tags @> ?", pq.StringArray(tags))
+		}
+		return tx
+	}
+}
+
+// moduleOrder orden del listado, con relevance se ordena por el ranking de la búsqueda.
+func moduleOrder(paginated *types.Paginated) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if paginated.Sort != SortRelevance {
+			return tx.Order(fmt.Sprintf("%s %s", paginated.Sort, paginated.Order))
+		}
+
+		query := moduleSearchQuery(paginated.Query)
+		if query == "" {
+			return tx.Order("modules.id asc")
+		}
+		return tx.Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "ts_rank(" + moduleSearchDocument + ", to_tsquery('" + moduleSearchConfig + "', ?)) DESC, modules.id ASC",
+			Vars:               []interface{}{query},
+			WithoutParentheses: true,
+		}})
+	}
+}
+
+// moduleTagFacets cuenta las etiquetas de los módulos que cumplen la búsqueda, scope limita los módulos del listado.
+func moduleTagFacets(scope func(tx *gorm.DB) *gorm.DB, paginated *types.Paginated, filter *types.ModuleFilter) ([]types.TagFacet, error) {
+	facets := make([]types.TagFacet, 0)
+	result := db.DB.
+		Table("modules, unnest(modules.tags) AS tag").
+		Select("tag, COUNT(*) AS count").
+		Where("modules.deleted_at IS NULL").
+		Scopes(scope, moduleSearch(paginated, filter)).
+		Group("tag").
+		Order("count DESC, tag ASC").
+		Limit(moduleTagFacetsLimit).
+		Scan(&facets)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return facets, nil
+}
+
+// teacherModules módulos del profesor y los módulos en los que colabora, sin las invitaciones pendientes.
+func teacherModules(userID uint) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		shared := db.DB.Model(&ModuleCollaborator{}).Select("module_id").Where("user_id = ? AND accepted_at IS NOT NULL", userID)
+		return tx.Where("(modules.created_by_id = ? OR modules.id IN (?))", userID, shared)
+	}
+}
+
+// publishedModules módulos públicos con una versión publicada, los que ven los estudiantes.
+func publishedModules(tx *gorm.DB) *gorm.DB {
+	return tx.Where("modules.is_public = true AND modules.published_version_id IS NOT NULL")
+}
+
+// allModules todos los módulos, sin importar quien los haya creado.
+func allModules(tx *gorm.DB) *gorm.DB {
+	return tx
+}
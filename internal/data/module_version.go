@@ -0,0 +1,310 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ModuleVersion versión publicada de un módulo. Es inmutable: guarda una copia de los datos del módulo
+// y sus preguntas son copias de las preguntas del borrador, así los test quedan ligados a la versión
+// con la que se realizaron aunque el profesor siga editando el borrador.
+type ModuleVersion struct {
+	gorm.Model
+	ModuleID         uint `gorm:"uniqueIndex:idx_module_version"`
+	Version          int  `gorm:"uniqueIndex:idx_module_version"`
+	Title            string
+	ShortDescription string
+	TextRoot         string
+	ImgBackURL       string
+	Difficulty       Difficulty
+	PointsToEarn     int
+	Notes            string
+	QuestionsCount   int
+	PublishedByID    uint
+	PublishedBy      User `gorm:"foreignKey:PublishedByID"`
+}
+
+var (
+	ErrModuleWithoutQuestions = errors.New("el módulo no tiene preguntas para publicar")
+	ErrModuleNotPublished     = errors.New("el módulo aún no tiene una versión publicada")
+)
+
+func ModuleVersionToAPI(version ModuleVersion) types.ModuleVersion {
+	return types.ModuleVersion{
+		ID:               version.ID,
+		ModuleID:         version.ModuleID,
+		Version:          version.Version,
+		Title:            version.Title,
+		ShortDescription: version.ShortDescription,
+		TextRoot:         version.TextRoot,
+		ImgBackURL:       version.ImgBackURL,
+		Difficulty:       DifficultyToFrontend(string(version.Difficulty)),
+		PointsToEarn:     version.PointsToEarn,
+		Notes:            version.Notes,
+		QuestionsCount:   version.QuestionsCount,
+		PublishedBy:      UserToAPI(version.PublishedBy),
+		PublishedAt:      utils.GetFullDate(version.CreatedAt),
+	}
+}
+
+func ModuleVersionsToAPI(versions []ModuleVersion) []types.ModuleVersion {
+	versionsAPI := make([]types.ModuleVersion, 0, len(versions))
+	for _, version := range versions {
+		versionsAPI = append(versionsAPI, ModuleVersionToAPI(version))
+	}
+	return versionsAPI
+}
+
+// applyModuleVersion reemplaza los datos del borrador por los de la versión publicada, es lo que ven los estudiantes.
+func applyModuleVersion(module *types.Module, version *ModuleVersion) {
+	if version == nil {
+		return
+	}
+	module.Title = version.Title
+	module.ShortDescription = version.ShortDescription
+	module.TextRoot = version.TextRoot
+	module.ImgBackURL = version.ImgBackURL
+	module.Difficulty = DifficultyToFrontend(string(version.Difficulty))
+	module.PointsToEarn = version.PointsToEarn
+	module.PublishedVersion = version.Version
+}
+
+// PublishedModuleToApi convierte el módulo con los datos de su versión publicada.
+func PublishedModuleToApi(module Module) types.Module {
+	moduleAPI := ModuleToApi(module)
+	applyModuleVersion(&moduleAPI, module.PublishedVersion)
+	return moduleAPI
+}
+
+func PublishedModulesToAPI(modules []Module) []types.Module {
+	modulesApi := make([]types.Module, len(modules))
+	for i, module := range modules {
+		modulesApi[i] = PublishedModuleToApi(module)
+	}
+	return modulesApi
+}
+
+// copyQuestion copia la pregunta con su respuesta correcta, la copia no pertenece a ningún módulo.
+func copyQuestion(question Question) Question {
+	return Question{
+		TextRoot:     question.TextRoot,
+		Difficulty:   question.Difficulty,
+		TypeQuestion: question.TypeQuestion,
+		Options: Options{
+			SelectMode:     question.Options.SelectMode,
+			TextOptions:    append(pq.StringArray{}, question.Options.TextOptions...),
+			TextToComplete: question.Options.TextToComplete,
+			Hind:           question.Options.Hind,
+		},
+		CorrectAnswer: Answer{
+			TrueOrFalse:    question.CorrectAnswer.TrueOrFalse,
+			TextOptions:    append(pq.StringArray{}, question.CorrectAnswer.TextOptions...),
+			TextToComplete: append(pq.StringArray{}, question.CorrectAnswer.TextToComplete...),
+		},
+	}
+}
+
+// PublishModule publica el borrador del módulo como una nueva versión y la deja como la versión vigente.
+func PublishModule(moduleID, publishedByID uint, notes string) (*ModuleVersion, error) {
+	var version ModuleVersion
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// bloqueamos el módulo para que dos publicaciones no tomen el mismo número de versión.
+		var module Module
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&module, moduleID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var questions []Question
+		result = tx.Preload("CorrectAnswer").Where("module_id = ?", moduleID).Order("created_at").Find(&questions)
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(questions) == 0 {
+			return ErrModuleWithoutQuestions
+		}
+
+		var last int
+		result = tx.Model(&ModuleVersion{}).Select("COALESCE(MAX(version), 0)").Where("module_id = ?", moduleID).Scan(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var err error
+		version, err = createModuleVersion(tx, module, last+1, publishedByID, notes, questions)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.DB.Preload("PublishedBy").First(&version, version.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &version, nil
+}
+
+// createModuleVersion registra la versión con las copias de las preguntas y la deja como la versión vigente del módulo.
+func createModuleVersion(tx *gorm.DB, module Module, number int, publishedByID uint, notes string, questions []Question) (ModuleVersion, error) {
+	version := ModuleVersion{
+		ModuleID:         module.ID,
+		Version:          number,
+		Title:            module.Title,
+		ShortDescription: module.ShortDescription,
+		TextRoot:         module.TextRoot,
+		ImgBackURL:       module.ImgBackURL,
+		Difficulty:       module.Difficulty,
+		PointsToEarn:     module.PointsToEarn,
+		Notes:            notes,
+		QuestionsCount:   len(questions),
+		PublishedByID:    publishedByID,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return version, err
+	}
+
+	if len(questions) > 0 {
+		snapshot := make([]Question, 0, len(questions))
+		for _, question := range questions {
+			copied := copyQuestion(question)
+			copied.ModuleVersionID = &version.ID
+			snapshot = append(snapshot, copied)
+		}
+		if err := tx.Create(&snapshot).Error; err != nil {
+			return version, err
+		}
+	}
+
+	// UpdateColumn no cambia updated_at, así el borrador no aparece con cambios después de publicar.
+	err := tx.Model(&Module{}).Where("id = ?", module.ID).UpdateColumn("published_version_id", version.ID).Error
+	return version, err
+}
+
+// GetModuleVersions lista las versiones publicadas del módulo, la más reciente primero.
+func GetModuleVersions(moduleID uint) ([]ModuleVersion, error) {
+	var versions []ModuleVersion
+	result := db.DB.Preload("PublishedBy").Where("module_id = ?", moduleID).Order("version desc").Find(&versions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return versions, nil
+}
+
+// GetModuleVersion recupera una versión publicada del módulo con sus preguntas.
+func GetModuleVersion(moduleID uint, number int) (*types.ModuleVersion, error) {
+	var version ModuleVersion
+	result := db.DB.Preload("PublishedBy").Where("module_id = ? AND version = ?", moduleID, number).First(&version)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var questions []Question
+	result = db.DB.Preload("CorrectAnswer").Where("module_version_id = ?", version.ID).Order("id").Find(&questions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	versionAPI := ModuleVersionToAPI(version)
+	versionAPI.Questions = QuestionListToAPI(questions)
+	return &versionAPI, nil
+}
+
+// GetModulePreview recupera el borrador del módulo con sus preguntas e indica si tiene cambios sin publicar.
+func GetModulePreview(moduleID uint) (*types.ModulePreview, error) {
+	module, err := ModuleByID(moduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	questions, err := GetQuestionsForModule(moduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := types.ModulePreview{
+		Module:                ModuleToApi(*module),
+		Questions:             questions,
+		HasUnpublishedChanges: true,
+	}
+	if module.PublishedVersion == nil {
+		return &preview, nil
+	}
+
+	// hay cambios si el módulo o alguna pregunta del borrador se modificó o eliminó después de publicar.
+	publishedAt := module.PublishedVersion.CreatedAt
+	var changedQuestions int64
+	result := db.DB.Unscoped().Model(&Question{}).
+		Where("module_id = ?", moduleID).
+		Where("created_at > ? OR updated_at > ? OR deleted_at > ?", publishedAt, publishedAt, publishedAt).
+		Count(&changedQuestions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	preview.HasUnpublishedChanges = module.UpdatedAt.After(publishedAt) || changedQuestions > 0
+
+	return &preview, nil
+}
+
+// PublishLegacyModules publica como versión 1 los módulos creados antes de que existieran las versiones.
+// Las preguntas actuales pasan a la versión 1 para que los test realizados sigan ligados a ellas,
+// y el borrador recibe una copia de las preguntas para seguir editándolas.
+func PublishLegacyModules() error {
+	var modules []Module
+	result := db.DB.Where("published_version_id IS NULL").Find(&modules)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, module := range modules {
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var questions []Question
+			result := tx.Preload("CorrectAnswer").Where("module_id = ?", module.ID).Order("created_at").Find(&questions)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			version, err := createModuleVersion(tx, module, 1, module.CreatedByID, "Versión inicial", nil)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&ModuleVersion{}).Where("id = ?", version.ID).Update("questions_count", len(questions)).Error; err != nil {
+				return err
+			}
+
+			// las preguntas originales, incluidas las eliminadas, quedan en la versión 1.
+			result = tx.Unscoped().Model(&Question{}).Where("module_id = ?", module.ID).
+				Updates(map[string]interface{}{"module_version_id": version.ID, "module_id": nil})
+			if result.Error != nil {
+				return result.Error
+			}
+			result = tx.Model(&TestModule{}).Where("module_id = ? AND module_version_id IS NULL", module.ID).
+				Update("module_version_id", version.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			for _, question := range questions {
+				draft := copyQuestion(question)
+				draft.ModuleID = &module.ID
+				draft.CreatedAt = question.CreatedAt
+				draft.UpdatedAt = question.UpdatedAt
+				if err := tx.Create(&draft).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Println("Error al publicar la versión inicial del módulo", module.ID, err)
+		}
+	}
+	return nil
+}
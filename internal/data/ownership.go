@@ -0,0 +1,92 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrResourceNotFound el recurso sobre el que se revisan los permisos no existe.
+var ErrResourceNotFound = errors.New("el recurso no existe")
+
+// CanManageModule revisa si el usuario puede modificar el módulo, el dueño y los colaboradores con rol de editor.
+func CanManageModule(userID, moduleID uint) (bool, error) {
+	role, err := ModuleRoleOf(userID, moduleID)
+	if err != nil {
+		return false, err
+	}
+	return role == types.ModuleRoleOwner || role == types.ModuleRoleEditor, nil
+}
+
+// CanManageQuestion revisa que la pregunta pertenezca al módulo y que el usuario pueda modificar el módulo.
+func CanManageQuestion(userID, moduleID, questionID uint) (bool, error) {
+	var question Question
+	result := db.DB.Select("id", "module_id").Where("id = ? AND module_id = ?", questionID, moduleID).First(&question)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+	return CanManageModule(userID, moduleID)
+}
+
+// CanManageClass revisa si el usuario creó la clase o es el profesor asignado.
+func CanManageClass(userID, classID uint) (bool, error) {
+	var class Class
+	result := db.DB.Select("id", "create_by_id", "teacher_id").First(&class, classID)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+	return class.CreateByID == userID || class.TeacherID == userID, nil
+}
+
+// CanManageLearningPath revisa si el usuario creó la ruta de aprendizaje.
+func CanManageLearningPath(userID, pathID uint) (bool, error) {
+	var path LearningPath
+	result := db.DB.Select("id", "created_by_id").First(&path, pathID)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+	return path.CreatedByID == userID, nil
+}
+
+// IsTestOwner revisa que el test pertenezca al estudiante.
+func IsTestOwner(userID, testID uint) (bool, error) {
+	var test TestModule
+	result := db.DB.Select("id", "user_id").First(&test, testID)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+	return test.UserID == userID, nil
+}
+
+// CanViewTest revisa que el test sea del estudiante o que el usuario sea dueño o colaborador del módulo del test,
+// así el profesor puede revisar los test de sus estudiantes.
+func CanViewTest(userID, testID uint) (bool, error) {
+	var test TestModule
+	result := db.DB.Select("id", "user_id", "module_id").First(&test, testID)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+	if test.UserID == userID {
+		return true, nil
+	}
+	return CanViewModule(userID, test.ModuleID)
+}
+
+// IsAnswerUserOwner revisa que la respuesta pertenezca a un test del estudiante.
+func IsAnswerUserOwner(userID, answerUserID uint) (bool, error) {
+	var answerUser AnswerUser
+	result := db.DB.Select("id", "test_module_id").First(&answerUser, answerUserID)
+	if result.Error != nil {
+		return false, notFound(result.Error)
+	}
+	return IsTestOwner(userID, answerUser.TestModuleID)
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrResourceNotFound
+	}
+	return err
+}
@@ -13,8 +13,11 @@ import (
 
 type Question struct {
 	gorm.Model
-	ModuleID        *uint
-	Module          Module
+	// ModuleID módulo cuyo borrador contiene la pregunta, las copias publicadas no tienen módulo.
+	ModuleID *uint
+	Module   Module
+	// ModuleVersionID versión publicada a la que pertenece la copia de la pregunta.
+	ModuleVersionID *uint `gorm:"index"`
 	QuestionnaireID *uint
 	Questionnaire   Questionnaire
 	TextRoot        string
@@ -57,10 +60,9 @@ func QuestionListToAPI(questions []Question) []types.Question {
 	return questionList
 }
 
-func RegisterQuestionForModule(questionAPI types.Question) (types.Question, error) {
-
-	// convertimos los datos que nos envian a una question entidad
-	question := Question{
+// questionFromAPI convierte la pregunta de la API REST en una entidad nueva, con su respuesta correcta.
+func questionFromAPI(questionAPI types.Question) Question {
+	return Question{
 		ModuleID:        questionAPI.ModuleID,
 		QuestionnaireID: nil,
 		TextRoot:        questionAPI.TextRoot,
@@ -78,6 +80,12 @@ func RegisterQuestionForModule(questionAPI types.Question) (types.Question, erro
 			TextToComplete: pq.StringArray(questionAPI.CorrectAnswer.TextToComplete),
 		},
 	}
+}
+
+func RegisterQuestionForModule(questionAPI types.Question) (types.Question, error) {
+
+	// convertimos los datos que nos envian a una question entidad
+	question := questionFromAPI(questionAPI)
 
 	// Registramos en la base de datos.
 	result := db.DB.Create(&question)
@@ -159,10 +167,11 @@ func UpdateQuestion(question types.Question) error {
 	return nil
 }
 
-func GenerateQuestions(moduleID uint, limit int) ([]Question, error) {
+// GenerateQuestions selecciona preguntas aleatorias de la versión publicada del módulo.
+func GenerateQuestions(moduleVersionID uint, limit int) ([]Question, error) {
 
 	var questions []Question
-	result := db.DB.Where("module_id = ?", moduleID).Order("RANDOM()").Limit(limit).Find(&questions)
+	result := db.DB.Where("module_version_id = ?", moduleVersionID).Order("RANDOM()").Limit(limit).Find(&questions)
 	if result.Error != nil {
 		return nil, result.Error
 	}
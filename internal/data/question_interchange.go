@@ -0,0 +1,43 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+
+	"gorm.io/gorm"
+)
+
+// GetDraftQuestionsForExport recupera las preguntas del borrador del módulo con su respuesta correcta y sin desordenar las opciones.
+func GetDraftQuestionsForExport(moduleID uint) ([]types.Question, error) {
+	var questions []Question
+	result := db.DB.Preload("CorrectAnswer").Where("module_id = ?", moduleID).Order("created_at").Find(&questions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	questionsAPI := make([]types.Question, 0, len(questions))
+	for _, question := range questions {
+		questionsAPI = append(questionsAPI, bundleQuestion(question))
+	}
+	return questionsAPI, nil
+}
+
+// ImportQuestionsToModule agrega al borrador del módulo las preguntas convertidas, con dryRun no se guarda nada.
+func ImportQuestionsToModule(moduleID uint, report *types.QuestionImportResult) error {
+	if report.DryRun || len(report.Questions) == 0 {
+		return nil
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for i, questionAPI := range report.Questions {
+			question := questionFromAPI(questionAPI)
+			question.ModuleID = &moduleID
+			if result := tx.Create(&question); result.Error != nil {
+				return result.Error
+			}
+			report.Questions[i] = bundleQuestion(question)
+		}
+		report.Imported = len(report.Questions)
+		return nil
+	})
+}
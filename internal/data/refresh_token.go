@@ -0,0 +1,130 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken token de larga duración que permite renovar el JWT de acceso.
+// Solo se guarda el hash del token.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint
+	User      User
+	SessionID *uint  `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// SaveRefreshToken registra un nuevo refresh token para la sesión del usuario.
+func SaveRefreshToken(userID, sessionID uint, tokenHash string, expiresAt time.Time) error {
+	refreshToken := RefreshToken{
+		UserID:    userID,
+		SessionID: &sessionID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}
+
+	result := db.DB.Create(&refreshToken)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// RotateRefreshToken revoca el refresh token actual y registra el nuevo en la misma sesión,
+// retorna el id del usuario y de la sesión.
+// Si el token ya fue revocado se considera reutilizado y se cierran todas las sesiones del usuario.
+// Los tokens emitidos antes de registrar las sesiones reciben una sesión con el dispositivo actual.
+func RotateRefreshToken(oldHash, newHash string, expiresAt time.Time, userAgent, ip string) (uint, uint, error) {
+	var refreshToken RefreshToken
+	result := db.DB.Where("token_hash = ?", oldHash).First(&refreshToken)
+	if result.Error != nil {
+		return 0, 0, errors.New("el refresh token no es valido")
+	}
+
+	if refreshToken.RevokedAt != nil {
+		_ = RevokeUserSessions(refreshToken.UserID, 0)
+		return 0, 0, errors.New("el refresh token ya fue utilizado")
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return 0, 0, errors.New("el refresh token ha expirado")
+	}
+
+	var sessionID uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// revocamos el token actual, solo si nadie lo revoco antes.
+		now := time.Now()
+		result := tx.Model(&RefreshToken{}).Where("id = ? AND revoked_at IS NULL", refreshToken.ID).Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("el refresh token ya fue utilizado")
+		}
+
+		if refreshToken.SessionID == nil {
+			session := Session{UserID: refreshToken.UserID, UserAgent: userAgent, IP: ip, LastSeenAt: now, ExpiresAt: expiresAt}
+			if err := tx.Create(&session).Error; err != nil {
+				return err
+			}
+			sessionID = session.ID
+		} else {
+			sessionID = *refreshToken.SessionID
+			result = tx.Model(&Session{}).
+				Where("id = ? AND revoked_at IS NULL", sessionID).
+				Updates(map[string]interface{}{"last_seen_at": now, "expires_at": expiresAt})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("la sesión fue cerrada")
+			}
+		}
+
+		return tx.Create(&RefreshToken{
+			UserID:    refreshToken.UserID,
+			SessionID: &sessionID,
+			TokenHash: newHash,
+			ExpiresAt: expiresAt,
+		}).Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return refreshToken.UserID, sessionID, nil
+}
+
+// RevokeRefreshToken revoca un refresh token y cierra su sesión, se utiliza al cerrar sesión.
+func RevokeRefreshToken(tokenHash string) error {
+	var refreshToken RefreshToken
+	result := db.DB.Where("token_hash = ?", tokenHash).First(&refreshToken)
+	if result.Error != nil {
+		return nil
+	}
+
+	if refreshToken.SessionID != nil {
+		err := RevokeSession(refreshToken.UserID, *refreshToken.SessionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	result = db.DB.Model(&RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", refreshToken.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
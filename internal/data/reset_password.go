@@ -3,56 +3,82 @@ package data
 import (
 	"Proyectos-UTEQ/api-ortografia/internal/db"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// ResetPassword token opaco de un solo uso para reestablecer la contraseña.
+// Solo se guarda el hash del token.
 type ResetPassword struct {
 	gorm.Model
-	UserID uint
-	User   User
-	Email  string
-	Token  string
-	Used   bool
+	UserID    uint
+	User      User
+	Email     string
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	RequestIP string
+	Used      bool
 }
 
 func (ResetPassword) TableName() string {
 	return "reset_password"
 }
 
-// registramos un nuevo reset password
-// basicamente se registra que se genero un nuevo jwt.
-func SaveResetPassword(id uint, email string, token string) error {
+// SaveResetPassword registramos un nuevo reset password,
+// todos los tokens anteriores del usuario quedan invalidados.
+func SaveResetPassword(id uint, email string, tokenHash string, expiresAt time.Time, ip string) error {
+	tx := db.DB.Begin()
+
+	result := tx.Model(&ResetPassword{}).Where("user_id = ? AND used = false", id).Update("used", true)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+
 	resetPassword := ResetPassword{
-		UserID: id,
-		Email:  email,
-		Token:  token,
-		Used:   false,
+		UserID:    id,
+		Email:     email,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		RequestIP: ip,
+		Used:      false,
 	}
 
-	result := db.DB.Create(&resetPassword)
+	result = tx.Create(&resetPassword)
 	if result.Error != nil {
+		tx.Rollback()
 		return result.Error
 	}
+
+	tx.Commit()
 	return nil
 }
 
-func TokenIsUsed(token string) (bool, ResetPassword, error) {
+// ConsumeResetPassword valida el token y lo marca como utilizado, retorna el id del usuario.
+func ConsumeResetPassword(tokenHash string) (uint, error) {
 	var resetPassword ResetPassword
-	result := db.DB.Model(&ResetPassword{}).Where("token = ?", token).First(&resetPassword)
+	result := db.DB.Where("token_hash = ?", tokenHash).First(&resetPassword)
 	if result.Error != nil {
-		return false, resetPassword, result.Error
+		return 0, errors.New("el token no es valido")
 	}
+
 	if resetPassword.Used {
-		return true, resetPassword, errors.New("token already used")
+		return 0, errors.New("el token ya fue utilizado")
+	}
+
+	if time.Now().After(resetPassword.ExpiresAt) {
+		return 0, errors.New("el token ha expirado")
 	}
-	return false, resetPassword, nil
-}
 
-func SetTokenUsed(token string) error {
-	result := db.DB.Model(&ResetPassword{}).Where("token = ?", token).Update("used", true)
+	// solo una petición puede consumir el token.
+	result = db.DB.Model(&ResetPassword{}).Where("id = ? AND used = false", resetPassword.ID).Update("used", true)
 	if result.Error != nil {
-		return result.Error
+		return 0, result.Error
 	}
-	return nil
+	if result.RowsAffected == 0 {
+		return 0, errors.New("el token ya fue utilizado")
+	}
+
+	return resetPassword.UserID, nil
 }
@@ -0,0 +1,349 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Permisos que controlan el acceso a las rutas de la API.
+const (
+	PermModuleCreate       = "module.create"
+	PermModuleEdit         = "module.edit"
+	PermQuestionManage     = "question.manage"
+	PermTestTake           = "test.take"
+	PermClassManage        = "class.manage"
+	PermClassEnroll        = "class.enroll"
+	PermAIGenerate         = "ai.generate"
+	PermUsersManage        = "users.manage"
+	PermUsersApprove       = "users.approve"
+	PermRolesManage        = "roles.manage"
+	PermSecurityManage     = "security.manage"
+	PermTwoFactor          = "account.two_factor"
+	PermResourcesManageAll = "resources.manage_all"
+	PermGuardianView       = "guardian.view"
+	PermGuardianApprove    = "guardian.approve"
+	PermAuditView          = "audit.view"
+	PermAPIKeysManage      = "api_keys.manage"
+	PermGradesRead         = "grades.read"
+	PermUsersImpersonate   = "users.impersonate"
+	PermLearningPaths      = "learning_paths.manage"
+	PermUsersCreate        = "users.create"
+)
+
+// permissionCatalog permisos disponibles, se registran al iniciar la API.
+var permissionCatalog = map[string]string{
+	PermModuleCreate:       "Crear módulos",
+	PermModuleEdit:         "Editar los módulos propios",
+	PermQuestionManage:     "Gestionar las preguntas de los módulos propios",
+	PermTestTake:           "Realizar los test de los módulos",
+	PermClassManage:        "Crear y gestionar clases",
+	PermClassEnroll:        "Inscribirse en clases",
+	PermAIGenerate:         "Generar contenido con IA",
+	PermUsersManage:        "Administrar usuarios",
+	PermUsersApprove:       "Aprobar o rechazar solicitudes de acceso",
+	PermRolesManage:        "Administrar roles y permisos",
+	PermSecurityManage:     "Administrar las políticas de seguridad",
+	PermTwoFactor:          "Configurar la verificación en dos pasos",
+	PermResourcesManageAll: "Gestionar los recursos de cualquier usuario",
+	PermGuardianView:       "Consultar el progreso de los estudiantes vinculados",
+	PermGuardianApprove:    "Aprobar la vinculación de representantes con los estudiantes de sus clases",
+	PermAuditView:          "Consultar el registro de auditoría",
+	PermAPIKeysManage:      "Administrar las API keys de las integraciones",
+	PermGradesRead:         "Consultar las calificaciones de los módulos propios",
+	PermUsersImpersonate:   "Ver la aplicación como otro usuario para dar soporte",
+	PermLearningPaths:      "Crear rutas de aprendizaje con los módulos propios",
+	PermUsersCreate:        "Registrar usuarios desde un archivo en las clases propias",
+}
+
+// defaultRoles permisos de los roles base, se aplican al crear el rol o al registrar un permiso nuevo.
+var defaultRoles = map[TypeUser][]string{
+	Student: {PermTestTake, PermClassEnroll, PermAIGenerate},
+	Teacher: {PermModuleCreate, PermModuleEdit, PermQuestionManage, PermClassManage, PermAIGenerate, PermTwoFactor, PermGuardianApprove, PermGradesRead, PermLearningPaths, PermUsersCreate},
+	Admin: {
+		PermModuleCreate, PermModuleEdit, PermQuestionManage, PermClassManage, PermAIGenerate,
+		PermUsersManage, PermUsersApprove, PermRolesManage, PermSecurityManage, PermTwoFactor,
+		PermResourcesManageAll, PermGuardianApprove, PermAuditView, PermAPIKeysManage, PermGradesRead,
+		PermUsersImpersonate, PermLearningPaths, PermUsersCreate,
+	},
+	Guardian: {PermGuardianView},
+}
+
+var (
+	ErrRoleInUse = errors.New("el rol tiene usuarios asignados")
+	ErrBaseRole  = errors.New("los roles base no se pueden eliminar")
+)
+
+// Permission permiso con nombre que se asigna a los roles.
+type Permission struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex"`
+	Description string
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// Role rol de usuario con sus permisos, se identifica por el nombre que se guarda en type_user.
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex"`
+	Description string
+	Permissions []Permission `gorm:"many2many:role_permissions;"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// SeedRoles registra el catálogo de permisos y los roles base si no existen.
+// Los permisos nuevos se asignan a los roles base que los tienen por defecto,
+// los cambios que hizo un administrador sobre los permisos existentes se respetan.
+func SeedRoles() error {
+	roles := make(map[TypeUser]*Role)
+	createdRoles := make(map[TypeUser]bool)
+	for typeUser := range defaultRoles {
+		role := Role{Name: string(typeUser)}
+		result := db.DB.Where(Role{Name: role.Name}).FirstOrCreate(&role)
+		if result.Error != nil {
+			return result.Error
+		}
+		roles[typeUser] = &role
+		createdRoles[typeUser] = result.RowsAffected > 0
+	}
+
+	for name, description := range permissionCatalog {
+		permission := Permission{Name: name, Description: description}
+		result := db.DB.Where(Permission{Name: name}).FirstOrCreate(&permission)
+		if result.Error != nil {
+			return result.Error
+		}
+		createdPermission := result.RowsAffected > 0
+
+		for typeUser, permissions := range defaultRoles {
+			if !containsString(permissions, name) || !(createdPermission || createdRoles[typeUser]) {
+				continue
+			}
+			if err := db.DB.Model(roles[typeUser]).Association("Permissions").Append(&permission); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetRolePermissions recupera los nombres de los permisos del rol.
+func GetRolePermissions(name string) ([]string, error) {
+	var role Role
+	result := db.DB.Preload("Permissions").Where("name = ?", name).First(&role)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	return permissions, nil
+}
+
+// RoleExists indica si existe un rol con el nombre indicado.
+func RoleExists(name string) bool {
+	var count int64
+	db.DB.Model(&Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// GetRoles recupera todos los roles con sus permisos.
+func GetRoles() ([]types.Role, error) {
+	var roles []Role
+	result := db.DB.Preload("Permissions").Order("name").Find(&roles)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	rolesAPI := make([]types.Role, 0, len(roles))
+	for _, role := range roles {
+		rolesAPI = append(rolesAPI, RoleToAPI(role))
+	}
+	return rolesAPI, nil
+}
+
+// GetRoleByID recupera un rol con sus permisos.
+func GetRoleByID(id uint) (*types.Role, error) {
+	var role Role
+	result := db.DB.Preload("Permissions").First(&role, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	roleAPI := RoleToAPI(role)
+	return &roleAPI, nil
+}
+
+// GetPermissions recupera el catálogo de permisos.
+func GetPermissions() ([]types.Permission, error) {
+	var permissions []Permission
+	result := db.DB.Order("name").Find(&permissions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	permissionsAPI := make([]types.Permission, 0, len(permissions))
+	for _, permission := range permissions {
+		permissionsAPI = append(permissionsAPI, types.Permission{
+			Name:        permission.Name,
+			Description: permission.Description,
+		})
+	}
+	return permissionsAPI, nil
+}
+
+// CreateRole registra un nuevo rol con sus permisos.
+func CreateRole(roleAPI types.Role) (*types.Role, error) {
+	permissions, err := findPermissions(roleAPI.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := Role{
+		Name:        roleAPI.Name,
+		Description: roleAPI.Description,
+		Permissions: permissions,
+	}
+	result := db.DB.Create(&role)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	created := RoleToAPI(role)
+	return &created, nil
+}
+
+// UpdateRole actualiza la descripción y reemplaza los permisos del rol, el nombre no cambia.
+func UpdateRole(id uint, roleAPI types.Role) (*types.Role, error) {
+	var role Role
+	result := db.DB.First(&role, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	permissions, err := findPermissions(roleAPI.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	// si el rol exige la verificación en dos pasos no puede perder el permiso para configurarla.
+	if !containsString(roleAPI.Permissions, PermTwoFactor) {
+		var required int64
+		db.DB.Model(&TwoFactorPolicy{}).Where("type_user = ? AND required = true", role.Name).Count(&required)
+		if required > 0 {
+			return nil, ErrTwoFactorNotAllowed
+		}
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("description", roleAPI.Description).Error; err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	role.Permissions = permissions
+	updated := RoleToAPI(role)
+	return &updated, nil
+}
+
+// DeleteRole elimina un rol que no tenga usuarios asignados, los roles base no se eliminan.
+func DeleteRole(id uint) error {
+	var role Role
+	result := db.DB.First(&role, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if _, ok := defaultRoles[TypeUser(role.Name)]; ok {
+		return ErrBaseRole
+	}
+
+	var count int64
+	db.DB.Model(&User{}).Where("type_user = ?", role.Name).Count(&count)
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		// se elimina definitivamente para poder registrar otro rol con el mismo nombre.
+		return tx.Unscoped().Delete(&role).Error
+	})
+}
+
+// SetUserRole asigna un rol existente al usuario.
+func SetUserRole(userID uint, name string) error {
+	if !RoleExists(name) {
+		return errors.New("el rol no existe")
+	}
+
+	result := db.DB.Model(&User{}).Where("id = ?", userID).Update("type_user", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("el usuario no existe")
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func findPermissions(names []string) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	if len(names) == 0 {
+		return permissions, nil
+	}
+
+	unique := make(map[string]bool)
+	for _, name := range names {
+		unique[name] = true
+	}
+
+	result := db.DB.Where("name IN ?", names).Find(&permissions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(permissions) != len(unique) {
+		return nil, errors.New("uno o más permisos no existen")
+	}
+	return permissions, nil
+}
+
+func RoleToAPI(role Role) types.Role {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+
+	return types.Role{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+	}
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval evita escribir la última actividad en cada petición.
+const sessionTouchInterval = time.Minute
+
+// Session sesión iniciada por el usuario en un dispositivo, agrupa los refresh tokens que se rotan.
+type Session struct {
+	gorm.Model
+	UserID     uint
+	User       User
+	UserAgent  string
+	IP         string
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+func SessionToAPI(session Session, currentID uint) types.Session {
+	return types.Session{
+		ID:         session.ID,
+		Device:     utils.DescribeUserAgent(session.UserAgent),
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		CreatedAt:  utils.GetFullDate(session.CreatedAt),
+		LastSeenAt: utils.GetFullDate(session.LastSeenAt),
+		Current:    session.ID == currentID,
+	}
+}
+
+func SessionsToAPI(sessions []Session, currentID uint) []types.Session {
+	sessionsAPI := make([]types.Session, 0)
+	for _, session := range sessions {
+		sessionsAPI = append(sessionsAPI, SessionToAPI(session, currentID))
+	}
+	return sessionsAPI
+}
+
+// CreateSession registra una nueva sesión para el usuario.
+func CreateSession(userID uint, userAgent, ip string, expiresAt time.Time) (*Session, error) {
+	session := Session{
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+
+	result := db.DB.Create(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &session, nil
+}
+
+// TouchSession indica si la sesión del usuario sigue activa y actualiza su última actividad.
+func TouchSession(sessionID, userID uint) bool {
+	var session Session
+	result := db.DB.Select("id", "last_seen_at", "expires_at", "revoked_at").
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		First(&session)
+	if result.Error != nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return false
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		db.DB.Model(&Session{}).Where("id = ?", sessionID).Update("last_seen_at", time.Now())
+	}
+	return true
+}
+
+// GetUserSessions recupera las sesiones activas del usuario, la más reciente primero.
+func GetUserSessions(userID uint) ([]Session, error) {
+	var sessions []Session
+	result := db.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return sessions, nil
+}
+
+// RevokeSession cierra una sesión del usuario junto con sus refresh tokens.
+func RevokeSession(userID, sessionID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// RevokeUserSessions cierra todas las sesiones del usuario, exceptID permite conservar la sesión actual.
+func RevokeUserSessions(userID uint, exceptID uint) error {
+	return revokeUsersSessions(db.DB, []uint{userID}, exceptID)
+}
+
+// revokeUsersSessions cierra las sesiones y revoca los refresh tokens de los usuarios.
+func revokeUsersSessions(tx *gorm.DB, userIDs []uint, exceptID uint) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		sessions := tx.Model(&Session{}).Where("user_id IN ? AND revoked_at IS NULL", userIDs)
+		tokens := tx.Model(&RefreshToken{}).Where("user_id IN ? AND revoked_at IS NULL", userIDs)
+		if exceptID != 0 {
+			sessions = sessions.Where("id <> ?", exceptID)
+			tokens = tokens.Where("session_id IS NULL OR session_id <> ?", exceptID)
+		}
+
+		if err := sessions.Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tokens.Update("revoked_at", time.Now()).Error
+	})
+}
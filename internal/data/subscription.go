@@ -33,6 +33,9 @@ func RegisterSubscription(userID uint, code string) (Subscription, error) {
 		return Subscription{}, result.Error
 
 	}
+	if module.PublishedVersionID == nil {
+		return Subscription{}, ErrModuleNotPublished
+	}
 
 	sub := Subscription{
 		UserID:   userID,
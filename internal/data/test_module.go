@@ -11,21 +11,26 @@ import (
 
 type TestModule struct {
 	gorm.Model
-	UserID        uint
-	User          User
-	ModuleID      uint
-	Module        Module
-	Started       *time.Time
-	Finished      *time.Time
-	Qualification float32
+	UserID   uint
+	User     User
+	ModuleID uint
+	Module   Module
+	// ModuleVersionID versión publicada del módulo con la que se realizó el test.
+	ModuleVersionID *uint
+	ModuleVersion   *ModuleVersion
+	Started         *time.Time
+	Finished        *time.Time
+	Qualification   float32
 }
 
 func TestModuleToAPI(testModule TestModule) types.TestModule {
+	module := ModuleToApi(testModule.Module)
+	applyModuleVersion(&module, testModule.ModuleVersion)
 	return types.TestModule{
 		ID:                        testModule.ID,
 		CreatedAt:                 utils.GetFullDate(testModule.CreatedAt),
 		ModuleID:                  testModule.Module.ID,
-		Module:                    ModuleToApi(testModule.Module),
+		Module:                    module,
 		Started:                   utils.GetFullDateOrNull(testModule.Started),
 		Finished:                  utils.GetFullDateOrNull(testModule.Finished),
 		Qualification:             testModule.Qualification,
@@ -42,31 +47,44 @@ func TestsModuleToAPI(testsModules []TestModule) []types.TestModule {
 	return testModulesAPI
 }
 
+// GenerateTestForStudent crea un test con preguntas de la versión publicada del módulo,
+// el test queda ligado a esa versión aunque luego se publique otra.
 func GenerateTestForStudent(userid uint, moduleID uint) (testId uint, err error) {
 
+	var module Module
+	result := db.DB.Select("id", "published_version_id").First(&module, moduleID)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if module.PublishedVersionID == nil {
+		return 0, ErrModuleNotPublished
+	}
+
 	// crear el objeto test Module
 
 	tx := db.DB.Begin()
 	now := time.Now()
 	test := TestModule{
-		UserID:        userid,
-		ModuleID:      moduleID,
-		Started:       &now,
-		Finished:      nil,
-		Qualification: 0,
+		UserID:          userid,
+		ModuleID:        moduleID,
+		ModuleVersionID: module.PublishedVersionID,
+		Started:         &now,
+		Finished:        nil,
+		Qualification:   0,
 	}
 
 	// lo registramos en la base de datos.
-	result := tx.Create(&test)
+	result = tx.Create(&test)
 	if result.Error != nil {
 		tx.Rollback()
 		return 0, result.Error
 	}
 
-	// Seleccionamos 10 preguntas aleatorias del módulo.
-	questions, err := GenerateQuestions(moduleID, 10)
+	// Seleccionamos 10 preguntas aleatorias de la versión publicada.
+	questions, err := GenerateQuestions(*module.PublishedVersionID, 10)
 	if err != nil {
-		return 0, result.Error
+		tx.Rollback()
+		return 0, err
 	}
 
 	// Asignamos las preguntas asignadas a la respuesta del usuario, y el puntaje.
@@ -104,7 +122,7 @@ func TestByID(testid uint) (types.TestModule, error) {
 
 	var test TestModule
 
-	result := db.DB.Preload("Module.CreatedBy").Where("ID = ?", testid).Find(&test)
+	result := db.DB.Preload("Module.CreatedBy").Preload("ModuleVersion").Where("ID = ?", testid).Find(&test)
 	if result.Error != nil {
 		return types.TestModule{}, result.Error
 	}
@@ -116,11 +134,14 @@ func TestByID(testid uint) (types.TestModule, error) {
 		return types.TestModule{}, result.Error
 	}
 
+	module := ModuleToApi(test.Module)
+	applyModuleVersion(&module, test.ModuleVersion)
+
 	responseModuleTest := types.TestModule{
 		ID:            test.ID,
 		CreatedAt:     test.CreatedAt.Format("02/01/2006 15:04:05"),
 		ModuleID:      test.ModuleID,
-		Module:        ModuleToApi(test.Module),
+		Module:        module,
 		Started:       utils.GetFullDateOrNull(test.Started),
 		Finished:      utils.GetFullDateOrNull(test.Finished),
 		Qualification: test.Qualification,
@@ -182,7 +203,7 @@ func GetMyTest(userId, moduleId uint) ([]TestModule, error) {
 	// Recuperamos los datos de la db.
 	result := db.DB.
 		Where("user_id = ? and module_id = ?", userId, moduleId).
-		Preload("User").Preload("Module.CreatedBy").Find(&testsModule)
+		Preload("User").Preload("Module.CreatedBy").Preload("ModuleVersion").Find(&testsModule)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -190,3 +211,49 @@ func GetMyTest(userId, moduleId uint) ([]TestModule, error) {
 
 	return testsModule, nil
 }
+
+// GetModuleGrades recupera el resumen de los test finalizados de cada estudiante del módulo.
+func GetModuleGrades(moduleID uint) ([]types.ModuleGrade, error) {
+	var rows []struct {
+		UserID        uint
+		TestsFinished int
+		Best          float32
+		Average       float32
+		LastFinished  *time.Time
+	}
+	result := db.DB.Model(&TestModule{}).
+		Select("user_id, count(*) as tests_finished, max(qualification) as best, ROUND(avg(qualification)::numeric, 2) as average, max(finished) as last_finished").
+		Where("module_id = ? AND finished IS NOT NULL", moduleID).
+		Group("user_id").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	userIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.UserID)
+	}
+
+	var users []User
+	result = db.DB.Where("id IN ?", userIDs).Find(&users)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	usersByID := make(map[uint]User)
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	grades := make([]types.ModuleGrade, 0, len(rows))
+	for _, row := range rows {
+		grades = append(grades, types.ModuleGrade{
+			Student:              UserToAPI(usersByID[row.UserID]),
+			TestsFinished:        row.TestsFinished,
+			BestQualification:    row.Best,
+			AverageQualification: row.Average,
+			LastFinished:         utils.GetFullDateOrNull(row.LastFinished),
+		})
+	}
+	return grades, nil
+}
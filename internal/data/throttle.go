@@ -0,0 +1,44 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"time"
+)
+
+// ThrottleEvent registra un evento para limitar la cantidad de peticiones por clave (email, ip, etc).
+type ThrottleEvent struct {
+	ID        uint   `gorm:"primarykey"`
+	Key       string `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (ThrottleEvent) TableName() string {
+	return "throttle_events"
+}
+
+// RegisterThrottleEvent registra un nuevo evento para la clave.
+func RegisterThrottleEvent(key string) error {
+	result := db.DB.Create(&ThrottleEvent{Key: key})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// CountThrottleEvents cuenta los eventos de la clave dentro de la ventana de tiempo.
+func CountThrottleEvents(key string, window time.Duration) int64 {
+	var count int64
+	db.DB.Model(&ThrottleEvent{}).
+		Where("key = ? AND created_at > ?", key, time.Now().Add(-window)).
+		Count(&count)
+	return count
+}
+
+// ClearThrottleEvents elimina los eventos de la clave.
+func ClearThrottleEvents(key string) error {
+	result := db.DB.Where("key = ?", key).Delete(&ThrottleEvent{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecoveryCode código de recuperación de un solo uso para la verificación en dos pasos.
+// Solo se guarda el hash del código.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint
+	User     User
+	CodeHash string `gorm:"index"`
+	UsedAt   *time.Time
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// TwoFactorPolicy indica si la verificación en dos pasos es obligatoria para un rol.
+type TwoFactorPolicy struct {
+	gorm.Model
+	TypeUser TypeUser `gorm:"uniqueIndex"`
+	Required bool
+}
+
+func (TwoFactorPolicy) TableName() string {
+	return "two_factor_policies"
+}
+
+// SetTwoFactorSecret guarda el secreto TOTP mientras el usuario confirma la activación.
+func SetTwoFactorSecret(userID uint, secret string) error {
+	var user User
+	result := db.DB.Select("two_factor_enabled").First(&user, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if user.TwoFactorEnabled {
+		return errors.New("la verificación en dos pasos ya está activada")
+	}
+
+	result = db.DB.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_secret":    secret,
+		"two_factor_last_step": 0,
+	})
+	return result.Error
+}
+
+// GetTwoFactorSecret recupera el secreto TOTP del usuario y si está activado.
+func GetTwoFactorSecret(userID uint) (string, bool, error) {
+	var user User
+	result := db.DB.Select("two_factor_secret", "two_factor_enabled").First(&user, userID)
+	if result.Error != nil {
+		return "", false, result.Error
+	}
+	return user.TwoFactorSecret, user.TwoFactorEnabled, nil
+}
+
+// UseTwoFactorStep registra el paso de tiempo utilizado, retorna falso si el código ya fue utilizado.
+func UseTwoFactorStep(userID uint, step int64) bool {
+	result := db.DB.Model(&User{}).
+		Where("id = ? AND two_factor_last_step < ?", userID, step).
+		Update("two_factor_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// EnableTwoFactor activa la verificación en dos pasos y registra los códigos de recuperación.
+func EnableTwoFactor(userID uint, codeHashes []string) error {
+	tx := db.DB.Begin()
+
+	result := tx.Model(&User{}).Where("id = ?", userID).Update("two_factor_enabled", true)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// DisableTwoFactor desactiva la verificación en dos pasos y elimina los códigos de recuperación.
+func DisableTwoFactor(userID uint) error {
+	tx := db.DB.Begin()
+
+	result := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":   false,
+		"two_factor_secret":    "",
+		"two_factor_last_step": 0,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+
+	result = tx.Where("user_id = ?", userID).Delete(&RecoveryCode{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// ReplaceRecoveryCodes reemplaza los códigos de recuperación del usuario.
+func ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	tx := db.DB.Begin()
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	result := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	codes := make([]RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseRecoveryCode marca el código de recuperación como utilizado, retorna falso si no es valido.
+func UseRecoveryCode(userID uint, codeHash string) bool {
+	result := db.DB.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// IsTwoFactorRequired indica si el rol tiene la verificación en dos pasos obligatoria.
+func IsTwoFactorRequired(typeUser string) bool {
+	var policy TwoFactorPolicy
+	result := db.DB.Where("type_user = ?", typeUser).First(&policy)
+	if result.Error != nil {
+		return false
+	}
+	return policy.Required
+}
+
+// ErrTwoFactorNotAllowed el rol no tiene el permiso para configurar la verificación en dos pasos.
+var ErrTwoFactorNotAllowed = errors.New("el rol no tiene el permiso " + PermTwoFactor + ", sus usuarios no podrían activar la verificación en dos pasos")
+
+// SetTwoFactorPolicy establece si la verificación en dos pasos es obligatoria para un rol. Solo se puede exigir a los
+// roles con el permiso account.two_factor, de lo contrario sus usuarios quedarían sin acceso.
+func SetTwoFactorPolicy(typeUser string, required bool) error {
+	if required {
+		permissions, err := GetRolePermissions(typeUser)
+		if err != nil {
+			return err
+		}
+		if !containsString(permissions, PermTwoFactor) {
+			return ErrTwoFactorNotAllowed
+		}
+	}
+
+	policy := TwoFactorPolicy{
+		TypeUser: TypeUser(typeUser),
+		Required: required,
+	}
+	result := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "type_user"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required", "updated_at"}),
+	}).Create(&policy)
+	return result.Error
+}
+
+// GetTwoFactorPolicies recupera las políticas de verificación en dos pasos por rol.
+func GetTwoFactorPolicies() ([]types.TwoFactorPolicy, error) {
+	var policies []TwoFactorPolicy
+	result := db.DB.Order("type_user").Find(&policies)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	policiesAPI := make([]types.TwoFactorPolicy, 0)
+	for _, policy := range policies {
+		policiesAPI = append(policiesAPI, types.TwoFactorPolicy{
+			TypeUser: string(policy.TypeUser),
+			Required: policy.Required,
+		})
+	}
+	return policiesAPI, nil
+}
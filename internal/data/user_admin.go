@@ -0,0 +1,164 @@
+package data
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/db"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// columnas por las que se pueden ordenar los usuarios.
+var userSortColumns = map[string]bool{
+	"id":            true,
+	"first_name":    true,
+	"last_name":     true,
+	"email":         true,
+	"status":        true,
+	"type_user":     true,
+	"points_earned": true,
+	"created_at":    true,
+}
+
+// GetUsers recupera los usuarios paginados aplicando los filtros de la administración.
+func GetUsers(paginated *types.Paginated, filter *types.UserFilter) ([]User, *types.PagintaedDetails, error) {
+	var users []User
+	var paginatedDetails types.PagintaedDetails
+
+	order, err := usersOrder(paginated)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := usersQuery(paginated, filter).Count(&paginatedDetails.TotalItems)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	paginatedDetails.Page = paginated.Page
+	paginatedDetails.TotalPage = int64(math.Ceil(float64(paginatedDetails.TotalItems) / float64(paginated.Limit)))
+
+	result = usersQuery(paginated, filter).
+		Order(order).
+		Limit(paginated.Limit).
+		Offset((paginated.Page - 1) * paginated.Limit).
+		Find(&users)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	paginatedDetails.ItemsPerPage = len(users)
+
+	return users, &paginatedDetails, nil
+}
+
+// GetUsersForExport recupera todos los usuarios que cumplen los filtros, sin paginar.
+func GetUsersForExport(paginated *types.Paginated, filter *types.UserFilter) ([]User, error) {
+	var users []User
+
+	order, err := usersOrder(paginated)
+	if err != nil {
+		return nil, err
+	}
+
+	result := usersQuery(paginated, filter).Order(order).Find(&users)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return users, nil
+}
+
+// ChangeStatusBulk activa o bloquea varios usuarios. Solo se desbloquean los usuarios bloqueados y se bloquean los
+// activos, los pendientes de aprobación o de verificación pasan por sus propios flujos. Retorna los ids actualizados
+// y los que se omitieron.
+func ChangeStatusBulk(userIDs []uint, active bool) (updated []uint, skipped []uint, err error) {
+	from, to := Blocked, Actived
+	if !active {
+		from, to = Actived, Blocked
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var users []User
+		result := tx.Select("id").Where("id IN ? AND status = ?", userIDs, from).Find(&users)
+		if result.Error != nil {
+			return result.Error
+		}
+		updated = make([]uint, 0, len(users))
+		for _, user := range users {
+			updated = append(updated, user.ID)
+		}
+		if len(updated) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&User{}).Where("id IN ?", updated).Update("status", to).Error; err != nil {
+			return err
+		}
+
+		if active {
+			return nil
+		}
+		// al bloquear los usuarios se revocan sus sesiones y sus módulos pasan a sus editores.
+		if err := revokeUsersSessions(tx, updated, 0); err != nil {
+			return err
+		}
+		for _, id := range updated {
+			if err := transferModulesOnLeave(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed := make(map[uint]bool, len(updated))
+	for _, id := range updated {
+		changed[id] = true
+	}
+	skipped = make([]uint, 0)
+	for _, id := range userIDs {
+		if !changed[id] {
+			skipped = append(skipped, id)
+		}
+	}
+	return updated, skipped, nil
+}
+
+func usersQuery(paginated *types.Paginated, filter *types.UserFilter) *gorm.DB {
+	tx := db.DB.Model(&User{})
+
+	if filter.TypeUser != "" {
+		tx = tx.Where("type_user = ?", filter.TypeUser)
+	}
+	if filter.Status != "" {
+		tx = tx.Where("status = ?", filter.Status)
+	}
+	if paginated.Query != "" {
+		search := "%" + paginated.Query + "%"
+		tx = tx.Where("first_name ILIKE ? OR last_name ILIKE ? OR email ILIKE ? OR CONCAT(first_name, ' ', last_name) ILIKE ?",
+			search, search, search, search)
+	}
+	if from, _ := utils.ParseDateOrNull(filter.From); from != nil {
+		tx = tx.Where("created_at >= ?", *from)
+	}
+	if to, _ := utils.ParseDateOrNull(filter.To); to != nil {
+		tx = tx.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+	return tx
+}
+
+func usersOrder(paginated *types.Paginated) (string, error) {
+	if !userSortColumns[paginated.Sort] {
+		return "", fmt.Errorf("columna inexistente: %s", paginated.Sort)
+	}
+
+	order := strings.ToLower(paginated.Order)
+	if order != "asc" && order != "desc" {
+		return "", fmt.Errorf("el orden debe ser asc o desc")
+	}
+	return fmt.Sprintf("%s %s, id asc", paginated.Sort, order), nil
+}
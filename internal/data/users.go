@@ -6,6 +6,7 @@ import (
 	"Proyectos-UTEQ/api-ortografia/pkg/types"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
@@ -28,22 +29,34 @@ type User struct {
 	Status               Status
 	TypeUser             TypeUser
 	PerfilUpdateRequired bool
+	GoogleID             string `gorm:"index"`
+	FailedLoginAttempts  int
+	LastFailedLoginAt    *time.Time
+	LockedUntil          *time.Time
+	TwoFactorEnabled     bool
+	TwoFactorSecret      string
+	TwoFactorLastStep    int64
+	DeletionScheduledAt  *time.Time
 }
 
 type Status string
 
 const (
-	Actived         Status = "actived"
-	Blocked         Status = "blocked"
-	PendingApproval Status = "pending_approval"
+	Actived             Status = "actived"
+	Blocked             Status = "blocked"
+	PendingApproval     Status = "pending_approval"
+	PendingVerification Status = "pending_verification"
+	Rejected            Status = "rejected"
+	Deleted             Status = "deleted"
 )
 
 type TypeUser string
 
 const (
-	Admin   TypeUser = "admin"
-	Student TypeUser = "student"
-	Teacher TypeUser = "teacher"
+	Admin    TypeUser = "admin"
+	Student  TypeUser = "student"
+	Teacher  TypeUser = "teacher"
+	Guardian TypeUser = "guardian"
 )
 
 func (User) TableName() string {
@@ -62,25 +75,25 @@ func UserToAPI(user User) *types.UserAPI {
 		Whatsapp:             user.Whatsapp,
 		Telegram:             user.Telegram,
 		TelegramID:           user.TelegramID,
-		URLAvatar:            user.URLAvatar,
+		URLAvatar:            AvatarURL(user.ID, user.URLAvatar),
 		Status:               string(user.Status),
 		TypeUser:             string(user.TypeUser),
 		PerfilUpdateRequired: user.PerfilUpdateRequired,
+		TwoFactorEnabled:     user.TwoFactorEnabled,
+		DeletionScheduledAt:  utils.GetFullDateOrNull(user.DeletionScheduledAt),
 	}
 }
 
 func UsersToAPI(users []User) []types.UserAPI {
-	var usersApi []types.UserAPI
+	usersApi := make([]types.UserAPI, 0, len(users))
 	for _, user := range users {
-		if user.URLAvatar == "" {
-			user.URLAvatar = fmt.Sprintf("https://ui-avatars.com/api/?name=%s&background=5952A2&color=fff&size=128", user.FirstName)
-		}
 		usersApi = append(usersApi, *UserToAPI(user))
 	}
 	return usersApi
 }
 
-func Login(login types.Login) (*types.UserAPI, bool, error) {
+// Login autentica al usuario aplicando la política de intentos fallidos.
+func Login(login types.Login, policy LoginPolicy) (*types.UserAPI, bool, error) {
 	var user User
 	result := db.DB.First(&user, "email = ?", login.Email)
 
@@ -89,34 +102,60 @@ func Login(login types.Login) (*types.UserAPI, bool, error) {
 		return nil, false, errors.New("Las credenciales son incorrectas")
 	}
 
+	// Revisamos si la cuenta está bloqueada o debe esperar.
+	if err := checkLoginAllowed(&user, policy); err != nil {
+		return nil, false, err
+	}
+
 	// Comparar las contraseñas con un hash.
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(login.Password))
 	if err != nil {
+		if err := registerFailedLogin(&user, policy); err != nil {
+			return nil, false, err
+		}
 		return nil, false, errors.New("Las credenciales son incorrectas")
 	}
 
+	if err := resetFailedLogins(&user); err != nil {
+		return nil, false, err
+	}
+
+	if user.Status == PendingVerification {
+		return nil, false, errors.New("Debes verificar tu correo electrónico antes de iniciar sesión")
+	}
+
 	if user.Status == PendingApproval {
 		return nil, false, errors.New("El usuario no ha sido aprobado")
 	}
 
+	if user.Status == Rejected {
+		return nil, false, errors.New("La solicitud del usuario fue rechazada")
+	}
+
 	if user.Status == Blocked {
 		return nil, false, errors.New("El usuario ha sido bloqueado")
 	}
 
+	if user.Status == Deleted {
+		return nil, false, errors.New("Las credenciales son incorrectas")
+	}
+
 	// Convertir a un usuario api
 	userAPI := &types.UserAPI{
-		ID:           user.ID,
-		FirstName:    user.FirstName,
-		LastName:     user.LastName,
-		Email:        user.Email,
-		Password:     "",
-		BirthDate:    utils.GetDate(user.BirthDate),
-		PointsEarned: user.PointsEarned,
-		Whatsapp:     user.Whatsapp,
-		Telegram:     user.Telegram,
-		URLAvatar:    user.URLAvatar,
-		Status:       string(user.Status),
-		TypeUser:     string(user.TypeUser),
+		ID:                  user.ID,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		Email:               user.Email,
+		Password:            "",
+		BirthDate:           utils.GetDate(user.BirthDate),
+		PointsEarned:        user.PointsEarned,
+		Whatsapp:            user.Whatsapp,
+		Telegram:            user.Telegram,
+		URLAvatar:           AvatarURL(user.ID, user.URLAvatar),
+		Status:              string(user.Status),
+		TypeUser:            string(user.TypeUser),
+		TwoFactorEnabled:    user.TwoFactorEnabled,
+		DeletionScheduledAt: utils.GetFullDateOrNull(user.DeletionScheduledAt),
 	}
 
 	return userAPI, true, nil
@@ -130,11 +169,8 @@ func Register(userAPI *types.UserAPI) (*User, error) {
 		return nil, err
 	}
 
-	// en caso de ser admin o profesor se pone en pendiente de aprobacion.
-	status := Actived
-	if userAPI.TypeUser == "admin" || userAPI.TypeUser == "teacher" {
-		status = PendingApproval
-	}
+	// el usuario debe verificar su correo antes de continuar.
+	status := PendingVerification
 
 	// rellenamos los datos con la entidad.
 	user := User{
@@ -162,6 +198,81 @@ func Register(userAPI *types.UserAPI) (*User, error) {
 	return &user, nil
 }
 
+// initialStatus estado del usuario con el correo verificado, los estudiantes y representantes quedan activos,
+// los demás roles (admin, profesor y los creados desde /api/roles) se ponen en pendiente de aprobacion.
+func initialStatus(typeUser TypeUser) Status {
+	if typeUser == Student || typeUser == Guardian {
+		return Actived
+	}
+	return PendingApproval
+}
+
+// ErrGoogleEmailNotVerified Google no verificó el correo, no se puede vincular ni registrar la cuenta.
+var ErrGoogleEmailNotVerified = errors.New("el correo de la cuenta de Google no está verificado")
+
+// FindOrCreateGoogleUser recupera el usuario vinculado a la cuenta de Google, si no existe
+// se vincula por el email o se registra un nuevo usuario con las mismas reglas de aprobación del registro.
+// Solo se vincula o registra si Google verificó el correo.
+// requested indica si el usuario quedó pendiente de aprobación en esta llamada.
+func FindOrCreateGoogleUser(googleUser types.GoogleUser) (user *User, requested bool, err error) {
+	user = &User{}
+
+	// usuario ya vinculado con Google.
+	result := db.DB.First(user, "google_id = ?", googleUser.GoogleID)
+	if result.Error == nil {
+		return user, false, nil
+	}
+
+	// sin el correo verificado cualquiera podría tomar la cuenta registrada con ese email.
+	if !googleUser.EmailVerified {
+		return nil, false, ErrGoogleEmailNotVerified
+	}
+
+	// usuario registrado con el mismo email, lo vinculamos.
+	result = db.DB.First(user, "LOWER(email) = ?", strings.ToLower(googleUser.Email))
+	if result.Error == nil {
+		updates := map[string]interface{}{"google_id": googleUser.GoogleID}
+		if user.URLAvatar == "" {
+			updates["url_avatar"] = googleUser.URLAvatar
+		}
+		// Google ya verificó el correo.
+		if user.Status == PendingVerification {
+			user.Status = initialStatus(user.TypeUser)
+			updates["status"] = user.Status
+			requested = user.Status == PendingApproval
+		}
+		result = db.DB.Model(user).Updates(updates)
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		return user, requested, nil
+	}
+
+	// nuevo usuario, no tiene contraseña por lo que solo puede ingresar con Google.
+	typeUser := TypeUser(googleUser.TypeUser)
+	if typeUser == "" || !RoleExists(string(typeUser)) {
+		typeUser = Student
+	}
+
+	user = &User{
+		FirstName:            googleUser.FirstName,
+		LastName:             googleUser.LastName,
+		Email:                googleUser.Email,
+		URLAvatar:            googleUser.URLAvatar,
+		Status:               initialStatus(typeUser),
+		TypeUser:             typeUser,
+		PerfilUpdateRequired: true,
+		GoogleID:             googleUser.GoogleID,
+	}
+
+	result = db.DB.Create(user)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	return user, user.Status == PendingApproval, nil
+}
+
 func ExisteEmail(email string) (bool, types.UserAPI) {
 	var user User
 	result := db.DB.First(&user, "email = ?", email)
@@ -236,10 +347,12 @@ func GetUserByID(id uint) (*types.UserAPI, error) {
 		Whatsapp:             user.Whatsapp,
 		Telegram:             user.Telegram,
 		TelegramID:           user.TelegramID,
-		URLAvatar:            user.URLAvatar,
+		URLAvatar:            AvatarURL(user.ID, user.URLAvatar),
 		Status:               string(user.Status),
 		TypeUser:             string(user.TypeUser),
 		PerfilUpdateRequired: user.PerfilUpdateRequired,
+		TwoFactorEnabled:     user.TwoFactorEnabled,
+		DeletionScheduledAt:  utils.GetFullDateOrNull(user.DeletionScheduledAt),
 	}, nil
 }
 
@@ -257,13 +370,14 @@ func UpdateUserByID(user types.UserAPI, userid uint) error {
 		return errors.New("la fecha de nacimiento es inválida")
 	}
 
+	// los avatares generados no se guardan, así el usuario sin foto sigue usando sus iniciales.
 	result := db.DB.Model(&User{}).Where("id = ?", userid).Updates(User{
 		FirstName:            user.FirstName,
 		LastName:             user.LastName,
 		BirthDate:            birth,
 		Whatsapp:             user.Whatsapp,
 		Telegram:             user.Telegram,
-		URLAvatar:            user.URLAvatar,
+		URLAvatar:            NormalizeAvatarURL(user.URLAvatar),
 		PerfilUpdateRequired: false,
 	})
 	if result.Error != nil {
@@ -272,16 +386,6 @@ func UpdateUserByID(user types.UserAPI, userid uint) error {
 	return nil
 }
 
-func GetAllUsers() ([]types.UserAPI, error) {
-	var users []User
-	result := db.DB.Order("status desc").Find(&users)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-
-	return UsersToAPI(users), nil
-}
-
 func ChangeStatus(userID uint, active bool) error {
 
 	status := Actived
@@ -289,9 +393,68 @@ func ChangeStatus(userID uint, active bool) error {
 		status = Blocked
 	}
 
-	result := db.DB.Model(&User{}).Where("id = ?", userID).Update("status", status)
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", userID).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if active {
+			return nil
+		}
+		// al bloquear el usuario se revocan sus sesiones y sus módulos pasan a sus editores.
+		if err := revokeUsersSessions(tx, []uint{userID}, 0); err != nil {
+			return err
+		}
+		return transferModulesOnLeave(tx, userID)
+	})
+}
+
+// IsUserActive revisa si el usuario sigue activo, se utiliza para revocar
+// el acceso de inmediato cuando un usuario es bloqueado.
+func IsUserActive(userID uint) bool {
+	var user User
+	result := db.DB.Select("status").First(&user, "id = ?", userID)
 	if result.Error != nil {
-		return result.Error
+		return false
 	}
-	return nil
+	return user.Status == Actived
+}
+
+// RegisterImportedUser registra un estudiante importado desde una lista de clase,
+// el correo se considera verificado por la institución.
+func RegisterImportedUser(userAPI *types.UserAPI) (*User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userAPI.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := User{
+		FirstName:            userAPI.FirstName,
+		LastName:             userAPI.LastName,
+		Email:                userAPI.Email,
+		Password:             string(hashedPassword),
+		Whatsapp:             userAPI.Whatsapp,
+		Telegram:             userAPI.Telegram,
+		Status:               Actived,
+		TypeUser:             Student,
+		PerfilUpdateRequired: true,
+	}
+
+	if birthDate, err := utils.ParseDateOrNull(userAPI.BirthDate); err == nil && birthDate != nil {
+		user.BirthDate = *birthDate
+	}
+
+	result := db.DB.Create(&user)
+	if result.Error != nil {
+		if pgerr, ok := result.Error.(*pgconn.PgError); ok {
+			if pgerr.Code == "23505" {
+				return nil, errors.New("el email ya existe")
+			}
+		}
+		return nil, result.Error
+	}
+
+	userAPI.ID = user.ID
+	return &user, nil
 }
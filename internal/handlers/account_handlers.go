@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+type AccountHandler struct {
+	config *viper.Viper
+}
+
+// NewAccountHandler crea un nuevo handler para la exportación y eliminación de los datos del usuario.
+func NewAccountHandler(config *viper.Viper) *AccountHandler {
+	return &AccountHandler{
+		config: config,
+	}
+}
+
+// ExportData entrega un zip con los datos vinculados al usuario en archivos JSON.
+func (h *AccountHandler) ExportData(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	export, err := data.GetUserDataExport(claims.UserAPI.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	files := map[string]interface{}{
+		"profile.json":        export.Profile,
+		"tests.json":          export.Tests,
+		"subscriptions.json":  export.Subscriptions,
+		"enrollments.json":    export.Enrollments,
+		"chats.json":          export.Chats,
+		"guardian_links.json": export.GuardianLinks,
+		"sessions.json":       export.Sessions,
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(content); err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="poliword_%d_%s.zip"`, claims.UserAPI.ID, time.Now().Format("20060102")))
+	return c.Send(buffer.Bytes())
+}
+
+// RequestDeletion programa la eliminación de la cuenta, se anonimiza al terminar el periodo de gracia.
+func (h *AccountHandler) RequestDeletion(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqAccountDeletion
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	hasPassword, err := data.HasPassword(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if hasPassword {
+		if !data.CheckUserPassword(claims.UserAPI.ID, req.Password) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "La contraseña es incorrecta",
+			})
+		}
+	} else {
+		// las cuentas de Google no tienen contraseña, se confirma con el token enviado al correo.
+		if req.Token == "" {
+			return h.sendDeletionConfirmation(c, claims.UserAPI.ID)
+		}
+		if !data.UseDeletionConfirmation(claims.UserAPI.ID, utils.HashToken(req.Token)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "El token de confirmación no es valido o ha expirado",
+			})
+		}
+	}
+
+	scheduledAt := time.Now().Add(h.config.GetDuration("APP_ACCOUNT_DELETION_GRACE"))
+	user, err := data.RequestAccountDeletion(claims.UserAPI.ID, scheduledAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	message := fmt.Sprintf("Hola, %s. Recibimos tu solicitud para eliminar tu cuenta de Poliword, tus datos se eliminarán el %s. Si no fuiste tú o cambiaste de opinión, inicia sesión y cancela la eliminación antes de esa fecha.",
+		user.FirstName, utils.GetFullDate(scheduledAt))
+	go notifyUser(h.config, user.Email, user.TelegramID, "Eliminación de tu cuenta", message)
+
+	return c.JSON(fiber.Map{
+		"status":                "success",
+		"deletion_scheduled_at": utils.GetFullDate(scheduledAt),
+	})
+}
+
+// CancelDeletion cancela la eliminación programada de la cuenta.
+func (h *AccountHandler) CancelDeletion(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	err := data.CancelAccountDeletion(claims.UserAPI.ID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// sendDeletionConfirmation envía al correo el token para confirmar la eliminación de una cuenta sin contraseña.
+func (h *AccountHandler) sendDeletionConfirmation(c *fiber.Ctx, userID uint) error {
+	user, err := data.GetUserByID(userID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	token, hash, err := utils.GenerateToken()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	ttl := h.config.GetDuration("APP_ACCOUNT_DELETION_TOKEN_TTL")
+	if err := data.SaveDeletionConfirmation(userID, hash, time.Now().Add(ttl)); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	message := fmt.Sprintf("Hola, %s. Para confirmar la eliminación de tu cuenta de Poliword ingresa el siguiente código en los próximos %s: %s. Si no fuiste tú, ignora este mensaje.",
+		user.FirstName, ttl, token)
+	go notifyUser(h.config, user.Email, user.TelegramID, "Confirma la eliminación de tu cuenta", message)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":                "success",
+		"confirmation_required": true,
+		"message":               "Te enviamos un código a tu correo para confirmar la eliminación de la cuenta",
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	config *viper.Viper
+}
+
+// NewAPIKeyHandler crea un nuevo handler para la administración de las API keys.
+func NewAPIKeyHandler(config *viper.Viper) *APIKeyHandler {
+	return &APIKeyHandler{
+		config: config,
+	}
+}
+
+// GetAPIKeys lista las API keys con su uso, la key completa no se vuelve a mostrar.
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	keys, err := data.GetAPIKeys()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(data.APIKeysToAPI(keys))
+}
+
+// CreateAPIKey crea una API key para una integración, la key solo se entrega en esta respuesta.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqAPIKey
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
+		})
+	}
+
+	expiresAt, err := req.Expiration()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	key, secret, err := data.CreateAPIKey(req.Name, req.Scopes, expiresAt, claims)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	keyAPI := data.APIKeyToAPI(*key)
+	recordAudit(c, data.AuditAPIKeyCreated, "api_key", key.ID, nil, keyAPI)
+
+	keyAPI.Key = secret
+	return c.Status(fiber.StatusCreated).JSON(keyAPI)
+}
+
+// RevokeAPIKey revoca una API key.
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	err = data.RevokeAPIKey(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "La API key no existe o ya fue revocada",
+			})
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if key, err := data.GetAPIKeyByID(uint(id)); err == nil {
+		recordAudit(c, data.AuditAPIKeyRevoked, "api_key", key.ID, nil, data.APIKeyToAPI(*key))
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
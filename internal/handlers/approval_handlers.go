@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/services"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+type ApprovalHandler struct {
+	config *viper.Viper
+}
+
+// NewApprovalHandler crea un nuevo handler para la cola de aprobaciones.
+func NewApprovalHandler(config *viper.Viper) *ApprovalHandler {
+	return &ApprovalHandler{
+		config: config,
+	}
+}
+
+// GetPendingApprovals lista los profesores y administradores pendientes de aprobación.
+func (h *ApprovalHandler) GetPendingApprovals(c *fiber.Ctx) error {
+	var paginated types.Paginated
+	if err := c.QueryParser(&paginated); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	_ = paginated.Validate()
+
+	var filter types.ApprovalFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	if err := filter.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	users, details, err := data.GetPendingApprovals(&paginated, &filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    data.UsersToAPI(users),
+		"details": details,
+	})
+}
+
+// ApproveUser aprueba la solicitud de un profesor o administrador.
+func (h *ApprovalHandler) ApproveUser(c *fiber.Ctx) error {
+	return h.decide(c, true)
+}
+
+// RejectUser rechaza la solicitud de un profesor o administrador, el motivo es obligatorio.
+func (h *ApprovalHandler) RejectUser(c *fiber.Ctx) error {
+	return h.decide(c, false)
+}
+
+// GetApprovalHistory recupera el historial de decisiones de un usuario.
+func (h *ApprovalHandler) GetApprovalHistory(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	decisions, err := data.GetApprovalHistory(uint(userID))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(data.ApprovalDecisionsToAPI(decisions))
+}
+
+func (h *ApprovalHandler) decide(c *fiber.Ctx, approved bool) error {
+	claims := utils.GetClaims(c)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqApprovalDecision
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !approved && req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El motivo del rechazo es requerido",
+		})
+	}
+
+	before := data.AuditUserStates(uint(userID))
+
+	user, err := data.DecideApproval(uint(userID), claims.UserAPI.ID, approved, req.Reason)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	action := data.AuditUserApproved
+	if !approved {
+		action = data.AuditUserRejected
+	}
+	recordAudit(c, action, "user", user.ID, before[user.ID], data.AuditUserStates(user.ID)[user.ID])
+
+	// notificamos al solicitante.
+	message := fmt.Sprintf("Hola, %s. Tu solicitud de acceso a Poliword fue aprobada, ya puedes iniciar sesión.", user.FirstName)
+	if !approved {
+		message = fmt.Sprintf("Hola, %s. Tu solicitud de acceso a Poliword fue rechazada. Motivo: %s", user.FirstName, req.Reason)
+	} else if req.Reason != "" {
+		message += " " + req.Reason
+	}
+	notifyUser(h.config, user.Email, user.TelegramID, "Solicitud de acceso a Poliword", message)
+
+	return c.JSON(fiber.Map{"status": "success", "data": data.UserToAPI(*user)})
+}
+
+// notifyUser envía una notificación al usuario por correo electrónico y por Telegram si lo tiene vinculado.
+func notifyUser(config *viper.Viper, email string, telegramID int64, subject string, message string) {
+	emailNotifier := services.NewEmailNotifier(config, []string{email}, subject)
+	if err := utils.SendNotification(emailNotifier, message); err != nil {
+		log.Println("Error al enviar el correo", err)
+	}
+
+	if telegramID != 0 {
+		telegramNotifier := services.NewTelegramNotifier(config, telegramID)
+		if err := utils.SendNotification(telegramNotifier, message); err != nil {
+			log.Println("Error al enviar el mensaje de Telegram", err)
+		}
+	}
+}
+
+// notifyAdminsPendingApproval notifica a los administradores que hay una nueva solicitud de aprobación.
+func notifyAdminsPendingApproval(config *viper.Viper, user *data.User) {
+	admins, err := data.GetActiveAdmins()
+	if err != nil {
+		log.Println("Error al recuperar los administradores", err)
+		return
+	}
+
+	message := fmt.Sprintf("%s %s (%s) solicita acceso como %s y está pendiente de aprobación.",
+		user.FirstName, user.LastName, user.Email, user.TypeUser)
+	for i := range admins {
+		notifyUser(config, admins[i].Email, admins[i].TelegramID, "Nueva solicitud de aprobación", message)
+	}
+}
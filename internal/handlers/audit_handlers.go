@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+type AuditHandler struct {
+	config *viper.Viper
+}
+
+// NewAuditHandler crea un nuevo handler para la consulta de la auditoría.
+func NewAuditHandler(config *viper.Viper) *AuditHandler {
+	return &AuditHandler{
+		config: config,
+	}
+}
+
+// GetAuditLogs lista los registros de auditoría, se pueden filtrar por actor, acción, entidad y fechas.
+func (h *AuditHandler) GetAuditLogs(c *fiber.Ctx) error {
+	var paginated types.Paginated
+	if err := c.QueryParser(&paginated); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	_ = paginated.Validate()
+
+	var filter types.AuditFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	if err := filter.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	logs, details, err := data.GetAuditLogs(&paginated, &filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    data.AuditLogsToAPI(logs),
+		"details": details,
+	})
+}
+
+// recordAudit registra la acción del usuario autenticado, un error al registrar no detiene la petición.
+// Al suplantar a un usuario la acción se registra a nombre del administrador.
+func recordAudit(c *fiber.Ctx, action, entityType string, entityID uint, before, after interface{}) {
+	claims := utils.GetClaims(c)
+
+	entry := data.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		IP:         c.IP(),
+	}
+
+	actorID := claims.UserAPI.ID
+	if claims.IsImpersonated() {
+		onBehalfOfID := claims.UserAPI.ID
+		actorID = claims.Impersonator.ID
+		entry.OnBehalfOfID = &onBehalfOfID
+	}
+	entry.ActorID = &actorID
+	if claims.APIKeyID != 0 {
+		apiKeyID := claims.APIKeyID
+		entry.APIKeyID = &apiKeyID
+	}
+
+	if err := data.RecordAudit(entry, before, after); err != nil {
+		log.Println("Error al registrar la auditoría", action, entityType, entityID, err)
+	}
+}
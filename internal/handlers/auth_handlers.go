@@ -1,20 +1,28 @@
 package handlers
 
 import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/google"
 	"github.com/spf13/viper"
-	"net/http"
 )
 
 const (
-	key    = "random-key"
 	MaxAge = 86400 * 30
-	IsProd = false
+
+	// cookie donde se guarda el tipo de usuario solicitado al registrarse con Google.
+	typeUserCookie = "poliword_type_user"
 )
 
 type AuthHandler struct {
@@ -31,45 +39,137 @@ func (h *AuthHandler) ConfigProvider() {
 
 	googleClientID := h.config.GetString("GOOGLE_CLIENT_ID")
 	googleClientSecret := h.config.GetString("GOOGLE_CLIENT_SECRET")
+	googleCallbackURL := h.config.GetString("GOOGLE_CALLBACK_URL")
 
-	store := sessions.NewCookieStore([]byte(key))
+	store := sessions.NewCookieStore([]byte(h.config.GetString("APP_SESSION_KEY")))
 	store.MaxAge(MaxAge)
 
-	store.Options.Path = "http://localhost:5173/"
+	store.Options.Path = "/"
 	store.Options.HttpOnly = true
-	store.Options.Secure = IsProd
+	store.Options.Secure = h.config.GetBool("APP_SESSION_SECURE")
 
 	gothic.Store = store
 	goth.UseProviders(
-		google.New(googleClientID, googleClientSecret, "http://localhost:3000/api/auth/google/callback"),
+		google.New(googleClientID, googleClientSecret, googleCallbackURL, "email", "profile"),
 	)
 }
 
 func (h *AuthHandler) BeginAuthGoogle(w http.ResponseWriter, r *http.Request) {
 	provider := "google"
-	r = r.WithContext(context.WithValue(context.Background(), "provider", provider))
-	// Probamos la autenticación
-	if gothUser, err := gothic.CompleteUserAuth(w, r); err == nil {
-		fmt.Println(gothUser)
 
-	} else {
-		gothic.BeginAuthHandler(w, r)
+	// guardamos el tipo de usuario para aplicarlo en caso de que sea un registro.
+	if typeUser := r.URL.Query().Get("type_user"); typeUser != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     typeUserCookie,
+			Value:    typeUser,
+			Path:     "/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   h.config.GetBool("APP_SESSION_SECURE"),
+		})
 	}
-	return
+
+	r = r.WithContext(context.WithValue(context.Background(), "provider", provider))
+	gothic.BeginAuthHandler(w, r)
 }
 
+// GetAuthCallbackFunction recupera o registra el usuario de Google y redirige al frontend con el JWT, o con el token
+// temporal del segundo paso si el usuario tiene activada la verificación en dos pasos.
 func (h *AuthHandler) GetAuthCallbackFunction(w http.ResponseWriter, r *http.Request) {
 	provider := "google"
 	r = r.WithContext(context.WithValue(context.Background(), "provider", provider))
-	user, err := gothic.CompleteUserAuth(w, r)
+	gothUser, err := gothic.CompleteUserAuth(w, r)
 	if err != nil {
+		log.Println("Error al autenticar con Google", err)
+		h.redirectWithError(w, r, "No se pudo autenticar con Google")
+		return
+	}
+
+	if gothUser.Email == "" {
+		h.redirectWithError(w, r, "La cuenta de Google no tiene un correo electrónico")
 		return
 	}
 
-	fmt.Println(user)
+	googleUser := types.GoogleUser{
+		GoogleID:  gothUser.UserID,
+		Email:     gothUser.Email,
+		FirstName: gothUser.FirstName,
+		LastName:  gothUser.LastName,
+		URLAvatar: gothUser.AvatarURL,
+		// userinfo v2 lo indica como verified_email, OpenID Connect como email_verified.
+		EmailVerified: rawDataBool(gothUser.RawData, "verified_email") || rawDataBool(gothUser.RawData, "email_verified"),
+	}
+	if cookie, err := r.Cookie(typeUserCookie); err == nil {
+		googleUser.TypeUser = cookie.Value
+		http.SetCookie(w, &http.Cookie{Name: typeUserCookie, Path: "/", MaxAge: -1})
+	}
 
-	// redirigir a la página de inicio
-	http.Redirect(w, r, "http://localhost:5173/onboard", http.StatusFound)
+	// buscamos o registramos el usuario.
+	user, requested, err := data.FindOrCreateGoogleUser(googleUser)
+	if errors.Is(err, data.ErrGoogleEmailNotVerified) {
+		h.redirectWithError(w, r, "Verifica el correo de tu cuenta de Google para ingresar")
+		return
+	}
+	if err != nil {
+		log.Println("Error al registrar usuario de Google", err)
+		h.redirectWithError(w, r, "Error al registrar usuario")
+		return
+	}
+
+	if requested {
+		go notifyAdminsPendingApproval(h.config, user)
+	}
+
+	if user.Status == data.PendingApproval {
+		h.redirectWithError(w, r, "El usuario no ha sido aprobado")
+		return
+	}
+
+	if user.Status == data.Rejected {
+		h.redirectWithError(w, r, "La solicitud del usuario fue rechazada")
+		return
+	}
+
+	if user.Status == data.Blocked {
+		h.redirectWithError(w, r, "El usuario ha sido bloqueado")
+		return
+	}
+
+	// cualquier otro estado, como pendiente de verificación, no puede iniciar sesión.
+	if user.Status != data.Actived {
+		h.redirectWithError(w, r, "El usuario no está activo")
+		return
+	}
+
+	// con la verificación en dos pasos activada se entrega el token temporal, la sesión se crea en /auth/sign-in/2fa.
+	if user.TwoFactorEnabled {
+		challenge, err := generateTwoFactorChallenge(h.config, user.ID)
+		if err != nil {
+			log.Println("Error al generar el token", err)
+			h.redirectWithError(w, r, "Error al generar el token")
+			return
+		}
+
+		values := url.Values{}
+		values.Set("two_factor_required", "true")
+		values.Set("challenge_token", challenge)
+		http.Redirect(w, r, fmt.Sprintf("%s#%s", h.config.GetString("GOOGLE_REDIRECT_URL"), values.Encode()), http.StatusFound)
+		return
+	}
+
+	userAPI := data.UserToAPI(*user)
+	token, refreshToken, err := generateTokens(h.config, userAPI, r.UserAgent(), remoteIP(r))
+	if err != nil {
+		log.Println("Error al generar el token", err)
+		h.redirectWithError(w, r, "Error al generar el token")
+		return
+	}
+
+	// los tokens se envían en el fragmento para que no lleguen a los logs del servidor.
+	values := url.Values{}
+	values.Set("token", token)
+	values.Set("refresh_token", refreshToken)
+	http.Redirect(w, r, fmt.Sprintf("%s#%s", h.config.GetString("GOOGLE_REDIRECT_URL"), values.Encode()), http.StatusFound)
 }
 
 func (h *AuthHandler) GetAuthSuccessFunction(w http.ResponseWriter, r *http.Request) {
@@ -83,3 +183,30 @@ func (h *AuthHandler) GetAuthSuccessFunction(w http.ResponseWriter, r *http.Requ
 
 	fmt.Fprintln(w, gothUser)
 }
+
+// redirectWithError redirige al frontend indicando el error.
+func (h *AuthHandler) redirectWithError(w http.ResponseWriter, r *http.Request, message string) {
+	values := url.Values{}
+	values.Set("error", message)
+	http.Redirect(w, r, fmt.Sprintf("%s#%s", h.config.GetString("GOOGLE_REDIRECT_URL"), values.Encode()), http.StatusFound)
+}
+
+// rawDataBool lee un valor booleano de los datos que devuelve el proveedor, puede llegar como texto.
+func rawDataBool(rawData map[string]interface{}, key string) bool {
+	switch value := rawData[key].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+// remoteIP recupera la ip del cliente sin el puerto.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+type AvatarHandler struct {
+	config *viper.Viper
+}
+
+// NewAvatarHandler crea un nuevo handler para los avatares de los usuarios.
+func NewAvatarHandler(config *viper.Viper) *AvatarHandler {
+	return &AvatarHandler{
+		config: config,
+	}
+}
+
+// UploadAvatar recibe la foto del usuario, la recorta en un cuadrado y la guarda en los tamaños estándar.
+func (h *AvatarHandler) UploadAvatar(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "El archivo es requerido"})
+	}
+
+	maxSize := int64(h.config.GetSizeInBytes("APP_AVATAR_MAX_SIZE"))
+	if fileHeader.Size > maxSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("La imagen no puede pesar más de %s", h.config.GetString("APP_AVATAR_MAX_SIZE")),
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	img, err := utils.DecodeAvatar(content)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	dir := utils.AvatarUserDir(claims.UserAPI.ID)
+	if err := controllingFolders(dir); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// cada foto tiene un nombre nuevo para que los navegadores no usen la foto anterior de la caché.
+	name := uuid.NewString()
+	for _, size := range utils.AvatarSizes {
+		encoded, err := utils.EncodeAvatar(utils.ResizeAvatar(img, size))
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s_%d.jpg", name, size)), encoded, 0644); err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	urlAvatar, err := data.SetUserAvatar(claims.UserAPI.ID, name)
+	if err != nil {
+		_ = data.RemoveAvatarFiles(claims.UserAPI.ID, "")
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// eliminamos las fotos anteriores.
+	if err := data.RemoveAvatarFiles(claims.UserAPI.ID, name); err != nil {
+		log.Println("Error al eliminar los avatares anteriores", err)
+	}
+
+	sizes := make(fiber.Map)
+	for _, size := range utils.AvatarSizes {
+		sizeURL, _ := data.UploadedAvatarURL(urlAvatar, size)
+		sizes[strconv.Itoa(size)] = data.AvatarURL(claims.UserAPI.ID, sizeURL)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"url_avatar": data.AvatarURL(claims.UserAPI.ID, urlAvatar),
+		"sizes":      sizes,
+	})
+}
+
+// DeleteAvatar elimina la foto del usuario, se vuelve a usar el avatar con las iniciales.
+func (h *AvatarHandler) DeleteAvatar(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	err := data.DeleteUserAvatar(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"url_avatar": data.AvatarURL(claims.UserAPI.ID, ""),
+	})
+}
+
+// GetAvatar entrega el avatar del usuario en el tamaño indicado (size), si el usuario subió una foto
+// se redirige a ella, si no se genera el avatar con sus iniciales.
+func (h *AvatarHandler) GetAvatar(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	size := c.QueryInt("size", 128)
+	if !utils.IsAvatarSize(size) {
+		size = 128
+	}
+
+	user, err := data.GetAvatarUser(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	if urlAvatar, ok := data.UploadedAvatarURL(user.URLAvatar, size); ok {
+		return c.Redirect(data.AvatarURL(user.ID, urlAvatar), fiber.StatusFound)
+	}
+
+	c.Set(fiber.HeaderContentType, "image/svg+xml")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'")
+	return c.Send(utils.InitialsAvatarSVG(user.ID, user.FirstName, user.LastName, size))
+}
@@ -69,6 +69,8 @@ func (h *ClassesHandler) NewClasses(c *fiber.Ctx) error {
 	// convertir la clase en un json
 	classAPI = data.ClassToAPI(class)
 
+	recordAudit(c, data.AuditClassCreated, "class", classAPI.ID, nil, classAPI)
+
 	return c.Status(fiber.StatusOK).JSON(classAPI)
 }
 
@@ -130,6 +132,8 @@ func (h *ClassesHandler) UpdateClassByID(c *fiber.Ctx) error {
 		})
 	}
 
+	before := classForAudit(uint(idClass))
+
 	// Actualizamos el registro de la clase
 	err = data.UpdateClassByID(classAPI)
 	if err != nil {
@@ -144,6 +148,8 @@ func (h *ClassesHandler) UpdateClassByID(c *fiber.Ctx) error {
 
 	classResponse := data.ClassToAPI(class)
 
+	recordAudit(c, data.AuditClassUpdated, "class", classResponse.ID, before, classResponse)
+
 	// Retornamos la clase actualizada.
 	return c.JSON(classResponse)
 }
@@ -157,12 +163,16 @@ func (h *ClassesHandler) ArchiveClassByID(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
+	before := classForAudit(uint(idClass))
+
 	// Actualizamos el registro de la clase
 	err = data.ArchiveClass(uint(idClass))
 	if err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
+	recordAudit(c, data.AuditClassArchived, "class", uint(idClass), before, classForAudit(uint(idClass)))
+
 	// Respondemos con un OK.
 	return c.SendStatus(fiber.StatusOK)
 }
@@ -248,3 +258,13 @@ func (h *ClassesHandler) GetStudentsByClass(c *fiber.Ctx) error {
 		"students": studentsAPI,
 	})
 }
+
+// classForAudit recupera la clase para guardarla en la auditoría.
+func classForAudit(id uint) *types.Class {
+	class, err := data.GetClassByID(id)
+	if err != nil {
+		return nil
+	}
+	classAPI := data.ClassToAPI(class)
+	return &classAPI
+}
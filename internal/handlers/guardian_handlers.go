@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+type GuardianHandler struct {
+	config *viper.Viper
+}
+
+// NewGuardianHandler crea un nuevo handler para los representantes de los estudiantes.
+func NewGuardianHandler(config *viper.Viper) *GuardianHandler {
+	return &GuardianHandler{
+		config: config,
+	}
+}
+
+// RequestLink envía la invitación del representante al estudiante.
+func (h *GuardianHandler) RequestLink(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqGuardianLink
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
+		})
+	}
+
+	link, err := data.RequestGuardianLink(claims.UserAPI.ID, req.StudentEmail)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al registrar la invitación",
+		})
+	}
+
+	// solo se notifica a los estudiantes que existen, la respuesta es la misma en todos los casos.
+	if link != nil {
+		message := fmt.Sprintf("Hola, %s. %s %s solicita vincularse como tu representante en Poliword, puedes aceptar o rechazar la invitación desde la aplicación.",
+			link.Student.FirstName, link.Guardian.FirstName, link.Guardian.LastName)
+		go notifyUser(h.config, link.Student.Email, link.Student.TelegramID, "Invitación de representante", message)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Si el correo pertenece a un estudiante, recibirá la invitación",
+	})
+}
+
+// GetLinks lista las invitaciones y vínculos del representante.
+func (h *GuardianHandler) GetLinks(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	links, err := data.GetGuardianLinks(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(data.GuardianLinksToAPI(links))
+}
+
+// DeleteLink elimina el vínculo, lo puede hacer el representante o el estudiante.
+func (h *GuardianHandler) DeleteLink(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	err = data.DeleteGuardianLink(uint(id), claims.UserAPI.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrResourceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetPendingInvitations lista las invitaciones pendientes del estudiante o de los estudiantes del profesor.
+func (h *GuardianHandler) GetPendingInvitations(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	links, err := data.GetPendingGuardianInvitations(claims.UserAPI.ID, claims.HasPermission(data.PermGuardianApprove))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(data.GuardianLinksToAPI(links))
+}
+
+// AcceptInvitation acepta la invitación del representante.
+func (h *GuardianHandler) AcceptInvitation(c *fiber.Ctx) error {
+	return h.decide(c, true)
+}
+
+// RejectInvitation rechaza la invitación del representante.
+func (h *GuardianHandler) RejectInvitation(c *fiber.Ctx) error {
+	return h.decide(c, false)
+}
+
+// GetStudents lista los estudiantes vinculados al representante.
+func (h *GuardianHandler) GetStudents(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	students, err := data.GetGuardianStudents(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(data.UsersToAPI(students))
+}
+
+// GetStudentModules lista los módulos a los que está suscrito el estudiante.
+func (h *GuardianHandler) GetStudentModules(c *fiber.Ctx) error {
+	studentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var paginated types.Paginated
+	if err := c.QueryParser(&paginated); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	_ = paginated.Validate()
+
+	modules, details, err := data.GetModuleForStudent(&paginated, uint(studentID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    data.PublishedModulesToAPI(modules),
+		"details": details,
+	})
+}
+
+// GetStudentTests lista el historial de test finalizados del estudiante, sin las preguntas ni respuestas.
+func (h *GuardianHandler) GetStudentTests(c *fiber.Ctx) error {
+	studentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	tests, err := data.GetFinishedTestsForStudent(uint(studentID))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	testsAPI := data.TestsModuleToAPI(tests)
+	if testsAPI == nil {
+		testsAPI = make([]types.TestModule, 0)
+	}
+	return c.JSON(testsAPI)
+}
+
+// GetStudentQualifications resume las calificaciones del estudiante por módulo.
+func (h *GuardianHandler) GetStudentQualifications(c *fiber.Ctx) error {
+	studentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	qualifications, err := data.GetStudentQualifications(uint(studentID))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(qualifications)
+}
+
+// GetStudentClasses lista las clases en las que está matriculado el estudiante.
+func (h *GuardianHandler) GetStudentClasses(c *fiber.Ctx) error {
+	studentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	classes, err := data.GetClassesSubscribedByStudentID(uint(studentID))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	classesAPI := data.ClassesToAPI(classes)
+	if classesAPI == nil {
+		classesAPI = make([]types.Class, 0)
+	}
+	return c.JSON(classesAPI)
+}
+
+func (h *GuardianHandler) decide(c *fiber.Ctx, accepted bool) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	link, err := data.DecideGuardianLink(uint(id), claims.UserAPI.ID, accepted,
+		claims.HasPermission(data.PermGuardianApprove), claims.HasPermission(data.PermResourcesManageAll))
+	if err != nil {
+		if errors.Is(err, data.ErrResourceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+		if errors.Is(err, data.ErrNotAllowed) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "No autorizado"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	// notificamos al representante.
+	message := fmt.Sprintf("Hola, %s. Tu solicitud para vincularte con %s %s fue aceptada.",
+		link.Guardian.FirstName, link.Student.FirstName, link.Student.LastName)
+	if !accepted {
+		message = fmt.Sprintf("Hola, %s. Tu solicitud para vincularte con %s %s fue rechazada.",
+			link.Guardian.FirstName, link.Student.FirstName, link.Student.LastName)
+	}
+	go notifyUser(h.config, link.Guardian.Email, link.Guardian.TelegramID, "Solicitud de representante", message)
+
+	return c.JSON(data.GuardianLinkToAPI(*link))
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+type ImpersonationHandler struct {
+	config *viper.Viper
+}
+
+// NewImpersonationHandler crea un nuevo handler para que los administradores vean la aplicación como otro usuario.
+func NewImpersonationHandler(config *viper.Viper) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		config: config,
+	}
+}
+
+// Impersonate emite un token de corta duración con el que el administrador ve la aplicación como el usuario,
+// el token lleva las dos identidades y no tiene refresh token.
+func (h *ImpersonationHandler) Impersonate(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if claims.APIKeyID != 0 || claims.IsImpersonated() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Solo un administrador con su propia sesión puede ver la aplicación como otro usuario",
+		})
+	}
+	if uint(id) == claims.UserAPI.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No puedes ver la aplicación como tu propio usuario",
+		})
+	}
+
+	user, err := data.GetUserByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Usuario no encontrado",
+		})
+	}
+	if user.Status != string(data.Actived) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El usuario no está activo",
+		})
+	}
+
+	permissions, err := data.GetRolePermissions(user.TypeUser)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// no se permite suplantar a otros administradores.
+	target := types.UserClaims{Permissions: permissions}
+	if target.HasPermission(data.PermUsersImpersonate, data.PermRolesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No se puede ver la aplicación como otro administrador",
+		})
+	}
+
+	impersonator := claims.UserAPI
+	expiresAt := time.Now().Add(h.config.GetDuration("APP_IMPERSONATION_TTL"))
+	impersonationClaims := types.UserClaims{
+		UserAPI:      *user,
+		SessionID:    claims.SessionID,
+		Impersonator: &impersonator,
+		Permissions:  permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := utils.GenerateAccessToken(h.config, impersonationClaims)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordAudit(c, data.AuditImpersonationStarted, "user", user.ID, nil, fiber.Map{"expires_at": utils.GetFullDate(expiresAt)})
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"token":      token,
+		"expires_at": utils.GetFullDate(expiresAt),
+		"user":       user,
+	})
+}
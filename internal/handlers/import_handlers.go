@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+const (
+	importModePassword = "password"
+	importModeInvite   = "invite"
+)
+
+// columnas aceptadas en el archivo, en español o en inglés.
+var importColumns = map[string]string{
+	"first_name":       "first_name",
+	"nombres":          "first_name",
+	"nombre":           "first_name",
+	"last_name":        "last_name",
+	"apellidos":        "last_name",
+	"apellido":         "last_name",
+	"email":            "email",
+	"correo":           "email",
+	"birth_date":       "birth_date",
+	"fecha_nacimiento": "birth_date",
+	"whatsapp":         "whatsapp",
+	"telegram":         "telegram",
+}
+
+type ImportHandler struct {
+	config *viper.Viper
+}
+
+// NewImportHandler crea un nuevo handler para la importación de usuarios.
+func NewImportHandler(config *viper.Viper) *ImportHandler {
+	return &ImportHandler{
+		config: config,
+	}
+}
+
+// ImportStudents registra los estudiantes de un archivo CSV o XLSX y los matricula en la clase.
+// Con dry_run=true solo se validan las filas, mode indica si se generan contraseñas temporales o invitaciones.
+func (h *ImportHandler) ImportStudents(c *fiber.Ctx) error {
+	classID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	class, err := data.GetClassByID(uint(classID))
+	if err != nil || class.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "La clase no existe"})
+	}
+
+	mode := c.Query("mode", importModeInvite)
+	if mode != importModePassword && mode != importModeInvite {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "el modo debe ser password o invite",
+		})
+	}
+	dryRun := c.QueryBool("dry_run", false)
+
+	rows, rowErrors, err := h.readFile(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	if len(rows) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "El archivo no tiene filas"})
+	}
+	if maxRows := h.config.GetInt("APP_IMPORT_MAX_ROWS"); len(rows)-1 > maxRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("El archivo no puede tener más de %d filas", maxRows),
+		})
+	}
+
+	columns, err := importHeader(rows[0])
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	report := types.UserImportReport{
+		DryRun:  dryRun,
+		Mode:    mode,
+		ClassID: class.ID,
+		Rows:    make([]types.UserImportRow, 0, len(rows)-1),
+	}
+
+	seen := make(map[string]int)
+	for i, values := range rows[1:] {
+		var row types.UserImportRow
+		if message, ok := rowErrors[i+1]; ok {
+			row = types.UserImportRow{Row: i + 2, Status: "error", Message: message}
+		} else if isEmptyRow(values) {
+			continue
+		} else {
+			row = h.importRow(i+2, values, columns, seen, class, mode, dryRun)
+		}
+		switch row.Status {
+		case "created":
+			report.Created++
+		case "enrolled":
+			report.Enrolled++
+		default:
+			report.Failed++
+		}
+		report.Total++
+		report.Rows = append(report.Rows, row)
+	}
+
+	return c.JSON(report)
+}
+
+// importRow valida y registra una fila, number es el número de fila en el archivo.
+func (h *ImportHandler) importRow(number int, values []string, columns map[string]int, seen map[string]int, class data.Class, mode string, dryRun bool) types.UserImportRow {
+	get := func(column string) string {
+		index, ok := columns[column]
+		if !ok || index >= len(values) {
+			return ""
+		}
+		return strings.TrimSpace(values[index])
+	}
+
+	userAPI := types.UserAPI{
+		FirstName: get("first_name"),
+		LastName:  get("last_name"),
+		Email:     strings.ToLower(get("email")),
+		BirthDate: get("birth_date"),
+		Whatsapp:  get("whatsapp"),
+		Telegram:  get("telegram"),
+		TypeUser:  string(data.Student),
+	}
+	row := types.UserImportRow{Row: number, Email: userAPI.Email, Status: "error"}
+
+	if previous, ok := seen[userAPI.Email]; ok && userAPI.Email != "" {
+		row.Message = fmt.Sprintf("el email está repetido en la fila %d", previous)
+		return row
+	}
+	seen[userAPI.Email] = number
+
+	// las fechas de Excel llegan como número de serie.
+	if date, ok := utils.ExcelSerialToDate(userAPI.BirthDate); ok {
+		userAPI.BirthDate = date
+	}
+	if _, err := utils.ParseDateOrNull(userAPI.BirthDate); err != nil {
+		row.Message = "la fecha de nacimiento debe tener el formato 2006-01-02"
+		return row
+	}
+
+	password, err := utils.GenerateTemporaryPassword()
+	if err != nil {
+		row.Message = "error al generar la contraseña"
+		return row
+	}
+	userAPI.Password = password
+
+	if resp, err := types.Validate(&userAPI); err != nil {
+		row.Message = "error en la validacion de datos"
+		if len(resp) > 0 {
+			row.Message = fmt.Sprintf("el campo %s no cumple la regla %s", resp[0].Field, resp[0].Tag)
+		}
+		return row
+	}
+
+	// los estudiantes que ya tienen cuenta solo se matriculan.
+	exists, existing := data.ExisteEmail(userAPI.Email)
+	if exists && existing.TypeUser != string(data.Student) {
+		row.Message = "el email pertenece a un usuario que no es estudiante"
+		return row
+	}
+
+	if dryRun {
+		row.Status = "created"
+		if exists {
+			row.Status = "enrolled"
+		}
+		return row
+	}
+
+	userID := existing.ID
+	if !exists {
+		user, err := data.RegisterImportedUser(&userAPI)
+		if err != nil {
+			row.Message = err.Error()
+			return row
+		}
+		userID = user.ID
+	}
+
+	if _, err := data.EnrollUser(userID, class.Code); err != nil {
+		row.Message = fmt.Sprintf("el usuario se registró pero no se pudo matricular: %s", err.Error())
+		return row
+	}
+
+	if exists {
+		row.Status = "enrolled"
+		return row
+	}
+
+	row.Status = "created"
+	if mode == importModePassword {
+		row.TemporaryPassword = password
+		return row
+	}
+
+	if err := h.sendInvitation(userID, userAPI, class); err != nil {
+		row.Message = "el usuario se registró pero no se pudo enviar la invitación"
+	}
+	return row
+}
+
+// sendInvitation envía el enlace para que el estudiante establezca su contraseña.
+func (h *ImportHandler) sendInvitation(userID uint, userAPI types.UserAPI, class data.Class) error {
+	token, hash, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	err = data.SaveResetPassword(userID, userAPI.Email, hash, time.Now().Add(h.config.GetDuration("APP_IMPORT_INVITE_TTL")), "")
+	if err != nil {
+		return err
+	}
+
+	inviteURL := fmt.Sprintf("%s/auth/forgot-password?token=%s", h.config.GetString("URL_FRONT"), token)
+	message := fmt.Sprintf("Hola, %s. Fuiste registrado en Poliword y matriculado en la clase %s. Ingresa al siguiente enlace para crear tu contraseña: %s",
+		userAPI.FirstName, class.Name, inviteURL)
+	go notifyUser(h.config, userAPI.Email, 0, "Bienvenido a Poliword", message)
+
+	return nil
+}
+
+func (h *ImportHandler) readFile(c *fiber.Ctx) ([][]string, map[int]string, error) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, nil, fmt.Errorf("el archivo es requerido")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return utils.ReadRoster(fileHeader.Filename, content)
+}
+
+// importHeader recupera el índice de cada columna a partir de la cabecera.
+func importHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+		if column, ok := importColumns[name]; ok {
+			columns[column] = i
+		}
+	}
+
+	for _, required := range []string{"first_name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("falta la columna %s", required)
+		}
+	}
+	return columns, nil
+}
+
+func isEmptyRow(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
 	"Proyectos-UTEQ/api-ortografia/internal/utils"
 	"Proyectos-UTEQ/api-ortografia/pkg/types"
 	"strings"
@@ -20,7 +21,25 @@ func NewJWTHandler(config *viper.Viper) *JWTHandler {
 	}
 }
 
+// JWTMiddleware autentica la petición con el JWT del usuario, las rutas del grupo no aceptan API keys.
 func (h *JWTHandler) JWTMiddleware(c *fiber.Ctx) error {
+	if c.Get("X-API-Key") != "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "La ruta no acepta API keys"})
+	}
+	return h.userMiddleware(c)
+}
+
+// JWTOrAPIKeyMiddleware autentica la petición con el JWT del usuario o con la API key de una integración
+// (encabezado X-API-Key). Cada ruta del grupo declara el permiso que acepta con RequirePermission o rechaza las
+// keys con RejectAPIKeys.
+func (h *JWTHandler) JWTOrAPIKeyMiddleware(c *fiber.Ctx) error {
+	if apiKey := c.Get("X-API-Key"); apiKey != "" {
+		return h.apiKeyMiddleware(c, apiKey)
+	}
+	return h.userMiddleware(c)
+}
+
+func (h *JWTHandler) userMiddleware(c *fiber.Ctx) error {
 	// recuperar el token
 	auth := c.Get("Authorization")
 	authArray := strings.Split(auth, " ")
@@ -43,21 +62,137 @@ func (h *JWTHandler) JWTMiddleware(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token no valido"})
 	}
 
+	// revisamos que el usuario no haya sido bloqueado después de emitir el token.
+	if !data.IsUserActive(claims.UserAPI.ID) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token revocado"})
+	}
+
+	// revisamos que la sesión del dispositivo no haya sido cerrada, al suplantar la sesión es la del administrador.
+	sessionUserID := claims.UserAPI.ID
+	if claims.IsImpersonated() {
+		sessionUserID = claims.Impersonator.ID
+	}
+	if claims.SessionID == 0 || !data.TouchSession(claims.SessionID, sessionUserID) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Sesión cerrada"})
+	}
+
+	// el rol exige la verificación en dos pasos, solo puede activarla.
+	if claims.TwoFactorPending && !strings.HasPrefix(c.Path(), "/api/users/me/2fa") {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "Debes activar la verificación en dos pasos", "two_factor_setup_required": true})
+	}
+
 	c.Locals("user", claims)
 
+	if claims.IsImpersonated() {
+		return h.impersonationMiddleware(c, claims)
+	}
+
 	return c.Next()
 }
 
-// Authorization controla la autorización del usuario por rol.
-func Authorization(allowedRoles ...string) func(c *fiber.Ctx) error {
+// impersonationMiddleware registra en la auditoría cada petición mientras un administrador ve la aplicación como
+// el usuario. Solo se permiten las consultas GET, las consultas sensibles se marcan con DenyImpersonation.
+func (h *JWTHandler) impersonationMiddleware(c *fiber.Ctx, claims *types.UserClaims) error {
+	if !data.IsUserActive(claims.Impersonator.ID) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token revocado"})
+	}
+
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return blockImpersonation(c)
+	}
+
+	err := c.Next()
+	if c.Locals(impersonationBlockedLocal) != true {
+		recordAudit(c, data.AuditImpersonationRequest, "user", claims.UserAPI.ID, nil, impersonationRequest(c))
+	}
+	return err
+}
+
+// indica que la acción se rechazó al ver la aplicación como otro usuario, ya quedó registrada en la auditoría.
+const impersonationBlockedLocal = "impersonation_blocked"
+
+// DenyImpersonation marca una ruta de consulta que no se puede usar al ver la aplicación como otro usuario,
+// como la exportación de datos, las sesiones o la administración.
+func DenyImpersonation(c *fiber.Ctx) error {
+	if utils.GetClaims(c).IsImpersonated() {
+		return blockImpersonation(c)
+	}
+	return c.Next()
+}
+
+// blockImpersonation rechaza la acción y la registra en la auditoría.
+func blockImpersonation(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+	c.Locals(impersonationBlockedLocal, true)
+	recordAudit(c, data.AuditImpersonationBlocked, "user", claims.UserAPI.ID, nil, impersonationRequest(c))
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "La acción no está permitida al ver la aplicación como otro usuario"})
+}
+
+func impersonationRequest(c *fiber.Ctx) fiber.Map {
+	return fiber.Map{
+		"method": c.Method(),
+		"path":   c.OriginalURL(),
+		"status": c.Response().StatusCode(),
+	}
+}
+
+// indica que la petición es de una API key y que la ruta declaró un permiso que la key tiene.
+const apiKeyScopeLocal = "api_key_scope"
+
+// RequirePermission controla que el JWT o la API key tenga alguno de los permisos indicados.
+func RequirePermission(permissions ...string) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		claims := utils.GetClaims(c)
-		for _, role := range allowedRoles {
-			if role == claims.TypeUser {
-				return c.Next()
+		if claims.HasPermission(permissions...) {
+			if claims.APIKeyID != 0 {
+				c.Locals(apiKeyScopeLocal, true)
 			}
+			return c.Next()
 		}
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "No autorizado"})
 	}
+}
+
+// RejectAPIKeys marca las rutas sin permiso de los grupos con JWTOrAPIKeyMiddleware, como la cuenta personal,
+// que no aceptan API keys. Se revisa en la ruta y no comparando el path porque fiber no distingue mayúsculas.
+func RejectAPIKeys(c *fiber.Ctx) error {
+	if utils.GetClaims(c).APIKeyID != 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "La ruta no acepta API keys"})
+	}
+	return c.Next()
+}
 
+// apiKeyMiddleware autentica una integración, la key actúa en nombre del administrador que la creó
+// pero solo con los permisos (scopes) de la key que el administrador todavía tiene en su rol.
+func (h *JWTHandler) apiKeyMiddleware(c *fiber.Ctx, secret string) error {
+	key, err := data.UseAPIKey(secret, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	// si la cuenta del administrador se bloquea sus keys dejan de funcionar.
+	user, err := data.GetUserByID(key.CreatedByID)
+	if err != nil || user.Status != string(data.Actived) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "API key revocada"})
+	}
+
+	// si el rol del administrador pierde un permiso la key también lo pierde.
+	rolePermissions, err := data.GetRolePermissions(user.TypeUser)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "API key revocada"})
+	}
+	scopes := make([]string, 0, len(key.Permissions))
+	for _, permission := range key.Permissions {
+		if utils.ContainsString(rolePermissions, permission.Name) {
+			scopes = append(scopes, permission.Name)
+		}
+	}
+
+	c.Locals("user", &types.UserClaims{
+		UserAPI:     *user,
+		APIKeyID:    key.ID,
+		Permissions: scopes,
+	})
+
+	return c.Next()
 }
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+// authAs reemplaza la autenticación con los claims indicados, así no se consulta la base de datos.
+func authAs(claims *types.UserClaims) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("user", claims)
+		return c.Next()
+	}
+}
+
+func okHandler(c *fiber.Ctx) error {
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// notOwner el usuario nunca es dueño del recurso.
+func notOwner(c *fiber.Ctx, userID uint) (bool, error) {
+	return false, nil
+}
+
+func TestJWTMiddlewareRejectsAPIKeys(t *testing.T) {
+	jwtHandler := NewJWTHandler(viper.New())
+	app := fiber.New()
+	app.Get("/api/users/me", jwtHandler.JWTMiddleware, okHandler)
+
+	for _, path := range []string{"/api/users/me", "/API/Users/ME"} {
+		req := httptest.NewRequest(fiber.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", data.APIKeyPrefix+"secreto")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Fatalf("%s: se esperaba 403 y se obtuvo %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	key := &types.UserClaims{
+		UserAPI:     types.UserAPI{ID: 1},
+		APIKeyID:    1,
+		Permissions: []string{data.PermGradesRead},
+	}
+	user := &types.UserClaims{
+		UserAPI:     types.UserAPI{ID: 2},
+		Permissions: []string{data.PermGradesRead},
+	}
+
+	tests := []struct {
+		name     string
+		claims   *types.UserClaims
+		handlers []fiber.Handler
+		status   int
+	}{
+		{"ruta sin permiso", key, []fiber.Handler{RejectAPIKeys, okHandler}, fiber.StatusForbidden},
+		{"permiso que la key no tiene", key, []fiber.Handler{RequirePermission(data.PermUsersManage), okHandler}, fiber.StatusForbidden},
+		{"permiso de la key", key, []fiber.Handler{RequirePermission(data.PermGradesRead), RequireOwnership(notOwner), okHandler}, fiber.StatusOK},
+		{"usuario en ruta sin permiso", user, []fiber.Handler{RejectAPIKeys, okHandler}, fiber.StatusOK},
+		{"usuario que no es dueño", user, []fiber.Handler{RequirePermission(data.PermGradesRead), RequireOwnership(notOwner), okHandler}, fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/api/module/:id/grades", append([]fiber.Handler{authAs(test.claims)}, test.handlers...)...)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/module/1/grades", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.status {
+				t.Fatalf("se esperaba %d y se obtuvo %d", test.status, resp.StatusCode)
+			}
+		})
+	}
+}
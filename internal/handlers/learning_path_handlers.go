@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type LearningPathHandler struct {
+	config *viper.Viper
+}
+
+// NewLearningPathHandler crea un nuevo handler para las rutas de aprendizaje y los prerrequisitos de los módulos.
+func NewLearningPathHandler(config *viper.Viper) *LearningPathHandler {
+	return &LearningPathHandler{
+		config: config,
+	}
+}
+
+// CreateLearningPath crea una ruta con los módulos del profesor en el orden indicado.
+func (h *LearningPathHandler) CreateLearningPath(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	req, status, errResponse := h.parseLearningPath(c)
+	if req == nil {
+		return c.Status(status).JSON(errResponse)
+	}
+
+	path, err := data.CreateLearningPath(claims.UserAPI.ID, *req)
+	if err != nil {
+		return learningPathError(c, err)
+	}
+
+	pathResponse := data.LearningPathToAPI(*path, true, nil)
+	recordAudit(c, data.AuditPathCreated, "learning_path", path.ID, nil, pathResponse)
+
+	return c.Status(fiber.StatusCreated).JSON(pathResponse)
+}
+
+// GetLearningPaths lista las rutas con el estado de bloqueo de cada módulo para el usuario, con mine=true solo las rutas propias.
+func (h *LearningPathHandler) GetLearningPaths(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var ownerID uint
+	if c.QueryBool("mine") {
+		ownerID = claims.UserAPI.ID
+	}
+
+	paths, err := data.GetLearningPaths(ownerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	moduleIDs := make([]uint, 0)
+	for _, path := range paths {
+		for _, pathModule := range path.Modules {
+			moduleIDs = append(moduleIDs, pathModule.ModuleID)
+		}
+	}
+	locks, err := data.ModuleLocks(claims.UserAPI.ID, moduleIDs)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	pathsResponse := make([]types.LearningPath, 0, len(paths))
+	for _, path := range paths {
+		draft := h.draftView(claims, path)
+		pathResponse := data.LearningPathToAPI(path, draft, locks)
+		// los estudiantes no ven las rutas sin módulos publicados.
+		if !draft && len(pathResponse.Modules) == 0 {
+			continue
+		}
+		pathsResponse = append(pathsResponse, pathResponse)
+	}
+
+	return c.JSON(pathsResponse)
+}
+
+// GetLearningPath recupera la ruta con el estado de bloqueo de cada módulo para el usuario.
+func (h *LearningPathHandler) GetLearningPath(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	path, err := data.GetLearningPath(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Ruta de aprendizaje no encontrada"})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	moduleIDs := make([]uint, 0, len(path.Modules))
+	for _, pathModule := range path.Modules {
+		moduleIDs = append(moduleIDs, pathModule.ModuleID)
+	}
+	locks, err := data.ModuleLocks(claims.UserAPI.ID, moduleIDs)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(data.LearningPathToAPI(*path, h.draftView(claims, *path), locks))
+}
+
+// UpdateLearningPath actualiza los datos de la ruta y el orden de sus módulos.
+func (h *LearningPathHandler) UpdateLearningPath(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	req, status, errResponse := h.parseLearningPath(c)
+	if req == nil {
+		return c.Status(status).JSON(errResponse)
+	}
+
+	before, err := data.GetLearningPath(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Ruta de aprendizaje no encontrada"})
+	}
+
+	path, err := data.UpdateLearningPath(uint(id), *req)
+	if err != nil {
+		return learningPathError(c, err)
+	}
+
+	pathResponse := data.LearningPathToAPI(*path, true, nil)
+	recordAudit(c, data.AuditPathUpdated, "learning_path", path.ID, data.LearningPathToAPI(*before, true, nil), pathResponse)
+
+	return c.JSON(pathResponse)
+}
+
+// DeleteLearningPath elimina la ruta, los módulos no se eliminan.
+func (h *LearningPathHandler) DeleteLearningPath(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	before, err := data.GetLearningPath(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Ruta de aprendizaje no encontrada"})
+	}
+
+	if err := data.DeleteLearningPath(uint(id)); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordAudit(c, data.AuditPathDeleted, "learning_path", uint(id), data.LearningPathToAPI(*before, true, nil), nil)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetModulePrerequisites recupera los prerrequisitos del módulo y las condiciones que el usuario aún no cumple.
+func (h *LearningPathHandler) GetModulePrerequisites(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	prerequisites, err := data.GetModulePrerequisites(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	conditions, err := data.ModuleUnmetConditions(claims.UserAPI.ID, uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	if conditions == nil {
+		conditions = make([]types.ModuleUnlockCondition, 0)
+	}
+
+	return c.JSON(fiber.Map{
+		"prerequisites":    prerequisites,
+		"locked":           len(conditions) > 0,
+		"unmet_conditions": conditions,
+	})
+}
+
+// SetModulePrerequisites reemplaza los prerrequisitos del módulo, por ejemplo aprobar otro módulo con al menos 70%.
+func (h *LearningPathHandler) SetModulePrerequisites(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqModulePrerequisites
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al parsear los datos",
+		})
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validación de datos",
+			"data":    resp,
+		})
+	}
+
+	before, err := data.GetModulePrerequisites(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if err := data.SetModulePrerequisites(uint(id), req.Prerequisites); err != nil {
+		return learningPathError(c, err)
+	}
+
+	prerequisites, err := data.GetModulePrerequisites(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordAudit(c, data.AuditModulePrerequisites, "module", uint(id), before, prerequisites)
+
+	return c.JSON(prerequisites)
+}
+
+// parseLearningPath valida los datos de la ruta y que el usuario pueda modificar todos sus módulos,
+// así una ruta no bloquea los módulos de otros profesores. Si los datos no son válidos devuelve la respuesta de error.
+func (h *LearningPathHandler) parseLearningPath(c *fiber.Ctx) (*types.ReqLearningPath, int, fiber.Map) {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqLearningPath
+	if err := c.BodyParser(&req); err != nil {
+		return nil, fiber.StatusBadRequest, fiber.Map{"status": "error", "message": "Error al parsear los datos"}
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return nil, fiber.StatusBadRequest, fiber.Map{"status": "error", "message": "Error en la validación de datos", "data": resp}
+	}
+
+	if claims.HasPermission(data.PermResourcesManageAll) {
+		return &req, 0, nil
+	}
+	for _, moduleID := range req.ModuleIDs {
+		ok, err := data.CanManageModule(claims.UserAPI.ID, moduleID)
+		if errors.Is(err, data.ErrResourceNotFound) {
+			return nil, fiber.StatusNotFound, fiber.Map{"status": "error", "message": "Módulo no encontrado"}
+		}
+		if err != nil {
+			return nil, fiber.StatusInternalServerError, fiber.Map{"status": "error", "message": err.Error()}
+		}
+		if !ok {
+			return nil, fiber.StatusForbidden, fiber.Map{"status": "error", "message": "Solo puedes agregar a la ruta tus propios módulos"}
+		}
+	}
+	return &req, 0, nil
+}
+
+// draftView el dueño de la ruta ve los borradores de los módulos, los demás solo los módulos publicados.
+func (h *LearningPathHandler) draftView(claims *types.UserClaims, path data.LearningPath) bool {
+	return claims.HasPermission(data.PermResourcesManageAll) || path.CreatedByID == claims.UserAPI.ID
+}
+
+func learningPathError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, data.ErrUnlockCycle):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, data.ErrRepeatedPathModule), errors.Is(err, data.ErrSelfPrerequisite),
+		errors.Is(err, data.ErrRepeatedPrerequisite), errors.Is(err, data.ErrModuleNotPublished):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, data.ErrResourceNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Módulo no encontrado"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": err.Error(),
+	})
+}
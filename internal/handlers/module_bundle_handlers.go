@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+type ModuleBundleHandler struct {
+	config *viper.Viper
+}
+
+// NewModuleBundleHandler crea un nuevo handler para exportar e importar módulos entre instalaciones.
+func NewModuleBundleHandler(config *viper.Viper) *ModuleBundleHandler {
+	return &ModuleBundleHandler{
+		config: config,
+	}
+}
+
+// ExportModule descarga el módulo con sus preguntas e imágenes en un paquete JSON.
+func (h *ModuleBundleHandler) ExportModule(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	bundle, err := data.ExportModule(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	content, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, data.ModuleBundleFileName(bundle)))
+	return c.Send(content)
+}
+
+// ImportModule crea un módulo del usuario a partir de un paquete exportado, con dry_run=true solo se revisan los conflictos.
+func (h *ModuleBundleHandler) ImportModule(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var bundle types.ModuleBundle
+	if err := c.BodyParser(&bundle); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El archivo no es un JSON válido",
+		})
+	}
+
+	report, err := data.ImportModule(bundle, claims.UserAPI.ID, c.QueryBool("dry_run"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al importar el módulo",
+		})
+	}
+
+	if report.HasErrors() {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	}
+	if !report.Imported {
+		return c.JSON(report)
+	}
+
+	recordAudit(c, data.AuditModuleImported, "module", report.Module.ID, nil, fiber.Map{"source": bundle.Source, "module": report.Module})
+
+	return c.Status(fiber.StatusCreated).JSON(report)
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+type ModuleCollaboratorHandler struct {
+	config *viper.Viper
+}
+
+// NewModuleCollaboratorHandler crea un nuevo handler para los colaboradores de los módulos y las transferencias.
+func NewModuleCollaboratorHandler(config *viper.Viper) *ModuleCollaboratorHandler {
+	return &ModuleCollaboratorHandler{
+		config: config,
+	}
+}
+
+// GetCollaborators lista los colaboradores del módulo con sus invitaciones pendientes.
+func (h *ModuleCollaboratorHandler) GetCollaborators(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	collaborators, err := data.GetModuleCollaborators(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	collaboratorsResponse := make([]types.ModuleCollaborator, 0, len(collaborators))
+	for _, collaborator := range collaborators {
+		collaboratorsResponse = append(collaboratorsResponse, data.ModuleCollaboratorToAPI(collaborator))
+	}
+
+	return c.JSON(collaboratorsResponse)
+}
+
+// InviteCollaborator invita a un profesor por su correo como editor o lector del módulo.
+func (h *ModuleCollaboratorHandler) InviteCollaborator(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqInviteCollaborator
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al parsear los datos",
+		})
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validación de datos",
+			"data":    resp,
+		})
+	}
+
+	exists, user := data.ExisteEmail(req.Email)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No existe un profesor con ese correo",
+		})
+	}
+
+	collaborator, err := data.InviteCollaborator(uint(id), claims.UserAPI.ID, user.ID, req.Role)
+	if err != nil {
+		return collaboratorError(c, err)
+	}
+
+	collaboratorResponse := data.ModuleCollaboratorToAPI(*collaborator)
+	recordAudit(c, data.AuditCollaboratorInvited, "module", uint(id), nil, collaboratorResponse)
+
+	if module, err := data.ModuleByID(uint(id)); err == nil {
+		message := fmt.Sprintf("%s %s te invitó a colaborar como %s en el módulo \"%s\". Revisa tus invitaciones para aceptarla.",
+			claims.UserAPI.FirstName, claims.UserAPI.LastName, req.Role, module.Title)
+		go notifyUser(h.config, user.Email, user.TelegramID, "Invitación para colaborar en un módulo", message)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collaboratorResponse)
+}
+
+// UpdateCollaborator cambia el rol del colaborador.
+func (h *ModuleCollaboratorHandler) UpdateCollaborator(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	collaboratorID, err := c.ParamsInt("collaborator")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqCollaboratorRole
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al parsear los datos",
+		})
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validación de datos",
+			"data":    resp,
+		})
+	}
+
+	before, err := data.GetModuleCollaborator(uint(id), uint(collaboratorID))
+	if err != nil {
+		return collaboratorError(c, err)
+	}
+	beforeResponse := data.ModuleCollaboratorToAPI(*before)
+
+	collaborator, err := data.UpdateCollaboratorRole(uint(id), uint(collaboratorID), req.Role)
+	if err != nil {
+		return collaboratorError(c, err)
+	}
+
+	collaboratorResponse := data.ModuleCollaboratorToAPI(*collaborator)
+	recordAudit(c, data.AuditCollaboratorUpdated, "module", uint(id), beforeResponse, collaboratorResponse)
+
+	return c.JSON(collaboratorResponse)
+}
+
+// RemoveCollaborator quita al colaborador del módulo o cancela la invitación.
+func (h *ModuleCollaboratorHandler) RemoveCollaborator(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	collaboratorID, err := c.ParamsInt("collaborator")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	before, err := data.GetModuleCollaborator(uint(id), uint(collaboratorID))
+	if err != nil {
+		return collaboratorError(c, err)
+	}
+
+	if err := data.RemoveCollaborator(uint(id), uint(collaboratorID)); err != nil {
+		return collaboratorError(c, err)
+	}
+
+	recordAudit(c, data.AuditCollaboratorRemoved, "module", uint(id), data.ModuleCollaboratorToAPI(*before), nil)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// TransferModule transfiere el módulo a otro profesor, el nuevo dueño gestiona los colaboradores desde ese momento.
+func (h *ModuleCollaboratorHandler) TransferModule(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqTransferModule
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al parsear los datos",
+		})
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validación de datos",
+			"data":    resp,
+		})
+	}
+
+	before, err := data.ModuleByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Módulo no encontrado"})
+	}
+
+	if _, err := data.TransferModuleOwnership(uint(id), req.UserID, req.KeepAsEditor); err != nil {
+		return collaboratorError(c, err)
+	}
+
+	module, err := data.ModuleByID(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	moduleResponse := data.ModuleToApi(*module)
+	recordAudit(c, data.AuditModuleTransferred, "module", uint(id), data.ModuleToApi(*before), moduleResponse)
+
+	message := fmt.Sprintf("%s %s te transfirió el módulo \"%s\", ahora eres su dueño.",
+		claims.UserAPI.FirstName, claims.UserAPI.LastName, module.Title)
+	go notifyUser(h.config, module.CreatedBy.Email, module.CreatedBy.TelegramID, "Transferencia de módulo", message)
+
+	return c.JSON(moduleResponse)
+}
+
+// GetInvitations lista las invitaciones pendientes del profesor.
+func (h *ModuleCollaboratorHandler) GetInvitations(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	invitations, err := data.GetPendingInvitations(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	invitationsResponse := make([]types.ModuleInvitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		invitationsResponse = append(invitationsResponse, data.ModuleInvitationToAPI(invitation))
+	}
+
+	return c.JSON(invitationsResponse)
+}
+
+// AcceptInvitation acepta la invitación, el módulo aparece en el listado del profesor.
+func (h *ModuleCollaboratorHandler) AcceptInvitation(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	collaborator, err := data.AcceptInvitation(claims.UserAPI.ID, uint(id))
+	if err != nil {
+		return collaboratorError(c, err)
+	}
+
+	return c.JSON(data.ModuleCollaboratorToAPI(*collaborator))
+}
+
+// LeaveCollaboration rechaza la invitación o deja de colaborar en el módulo.
+func (h *ModuleCollaboratorHandler) LeaveCollaboration(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	collaborator, err := data.LeaveCollaboration(claims.UserAPI.ID, uint(id))
+	if err != nil {
+		return collaboratorError(c, err)
+	}
+
+	recordAudit(c, data.AuditCollaboratorRemoved, "module", collaborator.ModuleID, data.ModuleCollaboratorToAPI(*collaborator), nil)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func collaboratorError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, data.ErrCollaboratorExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, data.ErrCollaboratorIsOwner), errors.Is(err, data.ErrNotActiveTeacher), errors.Is(err, data.ErrInvalidTransfer):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, data.ErrCollaboratorNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	case errors.Is(err, data.ErrResourceNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Módulo no encontrado"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": err.Error(),
+	})
+}
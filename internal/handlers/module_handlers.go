@@ -5,11 +5,13 @@ import (
 	"Proyectos-UTEQ/api-ortografia/internal/utils"
 	"Proyectos-UTEQ/api-ortografia/pkg/types"
 	"bufio"
+	"errors"
 	"fmt"
 	"github.com/blackestwhite/gopenai"
 	"log"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -62,6 +64,8 @@ func (h *ModuleHandler) CreateModuleForTeacher(c *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(c, data.AuditModuleCreated, "module", moduleResponse.ID, nil, moduleResponse)
+
 	// Generamos la url de la imagen del módulo.
 	moduleResponse.ImgBackURL = h.config.GetString("APP_HOST") + moduleResponse.ImgBackURL
 
@@ -69,6 +73,74 @@ func (h *ModuleHandler) CreateModuleForTeacher(c *fiber.Ctx) error {
 
 }
 
+// CloneModule copia el módulo con sus preguntas para el usuario. El dueño copia el borrador,
+// los demás profesores solo pueden copiar la versión publicada de los módulos públicos.
+func (h *ModuleHandler) CloneModule(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqCloneModule
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Error al parsear los datos",
+			})
+		}
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validación de datos",
+			"data":    resp,
+		})
+	}
+
+	source, err := data.ModuleByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Módulo no encontrado",
+		})
+	}
+
+	fromDraft := claims.HasPermission(data.PermResourcesManageAll)
+	if !fromDraft {
+		fromDraft, err = data.CanViewModule(claims.UserAPI.ID, source.ID)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+	if !fromDraft && !source.IsPublic {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "No autorizado"})
+	}
+
+	clone, err := data.CloneModule(source.ID, fromDraft, claims.UserAPI.ID, req)
+	if errors.Is(err, data.ErrModuleNotPublished) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al clonar el módulo",
+		})
+	}
+
+	moduleResponse := data.ModuleToApi(*clone)
+	recordAudit(c, data.AuditModuleCloned, "module", clone.ID, nil, fiber.Map{"source_module_id": source.ID, "module": moduleResponse})
+
+	return c.Status(fiber.StatusCreated).JSON(moduleResponse)
+}
+
 // UpdateModule Actualiza el modulo en la base de datos.
 func (h *ModuleHandler) UpdateModule(c *fiber.Ctx) error {
 
@@ -116,6 +188,12 @@ func (h *ModuleHandler) UpdateModule(c *fiber.Ctx) error {
 		})
 	}
 
+	var before *types.Module
+	if moduleBefore, err := data.ModuleByID(module.ID); err == nil {
+		moduleAPI := data.ModuleToApi(*moduleBefore)
+		before = &moduleAPI
+	}
+
 	// Actualizamos el módulo en la db
 	moduleData, err := data.UpdateModule(&module)
 	if err != nil {
@@ -128,6 +206,8 @@ func (h *ModuleHandler) UpdateModule(c *fiber.Ctx) error {
 
 	moduleResponse := data.ModuleToApi(*moduleData)
 
+	recordAudit(c, data.AuditModuleUpdated, "module", module.ID, before, moduleResponse)
+
 	return c.Status(fiber.StatusOK).JSON(moduleResponse)
 }
 
@@ -136,10 +216,9 @@ func (h *ModuleHandler) GetModulesForTeacher(c *fiber.Ctx) error {
 
 	claims := utils.GetClaims(c)
 
-	// campos para paginar
-	var paginated types.Paginated
-
-	if err := c.QueryParser(&paginated); err != nil {
+	// campos para paginar y filtrar
+	paginated, filter, err := moduleListQuery(c)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error al parsear los datos",
@@ -147,11 +226,8 @@ func (h *ModuleHandler) GetModulesForTeacher(c *fiber.Ctx) error {
 		})
 	}
 
-	// validamos
-	_ = paginated.Validate()
-
 	// obtenemos los modules
-	modules, details, err := data.GetModulesForTeacher(&paginated, claims.UserAPI.ID)
+	modules, details, facets, err := data.GetModulesForTeacher(paginated, filter, claims.UserAPI.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -162,17 +238,33 @@ func (h *ModuleHandler) GetModulesForTeacher(c *fiber.Ctx) error {
 
 	modulesApi := data.ModulesToAPI(modules)
 
+	// rol del profesor en cada módulo, los compartidos aparecen como editor o viewer.
+	moduleIDs := make([]uint, 0, len(modules))
+	for _, module := range modules {
+		moduleIDs = append(moduleIDs, module.ID)
+	}
+	roles, err := data.ModuleRolesForUser(claims.UserAPI.ID, moduleIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	for i := range modulesApi {
+		modulesApi[i].Role = roles[modulesApi[i].ID]
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data":    modulesApi,
 		"details": details,
+		"facets":  facets,
 	})
 }
 
 func (h *ModuleHandler) GetModules(c *fiber.Ctx) error {
 
-	var paginated types.Paginated
-
-	if err := c.QueryParser(&paginated); err != nil {
+	paginated, filter, err := moduleListQuery(c)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error al parsear los datos",
@@ -180,11 +272,11 @@ func (h *ModuleHandler) GetModules(c *fiber.Ctx) error {
 		})
 	}
 
-	// validamos
-	_ = paginated.Validate()
+	// solo quien administra todos los recursos ve los borradores, los demás la versión publicada.
+	drafts := utils.GetClaims(c).HasPermission(data.PermResourcesManageAll)
 
 	// obtenemos los modules
-	modules, details, err := data.GetModule(&paginated)
+	modules, details, facets, err := data.GetModule(paginated, filter, drafts)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -193,11 +285,15 @@ func (h *ModuleHandler) GetModules(c *fiber.Ctx) error {
 		})
 	}
 
-	modulesApi := data.ModulesToAPI(modules)
+	modulesApi := data.PublishedModulesToAPI(modules)
+	if drafts {
+		modulesApi = data.ModulesToAPI(modules)
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data":    modulesApi,
 		"details": details,
+		"facets":  facets,
 	})
 }
 
@@ -205,9 +301,8 @@ func (h *ModuleHandler) GetModuleWithIsSubscribed(c *fiber.Ctx) error {
 
 	claims := utils.GetClaims(c)
 
-	var paginated types.Paginated
-
-	if err := c.QueryParser(&paginated); err != nil {
+	paginated, filter, err := moduleListQuery(c)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error al parsear los datos",
@@ -215,11 +310,8 @@ func (h *ModuleHandler) GetModuleWithIsSubscribed(c *fiber.Ctx) error {
 		})
 	}
 
-	// validamos
-	_ = paginated.Validate()
-
 	// obtenemos los modules
-	modules, details, err := data.GetModuleWithUserSubscription(&paginated, claims.UserAPI.ID)
+	modules, details, facets, err := data.GetModuleWithUserSubscription(paginated, filter, claims.UserAPI.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -230,9 +322,18 @@ func (h *ModuleHandler) GetModuleWithIsSubscribed(c *fiber.Ctx) error {
 
 	modulesApi := data.ModuleUserSubToApi(modules)
 
+	// estado de bloqueo de cada módulo según sus prerrequisitos y las rutas de aprendizaje.
+	if err := data.ApplyModuleLocks(claims.UserAPI.ID, modulesApi); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data":    modulesApi,
 		"details": details,
+		"facets":  facets,
 	})
 
 }
@@ -306,7 +407,7 @@ func (h *ModuleHandler) Subscriptions(c *fiber.Ctx) error {
 		})
 	}
 
-	modulesApi := data.ModulesToAPI(modules)
+	modulesApi := data.PublishedModulesToAPI(modules)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data":    modulesApi,
@@ -360,7 +461,17 @@ func (h *ModuleHandler) GetModuleByID(c *fiber.Ctx) error {
 		})
 	}
 
+	// quien no puede editar el módulo ve la versión publicada y no el borrador.
+	claims := utils.GetClaims(c)
+	canManage := claims.HasPermission(data.PermResourcesManageAll)
+	if !canManage {
+		canManage, _ = data.CanViewModule(claims.UserAPI.ID, module.ID)
+	}
+
 	moduleResponse := data.ModuleToApi(*module)
+	if !canManage {
+		moduleResponse = data.PublishedModuleToApi(*module)
+	}
 
 	return c.JSON(moduleResponse)
 }
@@ -377,7 +488,35 @@ func (h *ModuleHandler) GenerateTest(c *fiber.Ctx) error {
 		})
 	}
 
+	// los módulos con prerrequisitos pendientes están bloqueados, salvo para quien puede editar el módulo.
+	canManage := claims.HasPermission(data.PermResourcesManageAll)
+	if !canManage {
+		canManage, _ = data.CanManageModule(claims.UserAPI.ID, uint(idModule))
+	}
+	if !canManage {
+		conditions, err := data.ModuleUnmetConditions(claims.UserAPI.ID, uint(idModule))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+		if len(conditions) > 0 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":           "error",
+				"message":          "El módulo está bloqueado: " + conditions[0].Message,
+				"unmet_conditions": conditions,
+			})
+		}
+	}
+
 	testId, err := data.GenerateTestForStudent(claims.UserAPI.ID, uint(idModule))
+	if errors.Is(err, data.ErrModuleNotPublished) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -695,6 +834,8 @@ func (h *ModuleHandler) FinishTest(c *fiber.Ctx) error {
 		})
 	}
 
+	before := data.AuditTestState(uint(testId))
+
 	// Finalizar el test en la base de datos.
 	finishTest, err := data.FinishTest(uint(testId))
 	if err != nil {
@@ -704,9 +845,29 @@ func (h *ModuleHandler) FinishTest(c *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(c, data.AuditTestFinished, "test", uint(testId), before, data.AuditTestState(uint(testId)))
+
 	return c.JSON(finishTest)
 }
 
+// GetGrades recupera las calificaciones de los estudiantes en el módulo.
+func (h *ModuleHandler) GetGrades(c *fiber.Ctx) error {
+	idModule, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	grades, err := data.GetModuleGrades(uint(idModule))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(grades)
+}
+
 // GetMyTestsByModule recupera todos los test de un usuario en un módulo específico.
 func (h *ModuleHandler) GetMyTestsByModule(c *fiber.Ctx) error {
 	claims := utils.GetClaims(c)
@@ -758,3 +919,23 @@ func (h *ModuleHandler) GetPointsStudentsForModule(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(lista)
 }
+
+// moduleListQuery recupera la paginación y las etiquetas del listado de módulos, al buscar sin indicar
+// el orden se ordena por relevancia.
+func moduleListQuery(c *fiber.Ctx) (*types.Paginated, *types.ModuleFilter, error) {
+	var paginated types.Paginated
+	if err := c.QueryParser(&paginated); err != nil {
+		return nil, nil, err
+	}
+	if paginated.Sort == "" && strings.TrimSpace(paginated.Query) != "" {
+		paginated.Sort = data.SortRelevance
+	}
+	_ = paginated.Validate()
+
+	var filter types.ModuleFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return nil, nil, err
+	}
+
+	return &paginated, &filter, nil
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type ModuleVersionHandler struct {
+	config *viper.Viper
+}
+
+// NewModuleVersionHandler crea un nuevo handler para el borrador y las versiones publicadas de los módulos.
+func NewModuleVersionHandler(config *viper.Viper) *ModuleVersionHandler {
+	return &ModuleVersionHandler{
+		config: config,
+	}
+}
+
+// PreviewModule recupera el borrador del módulo con sus preguntas para revisarlo antes de publicarlo.
+func (h *ModuleVersionHandler) PreviewModule(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	preview, err := data.GetModulePreview(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Módulo no encontrado"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(preview)
+}
+
+// PublishModule publica el borrador del módulo como una nueva versión, los nuevos test usan esta versión.
+func (h *ModuleVersionHandler) PublishModule(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqPublishModule
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validación de datos",
+			"data":    resp,
+		})
+	}
+
+	version, err := data.PublishModule(uint(id), claims.UserAPI.ID, req.Notes)
+	if errors.Is(err, data.ErrModuleWithoutQuestions) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Módulo no encontrado"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	versionAPI := data.ModuleVersionToAPI(*version)
+	recordAudit(c, data.AuditModulePublished, "module", uint(id), nil, versionAPI)
+
+	return c.Status(fiber.StatusCreated).JSON(versionAPI)
+}
+
+// GetVersions lista las versiones publicadas del módulo.
+func (h *ModuleVersionHandler) GetVersions(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	versions, err := data.GetModuleVersions(uint(id))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(data.ModuleVersionsToAPI(versions))
+}
+
+// GetVersion recupera una versión publicada del módulo con sus preguntas.
+func (h *ModuleVersionHandler) GetVersion(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	number, err := c.ParamsInt("version")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	version, err := data.GetModuleVersion(uint(id), number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Versión no encontrada"})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(version)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OwnershipCheck resuelve el recurso de la ruta y revisa si el usuario puede modificarlo.
+type OwnershipCheck func(c *fiber.Ctx, userID uint) (bool, error)
+
+var errInvalidParam = errors.New("el id no es valido")
+
+// RequireOwnership controla que el usuario sea dueño del recurso, con el permiso resources.manage_all se tiene acceso a todo.
+// Una API key no es dueña de recursos, accede a todos los de la ruta si tiene el permiso que la ruta declaró.
+func RequireOwnership(check OwnershipCheck) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		claims := utils.GetClaims(c)
+		if claims.HasPermission(data.PermResourcesManageAll) || c.Locals(apiKeyScopeLocal) == true {
+			return c.Next()
+		}
+
+		ok, err := check(c, claims.UserAPI.ID)
+		if err != nil {
+			if errors.Is(err, data.ErrResourceNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			if errors.Is(err, errInvalidParam) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "No autorizado"})
+		}
+
+		return c.Next()
+	}
+}
+
+// ModuleOwner el usuario puede modificar el módulo indicado en el parámetro, es el dueño o un colaborador con rol de editor.
+func ModuleOwner(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		moduleID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.CanManageModule(userID, uint(moduleID))
+	}
+}
+
+// ModuleViewer el usuario puede ver el borrador del módulo, es el dueño o cualquier colaborador.
+func ModuleViewer(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		moduleID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.CanViewModule(userID, uint(moduleID))
+	}
+}
+
+// ModuleCreator el usuario es el dueño del módulo, solo él gestiona los colaboradores y lo transfiere.
+func ModuleCreator(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		moduleID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.IsModuleOwner(userID, uint(moduleID))
+	}
+}
+
+// LearningPathOwner el usuario creó la ruta de aprendizaje indicada en el parámetro.
+func LearningPathOwner(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		pathID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.CanManageLearningPath(userID, uint(pathID))
+	}
+}
+
+// QuestionOwner la pregunta pertenece al módulo y el usuario es dueño del módulo.
+func QuestionOwner(moduleParam, questionParam string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		moduleID, err := c.ParamsInt(moduleParam)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		questionID, err := c.ParamsInt(questionParam)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.CanManageQuestion(userID, uint(moduleID), uint(questionID))
+	}
+}
+
+// ClassOwner el usuario creó la clase o es el profesor asignado.
+func ClassOwner(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		classID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.CanManageClass(userID, uint(classID))
+	}
+}
+
+// GuardianOf el usuario es representante del estudiante indicado en el parámetro.
+func GuardianOf(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		studentID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.IsGuardianOf(userID, uint(studentID))
+	}
+}
+
+// TestOwner el test pertenece al estudiante.
+func TestOwner(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		testID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.IsTestOwner(userID, uint(testID))
+	}
+}
+
+// TestViewer el test pertenece al estudiante o el usuario es dueño o colaborador del módulo del test.
+func TestViewer(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		testID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.CanViewTest(userID, uint(testID))
+	}
+}
+
+// AnswerUserOwner la respuesta pertenece a un test del estudiante.
+func AnswerUserOwner(param string) OwnershipCheck {
+	return func(c *fiber.Ctx, userID uint) (bool, error) {
+		answerUserID, err := c.ParamsInt(param)
+		if err != nil {
+			return false, errInvalidParam
+		}
+		return data.IsAnswerUserOwner(userID, uint(answerUserID))
+	}
+}
@@ -59,6 +59,8 @@ func (h *QuestionHandler) RegisterQuestionForModule(c *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(c, data.AuditQuestionCreate, "question", questionEntidad.ID, nil, questionEntidad)
+
 	return c.JSON(questionEntidad)
 }
 
@@ -95,6 +97,8 @@ func (h *QuestionHandler) DeleteQuestion(c *fiber.Ctx) error {
 		})
 	}
 
+	before := questionForAudit(uint(idquestion))
+
 	err = data.DeleteQuestion(uint(idquestion))
 
 	if err != nil {
@@ -104,6 +108,8 @@ func (h *QuestionHandler) DeleteQuestion(c *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(c, data.AuditQuestionDelete, "question", uint(idquestion), before, nil)
+
 	return c.SendStatus(fiber.StatusOK)
 }
 
@@ -126,6 +132,19 @@ func (h *QuestionHandler) UpdateQuestion(c *fiber.Ctx) error {
 
 	question.ID = uint(idquestion)
 
+	// la pregunta se mantiene en el módulo de la ruta.
+	idmodule, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": "error",
+			"error":   "Error al recuperar el id del modulo",
+		})
+	}
+	moduleID := uint(idmodule)
+	question.ModuleID = &moduleID
+
+	before := questionForAudit(question.ID)
+
 	err = data.UpdateQuestion(question)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -134,6 +153,8 @@ func (h *QuestionHandler) UpdateQuestion(c *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(c, data.AuditQuestionUpdate, "question", question.ID, before, questionForAudit(question.ID))
+
 	return c.SendStatus(fiber.StatusOK)
 }
 
@@ -187,3 +208,13 @@ func (h *QuestionHandler) GetQuestionByID(c *fiber.Ctx) error {
 
 	return c.JSON(questionAPI)
 }
+
+// questionForAudit recupera la pregunta para guardarla en la auditoría.
+func questionForAudit(id uint) *types.Question {
+	question, err := data.GetQuestionByID(id)
+	if err != nil {
+		return nil
+	}
+	questionAPI := data.QuestionToAPI(*question)
+	return &questionAPI
+}
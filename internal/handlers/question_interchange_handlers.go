@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	questionFormatGIFT = "gift"
+	questionFormatQTI  = "qti"
+)
+
+// tamaño máximo del archivo con el banco de preguntas.
+const questionBankMaxSize = 10 << 20
+
+type QuestionInterchangeHandler struct {
+	config *viper.Viper
+}
+
+// NewQuestionInterchangeHandler crea un nuevo handler para importar y exportar preguntas en formato GIFT (Moodle) y QTI 2.1.
+func NewQuestionInterchangeHandler(config *viper.Viper) *QuestionInterchangeHandler {
+	return &QuestionInterchangeHandler{
+		config: config,
+	}
+}
+
+// ExportQuestions descarga las preguntas del borrador del módulo en el formato indicado (gift o qti).
+// Las preguntas que no se pueden representar se cuentan en la cabecera X-Conversion-Issues, con report=true se
+// devuelve el detalle en lugar del archivo.
+func (h *QuestionInterchangeHandler) ExportQuestions(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	format := strings.ToLower(c.Query("format", questionFormatGIFT))
+	if format != questionFormatGIFT && format != questionFormatQTI {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El formato debe ser gift o qti",
+		})
+	}
+
+	module, err := data.ModuleByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Módulo no encontrado"})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	questions, err := data.GetDraftQuestionsForExport(module.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var content []byte
+	var issues []types.QuestionConversionIssue
+	var fileName, contentType string
+	name := fmt.Sprintf("preguntas_%s_%s", module.Code, time.Now().Format("20060102"))
+	if format == questionFormatGIFT {
+		var text string
+		text, issues = utils.ExportGIFT(module.Title, questions)
+		content = []byte(text)
+		fileName, contentType = name+".gift", fiber.MIMETextPlainCharsetUTF8
+	} else {
+		content, issues, err = utils.ExportQTI(module.Title, questions)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		fileName, contentType = name+".zip", "application/zip"
+	}
+
+	if c.QueryBool("report") {
+		return c.JSON(fiber.Map{
+			"format":    format,
+			"questions": len(questions),
+			"exported":  len(questions) - countIssues(issues, types.ConflictError),
+			"issues":    issues,
+		})
+	}
+
+	c.Set("X-Conversion-Issues", strconv.Itoa(len(issues)))
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	return c.Send(content)
+}
+
+// ImportQuestions agrega al borrador del módulo las preguntas de un archivo GIFT (.gift, .txt) o QTI 2.1 (.xml, .zip).
+// Las preguntas que no se pueden convertir se omiten y se reportan, con dry_run=true solo se revisa el archivo.
+func (h *QuestionInterchangeHandler) ImportQuestions(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "El archivo es requerido"})
+	}
+	if fileHeader.Size > questionBankMaxSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"status": "error", "message": "El archivo no puede pesar más de 10MB"})
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+		case ".gift", ".txt":
+			format = questionFormatGIFT
+		case ".xml", ".zip":
+			format = questionFormatQTI
+		}
+	}
+	if format != questionFormatGIFT && format != questionFormatQTI {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No se reconoce el formato del archivo, indica format=gift o format=qti",
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	report := types.QuestionImportResult{
+		DryRun: c.QueryBool("dry_run"),
+		Format: format,
+	}
+	if format == questionFormatGIFT {
+		report.Questions, report.Issues = utils.ImportGIFT(content)
+	} else {
+		report.Questions, report.Issues, err = utils.ImportQTI(fileHeader.Filename, content)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+	}
+
+	if len(report.Questions) == 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	}
+
+	if err := data.ImportQuestionsToModule(uint(id), &report); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error al importar las preguntas",
+		})
+	}
+	if report.DryRun {
+		return c.JSON(report)
+	}
+
+	recordAudit(c, data.AuditQuestionsImport, "module", uint(id), nil, fiber.Map{
+		"format":   format,
+		"file":     fileHeader.Filename,
+		"imported": report.Imported,
+		"issues":   len(report.Issues),
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(report)
+}
+
+func countIssues(issues []types.QuestionConversionIssue, severity string) int {
+	count := 0
+	for _, issue := range issues {
+		if issue.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
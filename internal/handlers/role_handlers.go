@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct {
+	config *viper.Viper
+}
+
+// NewRoleHandler crea un nuevo handler para la administración de roles y permisos.
+func NewRoleHandler(config *viper.Viper) *RoleHandler {
+	return &RoleHandler{
+		config: config,
+	}
+}
+
+// GetRoles lista los roles con sus permisos.
+func (h *RoleHandler) GetRoles(c *fiber.Ctx) error {
+	roles, err := data.GetRoles()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(roles)
+}
+
+// GetPermissions lista el catálogo de permisos.
+func (h *RoleHandler) GetPermissions(c *fiber.Ctx) error {
+	permissions, err := data.GetPermissions()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(permissions)
+}
+
+// CreateRole registra un nuevo rol, por ejemplo ayudante de cátedra o coordinador.
+func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
+	var role types.Role
+	if err := c.BodyParser(&role); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	resp, err := types.Validate(&role)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
+		})
+	}
+
+	if data.RoleExists(role.Name) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El rol ya existe",
+		})
+	}
+
+	created, err := data.CreateRole(role)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(c, data.AuditRoleCreated, "role", created.ID, nil, created)
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// UpdateRole actualiza la descripción y los permisos de un rol.
+func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var role types.Role
+	if err := c.BodyParser(&role); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	before, _ := data.GetRoleByID(uint(id))
+
+	updated, err := data.UpdateRole(uint(id), role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(c, data.AuditRoleUpdated, "role", uint(id), before, updated)
+
+	return c.JSON(updated)
+}
+
+// DeleteRole elimina un rol sin usuarios asignados.
+func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	before, _ := data.GetRoleByID(uint(id))
+
+	err = data.DeleteRole(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(c, data.AuditRoleDeleted, "role", uint(id), before, nil)
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// SetUserRole asigna un rol a un usuario, los permisos se aplican al renovar el JWT.
+func (h *RoleHandler) SetUserRole(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var req types.ReqUserRole
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
+		})
+	}
+
+	before := data.AuditUserStates(uint(id))
+
+	err = data.SetUserRole(uint(id), req.TypeUser)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(c, data.AuditUserRole, "user", uint(id), before[uint(id)], data.AuditUserStates(uint(id))[uint(id)])
+
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type SessionHandler struct {
+	config *viper.Viper
+}
+
+// NewSessionHandler crea un nuevo handler para las sesiones del usuario.
+func NewSessionHandler(config *viper.Viper) *SessionHandler {
+	return &SessionHandler{
+		config: config,
+	}
+}
+
+// GetSessions lista las sesiones activas del usuario, indicando la sesión actual.
+func (h *SessionHandler) GetSessions(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	sessions, err := data.GetUserSessions(claims.UserAPI.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(data.SessionsToAPI(sessions, claims.SessionID))
+}
+
+// RevokeSession cierra una sesión del usuario, por ejemplo la de un computador compartido.
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	err = data.RevokeSession(claims.UserAPI.ID, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "La sesión no existe",
+			})
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Sesión cerrada"})
+}
+
+// RevokeAllSessions cierra todas las sesiones del usuario, con except_current=true se conserva la sesión actual.
+func (h *SessionHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var exceptID uint
+	if c.QueryBool("except_current", false) {
+		exceptID = claims.SessionID
+	}
+
+	err := data.RevokeUserSessions(claims.UserAPI.ID, exceptID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Sesiones cerradas"})
+}
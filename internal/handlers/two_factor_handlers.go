@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"Proyectos-UTEQ/api-ortografia/internal/utils"
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+type TwoFactorHandler struct {
+	config *viper.Viper
+}
+
+// NewTwoFactorHandler crea un nuevo handler para la verificación en dos pasos.
+func NewTwoFactorHandler(config *viper.Viper) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		config: config,
+	}
+}
+
+// Setup genera un nuevo secreto TOTP y la uri para el código QR, se activa al confirmar un código.
+func (h *TwoFactorHandler) Setup(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	err = data.SetTwoFactorSecret(claims.UserAPI.ID, secret)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(types.TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(h.config.GetString("APP_TOTP_ISSUER"), claims.UserAPI.Email, secret),
+	})
+}
+
+// Enable activa la verificación en dos pasos con el primer código de la app y entrega los códigos de recuperación.
+func (h *TwoFactorHandler) Enable(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqTwoFactorCode
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	secret, enabled, err := data.GetTwoFactorSecret(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	if enabled || secret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Primero debes generar el código QR",
+		})
+	}
+
+	step, ok := utils.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok || !data.UseTwoFactorStep(claims.UserAPI.ID, step) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El código no es valido",
+		})
+	}
+
+	codes, hashes, err := h.recoveryCodes()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	err = data.EnableTwoFactor(claims.UserAPI.ID, hashes)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// el token anterior puede tener pendiente la activación, se entrega uno nuevo.
+	user, err := data.GetUserByID(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	token, err := generateAccessToken(h.config, user, claims.SessionID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":         "success",
+		"recovery_codes": codes,
+		"token":          token,
+	})
+}
+
+// Disable desactiva la verificación en dos pasos, no se permite si el rol la exige.
+func (h *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqTwoFactorCode
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if data.IsTwoFactorRequired(claims.UserAPI.TypeUser) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "La verificación en dos pasos es obligatoria para tu rol",
+		})
+	}
+
+	if !h.verifyCode(claims.UserAPI.ID, req.Code) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El código no es valido",
+		})
+	}
+
+	err := data.DisableTwoFactor(claims.UserAPI.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación del usuario.
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqTwoFactorCode
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !h.verifyCode(claims.UserAPI.ID, req.Code) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El código no es valido",
+		})
+	}
+
+	codes, hashes, err := h.recoveryCodes()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	err = data.ReplaceRecoveryCodes(claims.UserAPI.ID, hashes)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":         "success",
+		"recovery_codes": codes,
+	})
+}
+
+// HandlerSignin segundo paso del inicio de sesión, valida el código TOTP o un código de recuperación.
+func (h *TwoFactorHandler) HandlerSignin(c *fiber.Ctx) error {
+	var req types.ReqTwoFactorSignin
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
+		})
+	}
+
+	userID, err := parseTwoFactorChallenge(h.config, req.ChallengeToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token no valido"})
+	}
+
+	// limitamos los intentos de código por usuario.
+	key := fmt.Sprintf("2fa:user:%d", userID)
+	if data.CountThrottleEvents(key, h.config.GetDuration("APP_TWO_FACTOR_CHALLENGE_TTL")) >= h.config.GetInt64("APP_LOGIN_MAX_ATTEMPTS") {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"status": "error", "message": "Demasiados intentos fallidos, intenta más tarde"})
+	}
+
+	if !h.verifyCode(userID, req.Code) {
+		_ = data.RegisterThrottleEvent(key)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "El código no es valido"})
+	}
+	_ = data.ClearThrottleEvents(key)
+
+	user, err := data.GetUserByID(userID)
+	if err != nil || user.Status != string(data.Actived) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token no valido"})
+	}
+
+	token, refreshToken, err := generateTokens(h.config, user, c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al generar el token", "data": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"user":          user,
+	})
+}
+
+// GetPolicies recupera las políticas de verificación en dos pasos por rol.
+func (h *TwoFactorHandler) GetPolicies(c *fiber.Ctx) error {
+	policies, err := data.GetTwoFactorPolicies()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(policies)
+}
+
+// SetPolicy establece si la verificación en dos pasos es obligatoria para un rol.
+func (h *TwoFactorHandler) SetPolicy(c *fiber.Ctx) error {
+	var policy types.TwoFactorPolicy
+	if err := c.BodyParser(&policy); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	if !data.RoleExists(policy.TypeUser) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "El rol no existe",
+		})
+	}
+
+	err := data.SetTwoFactorPolicy(policy.TypeUser, policy.Required)
+	if errors.Is(err, data.ErrTwoFactorNotAllowed) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(policy)
+}
+
+// verifyCode valida un código TOTP o un código de recuperación del usuario.
+func (h *TwoFactorHandler) verifyCode(userID uint, code string) bool {
+	secret, enabled, err := data.GetTwoFactorSecret(userID)
+	if err != nil || !enabled {
+		return false
+	}
+
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now()); ok {
+		return data.UseTwoFactorStep(userID, step)
+	}
+
+	return data.UseRecoveryCode(userID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+}
+
+// recoveryCodes genera los códigos de recuperación y sus hashes.
+func (h *TwoFactorHandler) recoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(10)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// generateTwoFactorChallenge genera el token temporal del primer paso del inicio de sesión,
+// se firma con una clave distinta para que no pueda utilizarse como JWT de acceso.
+func generateTwoFactorChallenge(config *viper.Viper, userID uint) (string, error) {
+	claims := types.TwoFactorChallengeClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.GetDuration("APP_TWO_FACTOR_CHALLENGE_TTL"))),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(twoFactorChallengeKey(config))
+}
+
+func parseTwoFactorChallenge(config *viper.Viper, tokenString string) (uint, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &types.TwoFactorChallengeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return twoFactorChallengeKey(config), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(*types.TwoFactorChallengeClaims)
+	if !ok || !token.Valid || claims.UserID == 0 {
+		return 0, fmt.Errorf("token no valido")
+	}
+	return claims.UserID, nil
+}
+
+func twoFactorChallengeKey(config *viper.Viper) []byte {
+	return []byte(config.GetString("APP_JWT_SECRET") + ":2fa")
+}
@@ -5,12 +5,16 @@ import (
 	"Proyectos-UTEQ/api-ortografia/internal/services"
 	"Proyectos-UTEQ/api-ortografia/internal/utils"
 	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/viper"
 )
 
@@ -36,41 +40,177 @@ func (h *UserHandler) HandlerSignin(c *fiber.Ctx) error {
 		})
 	}
 
+	// limitamos los intentos fallidos por ip.
+	ipKey := "login:ip:" + c.IP()
+	if data.CountThrottleEvents(ipKey, h.config.GetDuration("APP_LOGIN_IP_WINDOW")) >= h.config.GetInt64("APP_LOGIN_MAX_ATTEMPTS_PER_IP") {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"status": "error", "message": "Demasiados intentos fallidos, intenta más tarde"})
+	}
+
 	// realizamos la autenticacion del usuario
-	user, ok, err := data.Login(login)
+	user, ok, err := data.Login(login, h.loginPolicy())
 
 	if !ok || err != nil {
+		_ = data.RegisterThrottleEvent(ipKey)
+
+		switch {
+		case errors.Is(err, data.ErrAccountLockedNow):
+			go h.notifyAccountLocked(login.Email)
+			fallthrough
+		case errors.Is(err, data.ErrAccountLocked), errors.Is(err, data.ErrLoginTooSoon):
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"status": "error", "message": "Error al iniciar sesion", "data": err.Error()})
+		}
+
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Error al iniciar sesion", "data": err.Error()})
 	}
 
-	if user.URLAvatar == "" {
-		user.URLAvatar = fmt.Sprintf("https://ui-avatars.com/api/?name=%s&background=5952A2&color=fff&size=128", user.FirstName)
-	} else {
-		user.URLAvatar = h.config.GetString("APP_HOST") + user.URLAvatar
+	// con la verificación en dos pasos activada se entrega un token temporal para el segundo paso.
+	if user.TwoFactorEnabled {
+		challenge, err := generateTwoFactorChallenge(h.config, user.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al generar el token", "data": err.Error()})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
 	}
 
-	// generá el JWT para el usuario.
-	claims := types.UserClaims{
-		UserAPI: *user,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		},
+	// generá el JWT y el refresh token para el usuario.
+	ss, refreshToken, err := generateTokens(h.config, user, c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al generar el token", "data": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"token":         ss,
+		"refresh_token": refreshToken,
+		"user":          user,
+	})
+}
+
+// loginPolicy política de intentos fallidos para el inicio de sesión.
+func (h *UserHandler) loginPolicy() data.LoginPolicy {
+	return data.LoginPolicy{
+		MaxAttempts: h.config.GetInt("APP_LOGIN_MAX_ATTEMPTS"),
+		Lockout:     h.config.GetDuration("APP_LOGIN_LOCKOUT"),
+		BaseDelay:   h.config.GetDuration("APP_LOGIN_BASE_DELAY"),
+		MaxDelay:    h.config.GetDuration("APP_LOGIN_MAX_DELAY"),
+	}
+}
+
+// notifyAccountLocked notifica al usuario que su cuenta fue bloqueada por intentos fallidos.
+func (h *UserHandler) notifyAccountLocked(email string) {
+	ok, user := data.ExisteEmail(email)
+	if !ok {
+		return
 	}
 
-	secret := h.config.GetString("APP_JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	message := fmt.Sprintf(
+		"Hola, %s. Tu cuenta fue bloqueada temporalmente por %s debido a varios intentos fallidos de inicio de sesión. Si no fuiste tú, te recomendamos cambiar tu contraseña.",
+		user.FirstName, h.config.GetDuration("APP_LOGIN_LOCKOUT"))
+	notifyUser(h.config, user.Email, user.TelegramID, "Cuenta bloqueada temporalmente", message)
+}
+
+// HandlerRefreshToken renueva el JWT de acceso, el refresh token utilizado se revoca y se entrega uno nuevo.
+func (h *UserHandler) HandlerRefreshToken(c *fiber.Ctx) error {
+	var req types.RefreshToken
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Revisa tu solicitud",
+		})
+	}
 
-	ss, err := token.SignedString([]byte(secret))
+	refreshToken, hash, err := utils.GenerateToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al generar el token", "data": err.Error()})
+	}
+
+	// rotamos el refresh token.
+	userID, sessionID, err := data.RotateRefreshToken(utils.HashToken(req.RefreshToken), hash, time.Now().Add(h.config.GetDuration("APP_JWT_REFRESH_TTL")), c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token no valido", "data": err.Error()})
+	}
+
+	// recuperamos los datos actualizados del usuario.
+	user, err := data.GetUserByID(userID)
+	if err != nil || user.Status != string(data.Actived) {
+		_ = data.RevokeUserSessions(userID, 0)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Token no valido"})
+	}
+
+	ss, err := generateAccessToken(h.config, user, sessionID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al generar el token", "data": err.Error()})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"token": ss,
-		"user":  user,
+		"token":         ss,
+		"refresh_token": refreshToken,
+		"user":          user,
 	})
 }
 
+// HandlerLogout cierra la sesión del usuario revocando el refresh token.
+func (h *UserHandler) HandlerLogout(c *fiber.Ctx) error {
+	var req types.RefreshToken
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Revisa tu solicitud",
+		})
+	}
+
+	err := data.RevokeRefreshToken(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Sesión cerrada"})
+}
+
+// generateAccessToken genera el JWT de acceso de la sesión con los permisos del rol, indicando si el usuario debe activar la verificación en dos pasos.
+func generateAccessToken(config *viper.Viper, user *types.UserAPI, sessionID uint) (string, error) {
+	permissions, err := data.GetRolePermissions(user.TypeUser)
+	if err != nil {
+		return "", err
+	}
+
+	claims := types.UserClaims{
+		UserAPI:          *user,
+		SessionID:        sessionID,
+		Permissions:      permissions,
+		TwoFactorPending: !user.TwoFactorEnabled && data.IsTwoFactorRequired(user.TypeUser),
+	}
+	return utils.GenerateAccessToken(config, claims)
+}
+
+// generateTokens inicia una sesión en el dispositivo y genera el JWT de acceso y el refresh token para el usuario.
+func generateTokens(config *viper.Viper, user *types.UserAPI, userAgent, ip string) (string, string, error) {
+	expiresAt := time.Now().Add(config.GetDuration("APP_JWT_REFRESH_TTL"))
+	session, err := data.CreateSession(user.ID, userAgent, ip, expiresAt)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := generateAccessToken(config, user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, hash, err := utils.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = data.SaveRefreshToken(user.ID, session.ID, hash, expiresAt)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
 // HandlerSignup crea un nuevo usuario.
 func (h *UserHandler) HandlerSignup(c *fiber.Ctx) error {
 
@@ -104,12 +244,87 @@ func (h *UserHandler) HandlerSignup(c *fiber.Ctx) error {
 		})
 	}
 
+	// enviamos el correo de verificación.
+	err = h.sendVerificationEmail(user.ID, user.Email, user.FirstName)
+	if err != nil {
+		log.Println("Error al enviar el correo de verificación", err)
+	}
+
 	// convertir los datos a un usuario api
 	result := data.UserToAPI(*user)
 
-	return c.JSON(fiber.Map{"status": "success", "message": "User created", "data": result})
+	return c.JSON(fiber.Map{"status": "success", "message": "Usuario creado, revisa tu correo electrónico para verificar tu cuenta", "data": result})
 }
 
+// HandlerVerifyEmail verifica el correo del usuario con el token enviado al registrarse.
+func (h *UserHandler) HandlerVerifyEmail(c *fiber.Ctx) error {
+	var req types.VerifyEmail
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Revisa tu solicitud",
+		})
+	}
+
+	user, err := data.VerifyEmail(utils.HashToken(req.Token))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	// los profesores y administradores quedan en la cola de aprobación.
+	if user.Status == data.PendingApproval {
+		go notifyAdminsPendingApproval(h.config, user)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Correo verificado", "data": data.UserToAPI(*user)})
+}
+
+// HandlerResendVerification reenvía el correo de verificación, la respuesta es la misma exista o no el correo.
+func (h *UserHandler) HandlerResendVerification(c *fiber.Ctx) error {
+	var req types.ResetPassword
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Revisa tu solicitud",
+		})
+	}
+
+	ok, user := data.ExisteEmail(req.Email)
+	if ok && user.Status == string(data.PendingVerification) {
+		err := h.sendVerificationEmail(user.ID, user.Email, user.FirstName)
+		if err != nil {
+			log.Println("Error al enviar el correo de verificación", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Si el correo está pendiente de verificación recibirás un nuevo enlace"})
+}
+
+// sendVerificationEmail genera un nuevo token de verificación y lo envía al correo del usuario.
+func (h *UserHandler) sendVerificationEmail(userID uint, email string, firstName string) error {
+	token, hash, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	err = data.SaveEmailVerification(userID, hash, time.Now().Add(h.config.GetDuration("APP_EMAIL_VERIFICATION_TTL")))
+	if err != nil {
+		return err
+	}
+
+	messageToSend := fmt.Sprintf(
+		"Hola, %s. Haga click en el siguiente enlace para verificar su correo electrónico: %s/auth/verify-email?token=%s",
+		firstName,
+		h.config.GetString("URL_FRONT"), token)
+
+	emailNotifier := services.NewEmailNotifier(h.config, []string{email}, "Verifica tu correo electrónico")
+	return utils.SendNotification(emailNotifier, messageToSend)
+}
+
+// HandlerResetPassword envía el enlace para reestablecer la contraseña, la respuesta es la misma exista o no el correo.
 func (h *UserHandler) HandlerResetPassword(c *fiber.Ctx) error {
 	// requiere el correo electronico
 	var resetPassword types.ResetPassword
@@ -121,66 +336,64 @@ func (h *UserHandler) HandlerResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	ok, user := data.ExisteEmail(resetPassword.Email)
+	email := strings.ToLower(strings.TrimSpace(resetPassword.Email))
+	emailKey := "reset-password:email:" + email
+	ipKey := "reset-password:ip:" + c.IP()
+	window := h.config.GetDuration("APP_RESET_PASSWORD_WINDOW")
 
-	if !ok {
-		return c.Status(500).JSON(fiber.Map{
+	// limitamos la cantidad de solicitudes por correo y por ip.
+	if data.CountThrottleEvents(emailKey, window) >= h.config.GetInt64("APP_RESET_PASSWORD_MAX_PER_EMAIL") ||
+		data.CountThrottleEvents(ipKey, window) >= h.config.GetInt64("APP_RESET_PASSWORD_MAX_PER_IP") {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 			"status":  "error",
-			"message": "El correo no existe en la base de datos",
+			"message": "Demasiadas solicitudes, intenta más tarde",
 		})
 	}
+	_ = data.RegisterThrottleEvent(emailKey)
+	_ = data.RegisterThrottleEvent(ipKey)
 
-	// Generar el jwt para el usuario
-	claims := types.UserClaims{
-		UserAPI: user,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		},
-	}
+	response := fiber.Map{"status": "success", "message": "Si el correo está registrado recibirás un enlace para reestablecer tu contraseña"}
 
-	secret := h.config.GetString("APP_JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	ok, user := data.ExisteEmail(strings.TrimSpace(resetPassword.Email))
+	if !ok || user.Status == string(data.Blocked) {
+		return c.JSON(response)
+	}
 
-	ss, err := token.SignedString([]byte(secret))
+	// Generamos el token opaco, en la db solo se guarda el hash.
+	token, hash, err := utils.GenerateToken()
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al generar el token", "data": err.Error()})
+		log.Println("Error al generar el token", err)
+		return c.JSON(response)
 	}
 
-	// guardamos en la db estos datos
-	err = data.SaveResetPassword(user.ID, resetPassword.Email, ss)
+	err = data.SaveResetPassword(user.ID, user.Email, hash, time.Now().Add(h.config.GetDuration("APP_RESET_PASSWORD_TTL")), c.IP())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error al guardar el token", "data": err.Error()})
+		log.Println("Error al guardar el token", err)
+		return c.JSON(response)
 	}
 
+	resetURL := fmt.Sprintf("%s/auth/forgot-password?token=%s", h.config.GetString("URL_FRONT"), token)
+
 	// make message to send
 	messageToSend := fmt.Sprintf(
-		`Hola, %s %s. Haga click en el siguiente enlace para reestablecer su contraseña: %s/auth/forgot-password?token=%s`,
+		`Hola, %s %s. Haga click en el siguiente enlace para reestablecer su contraseña: %s`,
 		user.FirstName,
 		user.LastName,
-		h.config.GetString("URL_FRONT"), ss)
+		resetURL)
 
 	emailNotifier := services.NewEmailNotifier(h.config, []string{user.Email}, "Reestablece tu contraseña")
 	telegramNotifier := services.NewTelegramNotifier(h.config, user.TelegramID)
 
-	err = utils.ResetPassword(
-		emailNotifier,
-		messageToSend,
-		fmt.Sprintf("%s/auth/forgot-password/%s", h.config.GetString("URL_FRONT"), ss),
-	)
+	err = utils.ResetPassword(emailNotifier, messageToSend, resetURL)
 	if err != nil {
 		log.Println(err)
-		return c.JSON(fiber.Map{
-			"status":  "error",
-			"message": "Error al enviar el correo electrónico",
-			"data":    err.Error(),
-		})
 	}
-	err = utils.ResetPassword(telegramNotifier, "Presiona el siguiente boton para resetear tu contraseña", "https://app-poliword.onrender.com/auth/forgot-password?token="+ss)
+	err = utils.ResetPassword(telegramNotifier, "Presiona el siguiente boton para resetear tu contraseña", resetURL)
 	if err != nil {
 		log.Println(err)
 	}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Revisa tu correo electronico"})
+	return c.JSON(response)
 }
 
 func (h *UserHandler) HandlerChangePasswordInside(c *fiber.Ctx) error {
@@ -207,6 +420,7 @@ func (h *UserHandler) HandlerChangePasswordInside(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusOK)
 }
 
+// HandlerChangePassword actualiza la contraseña con el token enviado al correo.
 func (h *UserHandler) HandlerChangePassword(c *fiber.Ctx) error {
 	var changePassword types.ChangePassword
 	if err := c.BodyParser(&changePassword); err != nil {
@@ -217,48 +431,27 @@ func (h *UserHandler) HandlerChangePassword(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parsear los datos del token
-	secret := h.config.GetString("APP_JWT_SECRET")
-
-	token, err := jwt.ParseWithClaims(changePassword.Token, &types.UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	resp, err := types.Validate(&changePassword)
 	if err != nil {
-		log.Println("Error al resetear la constraseña", err)
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "El token no es valido",
-		})
-	}
-	claims, ok := token.Claims.(*types.UserClaims)
-	if !ok || !token.Valid {
-		log.Println("Error al resetear la constraseña", err)
-		return c.Status(500).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "El token no es valido",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
 		})
 	}
 
-	// Revisar si el token no ha sido utilizado.
-	used, _, err := data.TokenIsUsed(changePassword.Token)
+	// Validamos el token y lo marcamos como utilizado.
+	userID, err := data.ConsumeResetPassword(utils.HashToken(changePassword.Token))
 	if err != nil {
 		log.Println("Error al resetear la constraseña", err)
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "El token ya fue utilizado",
-		})
-	}
-
-	if used {
-		log.Println("Error al resetear la constraseña", err)
-		return c.Status(500).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "El token ya fue utilizado",
+			"message": err.Error(),
 		})
 	}
 
 	// Actualizar la contraseña.
-	err = data.UpdatePassword(claims.UserAPI.ID, changePassword.Password)
+	err = data.UpdatePassword(userID, changePassword.Password)
 	if err != nil {
 		log.Println("Error al resetear la constraseña", err)
 		return c.Status(500).JSON(fiber.Map{
@@ -266,10 +459,11 @@ func (h *UserHandler) HandlerChangePassword(c *fiber.Ctx) error {
 			"message": "No se pudo actuailzar la contraseña",
 		})
 	}
-	// Establecer que el token ya se utilizo.
-	err = data.SetTokenUsed(changePassword.Token)
+
+	// cerramos las sesiones abiertas con la contraseña anterior.
+	err = data.RevokeUserSessions(userID, 0)
 	if err != nil {
-		fmt.Println(err)
+		log.Println(err)
 	}
 
 	return c.JSON(fiber.Map{"status": "success", "message": "Contraseña actualizada"})
@@ -311,16 +505,150 @@ func (h *UserHandler) HandlerUpdateUser(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusOK)
 }
 
-// GetAllUsers obtiene todos los usuarios
+// GetAllUsers lista los usuarios paginados con filtros por rol, estado, fecha de registro y búsqueda.
 func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
+	paginated, filter, err := userAdminQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	users, details, err := data.GetUsers(paginated, filter)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    data.UsersToAPI(users),
+		"details": details,
+	})
+}
 
-	// Obtenemos todos los usuarios de la DB.
-	users, err := data.GetAllUsers()
+// ExportUsers exporta en CSV los usuarios que cumplen los filtros.
+func (h *UserHandler) ExportUsers(c *fiber.Ctx) error {
+	paginated, filter, err := userAdminQuery(c)
 	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	users, err := data.GetUsersForExport(paginated, filter)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write([]string{"id", "first_name", "last_name", "email", "type_user", "status", "points_earned", "created_at"})
+	for _, user := range users {
+		_ = writer.Write([]string{
+			strconv.FormatUint(uint64(user.ID), 10),
+			csvSafe(user.FirstName),
+			csvSafe(user.LastName),
+			csvSafe(user.Email),
+			string(user.TypeUser),
+			string(user.Status),
+			strconv.Itoa(user.PointsEarned),
+			utils.GetFullDate(user.CreatedAt),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
-	return c.JSON(users)
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="usuarios_%s.csv"`, time.Now().Format("20060102")))
+	return c.Send(buffer.Bytes())
+}
+
+// BulkChangeStatus activa o bloquea varios usuarios a la vez.
+func (h *UserHandler) BulkChangeStatus(c *fiber.Ctx) error {
+	claims := utils.GetClaims(c)
+
+	var req types.ReqBulkStatus
+	if err := c.BodyParser(&req); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	resp, err := types.Validate(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error en la validacion de datos",
+			"data":    resp,
+		})
+	}
+
+	// evitamos que el administrador se bloquee a sí mismo.
+	for _, id := range req.UserIDs {
+		if id == claims.UserAPI.ID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No puedes cambiar tu propio estado",
+			})
+		}
+	}
+
+	action := data.AuditUserBlocked
+	if req.Status == string(data.Actived) {
+		action = data.AuditUserUnblocked
+	}
+	before := data.AuditUserStates(req.UserIDs...)
+
+	// solo se cambia entre activo y bloqueado, los demás usuarios se omiten.
+	updated, skipped, err := data.ChangeStatusBulk(req.UserIDs, req.Status == string(data.Actived))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	after := data.AuditUserStates(updated...)
+	for _, id := range updated {
+		recordAudit(c, action, "user", id, before[id], after[id])
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"updated": len(updated),
+		"skipped": skipped,
+	})
+}
+
+// userAdminQuery recupera la paginación y los filtros de la administración de usuarios.
+func userAdminQuery(c *fiber.Ctx) (*types.Paginated, *types.UserFilter, error) {
+	var paginated types.Paginated
+	if err := c.QueryParser(&paginated); err != nil {
+		return nil, nil, err
+	}
+	_ = paginated.Validate()
+
+	var filter types.UserFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return nil, nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return &paginated, &filter, nil
+}
+
+// csvSafe evita que las hojas de cálculo interpreten el valor como una fórmula.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
 }
 
 func (h *UserHandler) ActiveUser(c *fiber.Ctx) error {
@@ -329,11 +657,15 @@ func (h *UserHandler) ActiveUser(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
+	before := data.AuditUserStates(uint(userID))
+
 	err = data.ChangeStatus(uint(userID), true)
 	if err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
+	recordAudit(c, data.AuditUserApproved, "user", uint(userID), before[uint(userID)], data.AuditUserStates(uint(userID))[uint(userID)])
+
 	return c.SendStatus(fiber.StatusOK)
 }
 
@@ -343,10 +675,33 @@ func (h *UserHandler) BlockedUser(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
+	before := data.AuditUserStates(uint(userID))
+
 	err = data.ChangeStatus(uint(userID), false)
 	if err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
+	recordAudit(c, data.AuditUserBlocked, "user", uint(userID), before[uint(userID)], data.AuditUserStates(uint(userID))[uint(userID)])
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// UnlockUser desbloquea la cuenta de un usuario bloqueada por intentos fallidos.
+func (h *UserHandler) UnlockUser(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	before := data.AuditUserStates(uint(userID))
+
+	err = data.UnlockUser(uint(userID))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordAudit(c, data.AuditUserUnlocked, "user", uint(userID), before[uint(userID)], data.AuditUserStates(uint(userID))[uint(userID)])
+
 	return c.SendStatus(fiber.StatusOK)
 }
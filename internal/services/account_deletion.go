@@ -0,0 +1,26 @@
+package services
+
+import (
+	"Proyectos-UTEQ/api-ortografia/internal/data"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// AccountDeletionWorker anonimiza periódicamente las cuentas cuyo periodo de gracia terminó.
+func AccountDeletionWorker(config *viper.Viper) {
+	ticker := time.NewTicker(config.GetDuration("APP_ACCOUNT_DELETION_INTERVAL"))
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		anonymized, err := data.AnonymizeDueAccounts()
+		if err != nil {
+			log.Println("Error al anonimizar las cuentas", err)
+			continue
+		}
+		if anonymized > 0 {
+			log.Printf("%d cuentas anonimizadas", anonymized)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// AvatarDir carpeta donde se guardan los avatares, se publica junto con el resto de uploads.
+const AvatarDir = "./uploads/avatars"
+
+// AvatarSizes tamaños estándar de los avatares en píxeles.
+var AvatarSizes = []int{64, 128, 256}
+
+// AvatarLargestSize tamaño que se guarda como avatar del usuario.
+const AvatarLargestSize = 256
+
+const (
+	avatarMinSide   = 32
+	avatarMaxPixels = 4096 * 4096
+)
+
+// colores de fondo de los avatares generados, el primero es el color de Poliword.
+var avatarColors = []string{"#5952A2", "#2E86AB", "#C0392B", "#16A085", "#D35400", "#8E44AD", "#27AE60", "#2C3E50"}
+
+// AvatarUserDir carpeta de los avatares de un usuario.
+func AvatarUserDir(userID uint) string {
+	return filepath.Join(AvatarDir, fmt.Sprint(userID))
+}
+
+// IsAvatarSize indica si el tamaño es uno de los tamaños estándar.
+func IsAvatarSize(size int) bool {
+	for _, s := range AvatarSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeAvatar valida y decodifica la imagen subida por el usuario (JPG, PNG o GIF).
+func DecodeAvatar(content []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.New("el archivo debe ser una imagen JPG, PNG o GIF")
+	}
+	if config.Width < avatarMinSide || config.Height < avatarMinSide {
+		return nil, fmt.Errorf("la imagen debe medir al menos %dx%d píxeles", avatarMinSide, avatarMinSide)
+	}
+	// revisamos las dimensiones antes de decodificar para no reservar imágenes enormes en memoria.
+	if config.Width*config.Height > avatarMaxPixels {
+		return nil, errors.New("la imagen es demasiado grande")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.New("la imagen está dañada")
+	}
+	return img, nil
+}
+
+// ResizeAvatar recorta el centro de la imagen en un cuadrado y lo escala al tamaño indicado.
+// Las zonas transparentes se rellenan de blanco porque el avatar se guarda en JPG.
+func ResizeAvatar(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, origin, draw.Over)
+
+	return scaleSquare(square, size)
+}
+
+// scaleSquare escala la imagen promediando los píxeles de origen que cubre cada píxel de destino.
+func scaleSquare(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		y0, y1 := scaleRange(y, side, size)
+		for x := 0; x < size; x++ {
+			x0, x1 := scaleRange(x, side, size)
+
+			var r, g, b, count int
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[offset])
+					g += int(src.Pix[offset+1])
+					b += int(src.Pix[offset+2])
+					offset += 4
+					count++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / count), G: uint8(g / count), B: uint8(b / count), A: 255})
+		}
+	}
+	return dst
+}
+
+// scaleRange rango de píxeles de origen que cubre el píxel de destino, al ampliar se repite el píxel más cercano.
+func scaleRange(i, side, size int) (int, int) {
+	start := i * side / size
+	end := (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// EncodeAvatar codifica el avatar en JPG.
+func EncodeAvatar(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// InitialsAvatarSVG genera el avatar con las iniciales del usuario, el color depende del id
+// para que el mismo usuario tenga siempre el mismo avatar.
+func InitialsAvatarSVG(userID uint, firstName, lastName string, size int) []byte {
+	initials := initial(firstName) + initial(lastName)
+	if initials == "" {
+		initials = "?"
+	}
+	background := avatarColors[int(userID)%len(avatarColors)]
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 100 100">`+
+		`<rect width="100" height="100" fill="%s"/>`+
+		`<text x="50" y="50" dy=".35em" text-anchor="middle" font-family="Arial, Helvetica, sans-serif" font-size="42" fill="#FFFFFF">%s</text>`+
+		`</svg>`, size, size, background, html.EscapeString(initials)))
+}
+
+func initial(name string) string {
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return string(unicode.ToUpper(r))
+		}
+	}
+	return ""
+}
@@ -36,3 +36,16 @@ func GetDateOrNullForString(date *string) *string {
 	dateString := t.Format("02/01/2006")
 	return &dateString
 }
+
+// ParseDateOrNull convierte una fecha con formato 2006-01-02, en caso de estar vacía retorna nil.
+func ParseDateOrNull(date string) (*time.Time, error) {
+	if date == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, fmt.Errorf("la fecha %s es inválida", date)
+	}
+	return &t, nil
+}
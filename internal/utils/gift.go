@@ -0,0 +1,461 @@
+package utils
+
+import (
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// QuestionBlank espacio en blanco de las preguntas de completar.
+const QuestionBlank = "__________"
+
+// importedDifficulty dificultad de las preguntas importadas (escala de 1 a 10), los otros formatos no la indican.
+const importedDifficulty = 5
+
+var (
+	blankPattern   = regexp.MustCompile(`_{3,}`)
+	htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+	giftWeight     = regexp.MustCompile(`^%(-?[0-9.]+)%`)
+)
+
+// caracteres que GIFT exige escapar con una barra invertida.
+var giftEscaper = strings.NewReplacer(`\`, `\\`, `~`, `\~`, `=`, `\=`, `#`, `\#`, `{`, `\{`, `}`, `\}`, `:`, `\:`, "\n", `\n`)
+
+// conversionIssue crea un problema de conversión con el título recortado de la pregunta.
+func conversionIssue(item int, text, severity, message string) types.QuestionConversionIssue {
+	title := strings.Join(strings.Fields(text), " ")
+	if runes := []rune(title); len(runes) > 60 {
+		title = string(runes[:60]) + "..."
+	}
+	return types.QuestionConversionIssue{Item: item, Title: title, Severity: severity, Message: message}
+}
+
+// ExportGIFT convierte las preguntas al formato GIFT de Moodle. Las preguntas de ordenar no existen en GIFT
+// y se reportan, al igual que los datos que se pierden como las pistas.
+func ExportGIFT(title string, questions []types.Question) (string, []types.QuestionConversionIssue) {
+	issues := make([]types.QuestionConversionIssue, 0)
+	var body strings.Builder
+
+	for i, question := range questions {
+		item := i + 1
+		if question.CorrectAnswer == nil {
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictError, "la pregunta no tiene respuesta correcta"))
+			continue
+		}
+
+		var text, answers string
+		switch question.TypeQuestion {
+		case types.QuestionTypeTrueOrFalse:
+			text = giftEscaper.Replace(question.TextRoot)
+			answers = "{F}"
+			if question.CorrectAnswer.TrueOrFalse {
+				answers = "{T}"
+			}
+		case types.QuestionTypeMultiChoiceText:
+			text = giftEscaper.Replace(question.TextRoot)
+			answers = giftChoices(question)
+		case types.QuestionTypeCompleteWord:
+			if len(question.CorrectAnswer.TextToComplete) == 0 {
+				issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictError, "la pregunta no tiene respuesta correcta"))
+				continue
+			}
+			accepted := make([]string, 0, len(question.CorrectAnswer.TextToComplete))
+			for _, answer := range question.CorrectAnswer.TextToComplete {
+				accepted = append(accepted, "="+giftEscaper.Replace(answer))
+			}
+			// la respuesta se inserta en el espacio en blanco, GIFT solo admite un espacio por pregunta.
+			parts := blankPattern.Split(question.TextRoot, -1)
+			if len(parts) > 2 {
+				issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictWarning, "GIFT solo admite un espacio en blanco, los demás se exportan como texto"))
+			}
+			if len(parts) == 1 {
+				text = giftEscaper.Replace(question.TextRoot)
+				answers = "{" + strings.Join(accepted, " ") + "}"
+			} else {
+				text = giftEscaper.Replace(parts[0]) + "{" + strings.Join(accepted, " ") + "}" + giftEscaper.Replace(strings.Join(parts[1:], QuestionBlank))
+			}
+		case types.QuestionTypeOrderWord:
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictError, "GIFT no tiene preguntas de ordenar palabras"))
+			continue
+		default:
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictError, fmt.Sprintf("el tipo de pregunta %s no existe en GIFT", question.TypeQuestion)))
+			continue
+		}
+
+		if question.Options.Hind != "" {
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictWarning, "GIFT no tiene pistas, la pista no se exporta"))
+		}
+
+		fmt.Fprintf(&body, "::P%d:: %s", item, text)
+		if answers != "" {
+			body.WriteString(" " + answers)
+		}
+		body.WriteString("\n\n")
+	}
+
+	// los comentarios de GIFT permiten dejar el reporte en el mismo archivo.
+	var gift strings.Builder
+	fmt.Fprintf(&gift, "// %s\n", strings.ReplaceAll(title, "\n", " "))
+	for _, issue := range issues {
+		fmt.Fprintf(&gift, "// Pregunta %d (%s): %s\n", issue.Item, issue.Severity, issue.Message)
+	}
+	gift.WriteString("\n")
+	gift.WriteString(body.String())
+
+	return gift.String(), issues
+}
+
+// giftChoices opciones de una pregunta de selección, en selección múltiple el puntaje se reparte entre las correctas.
+func giftChoices(question types.Question) string {
+	correct := question.CorrectAnswer.TextOptions
+	var choices strings.Builder
+	choices.WriteString("{\n")
+	for _, option := range question.Options.TextOptions {
+		isCorrect := ContainsString(correct, option)
+		switch {
+		case question.Options.SelectMode == "multiple" && isCorrect:
+			weight := strconv.FormatFloat(100/float64(len(correct)), 'f', -1, 64)
+			if len(weight) > 8 {
+				weight = strconv.FormatFloat(100/float64(len(correct)), 'f', 5, 64)
+			}
+			fmt.Fprintf(&choices, "\t~%%%s%%%s\n", weight, giftEscaper.Replace(option))
+		case question.Options.SelectMode == "multiple":
+			fmt.Fprintf(&choices, "\t~%%-100%%%s\n", giftEscaper.Replace(option))
+		case isCorrect:
+			fmt.Fprintf(&choices, "\t=%s\n", giftEscaper.Replace(option))
+		default:
+			fmt.Fprintf(&choices, "\t~%s\n", giftEscaper.Replace(option))
+		}
+	}
+	choices.WriteString("}")
+	return choices.String()
+}
+
+type giftAnswer struct {
+	correct  bool
+	weight   *float64
+	text     string
+	feedback bool
+}
+
+// ImportGIFT lee las preguntas de un archivo GIFT de Moodle. Verdadero/falso, selección simple y múltiple,
+// respuesta corta y preguntas con un espacio para completar se convierten, los demás tipos se reportan.
+func ImportGIFT(content []byte) ([]types.Question, []types.QuestionConversionIssue) {
+	questions := make([]types.Question, 0)
+	issues := make([]types.QuestionConversionIssue, 0)
+
+	for i, block := range giftBlocks(content) {
+		item := i + 1
+		question, warnings, err := parseGIFTQuestion(block)
+		if err == nil {
+			err = types.ValidateQuestion(question)
+		}
+		if err != nil {
+			issues = append(issues, conversionIssue(item, block, types.ConflictError, err.Error()))
+			continue
+		}
+		for _, warning := range warnings {
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictWarning, warning))
+		}
+		questions = append(questions, *question)
+	}
+
+	return questions, issues
+}
+
+// giftBlocks separa las preguntas del archivo, cada pregunta termina con una línea en blanco.
+func giftBlocks(content []byte) []string {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	blocks := make([]string, 0)
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "$CATEGORY:"):
+			continue
+		default:
+			current = append(current, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+func parseGIFTQuestion(block string) (*types.Question, []string, error) {
+	warnings := make([]string, 0)
+	block = strings.TrimSpace(block)
+
+	// título opcional ::título::
+	if strings.HasPrefix(block, "::") {
+		end := indexUnescaped(block[2:], "::")
+		if end < 0 {
+			return nil, nil, fmt.Errorf("el título de la pregunta no está cerrado")
+		}
+		block = strings.TrimSpace(block[end+4:])
+	}
+
+	isHTML := false
+	if strings.HasPrefix(block, "[") {
+		if end := strings.Index(block, "]"); end > 0 {
+			switch block[1:end] {
+			case "html":
+				isHTML = true
+				block = block[end+1:]
+			case "moodle", "plain", "markdown":
+				block = block[end+1:]
+			}
+		}
+	}
+
+	open := indexUnescaped(block, "{")
+	if open < 0 {
+		return nil, nil, fmt.Errorf("el texto no tiene respuestas, las descripciones no se importan")
+	}
+	closing := indexUnescaped(block[open:], "}")
+	if closing < 0 {
+		return nil, nil, fmt.Errorf("las respuestas no están cerradas con }")
+	}
+	closing += open
+
+	before := giftText(block[:open], isHTML)
+	after := giftText(block[closing+1:], isHTML)
+	answers := strings.TrimSpace(block[open+1 : closing])
+	if indexUnescaped(block[closing+1:], "{") >= 0 {
+		return nil, nil, fmt.Errorf("la pregunta tiene varios grupos de respuestas, solo se admite uno")
+	}
+
+	text := before
+	if after != "" {
+		text = strings.TrimSpace(before + " " + QuestionBlank + " " + after)
+	}
+
+	question := &types.Question{TextRoot: text, Difficulty: importedDifficulty}
+
+	// las retroalimentaciones generales (####) no se importan.
+	if index := indexUnescaped(answers, "####"); index >= 0 {
+		answers = strings.TrimSpace(answers[:index])
+		warnings = append(warnings, "la retroalimentación general no se importa")
+	}
+
+	switch {
+	case answers == "":
+		return nil, nil, fmt.Errorf("las preguntas de ensayo no tienen un equivalente")
+	case strings.HasPrefix(answers, "#"):
+		return nil, nil, fmt.Errorf("las preguntas numéricas no tienen un equivalente")
+	}
+
+	if value, feedback, ok := giftTrueFalse(answers); ok {
+		if feedback {
+			warnings = append(warnings, "la retroalimentación no se importa")
+		}
+		question.TypeQuestion = types.QuestionTypeTrueOrFalse
+		question.CorrectAnswer = &types.Answer{TrueOrFalse: value}
+		return question, warnings, nil
+	}
+
+	items, err := giftAnswers(answers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrong, feedback := 0, false
+	for _, item := range items {
+		if item.correct && strings.Contains(item.text, "->") {
+			return nil, nil, fmt.Errorf("las preguntas de emparejamiento no tienen un equivalente")
+		}
+		if !item.correct && (item.weight == nil || *item.weight <= 0) {
+			wrong++
+		}
+		feedback = feedback || item.feedback
+	}
+	if feedback {
+		warnings = append(warnings, "la retroalimentación de las respuestas no se importa")
+	}
+
+	// solo respuestas correctas: respuesta corta o completar el espacio en blanco.
+	if wrong == 0 && allCorrect(items) {
+		accepted := make([]string, 0, len(items))
+		for _, item := range items {
+			if item.weight != nil && *item.weight < 100 {
+				warnings = append(warnings, fmt.Sprintf("la respuesta %q tenía puntaje parcial, se importa como correcta", item.text))
+			}
+			accepted = append(accepted, item.text)
+		}
+		if after == "" && !blankPattern.MatchString(text) {
+			question.TextRoot = strings.TrimSpace(text + " " + QuestionBlank)
+		}
+		question.TypeQuestion = types.QuestionTypeCompleteWord
+		question.CorrectAnswer = &types.Answer{TextToComplete: accepted}
+		return question, warnings, nil
+	}
+
+	if after != "" {
+		return nil, nil, fmt.Errorf("las preguntas de selección dentro del texto no tienen un equivalente")
+	}
+
+	options := make([]string, 0, len(items))
+	correct := make([]string, 0)
+	usesWeights := false
+	for _, item := range items {
+		options = append(options, item.text)
+		if item.weight != nil {
+			usesWeights = true
+		}
+		if item.correct || (item.weight != nil && *item.weight > 0) {
+			correct = append(correct, item.text)
+		}
+	}
+	if len(correct) == 0 {
+		return nil, nil, fmt.Errorf("la pregunta no tiene respuestas correctas")
+	}
+
+	question.TypeQuestion = types.QuestionTypeMultiChoiceText
+	question.Options = types.Options{SelectMode: "single", TextOptions: options}
+	if usesWeights && len(correct) > 1 {
+		question.Options.SelectMode = "multiple"
+	} else if len(correct) > 1 {
+		warnings = append(warnings, "la pregunta tenía varias respuestas correctas de selección simple, se importa como selección múltiple")
+		question.Options.SelectMode = "multiple"
+	}
+	question.CorrectAnswer = &types.Answer{TextOptions: correct}
+	return question, warnings, nil
+}
+
+func allCorrect(items []giftAnswer) bool {
+	for _, item := range items {
+		if !item.correct {
+			return false
+		}
+	}
+	return true
+}
+
+// giftTrueFalse reconoce las respuestas {T}, {TRUE}, {F} y {FALSE}.
+func giftTrueFalse(answers string) (bool, bool, bool) {
+	value := answers
+	feedback := false
+	if index := indexUnescaped(answers, "#"); index >= 0 {
+		value = strings.TrimSpace(answers[:index])
+		feedback = true
+	}
+	switch value {
+	case "T", "TRUE":
+		return true, feedback, true
+	case "F", "FALSE":
+		return false, feedback, true
+	}
+	return false, false, false
+}
+
+// giftAnswers separa las respuestas que empiezan con = (correcta) o ~ (incorrecta o con puntaje).
+func giftAnswers(answers string) ([]giftAnswer, error) {
+	items := make([]giftAnswer, 0)
+	start := -1
+	add := func(end int) error {
+		if start < 0 {
+			return nil
+		}
+		raw := answers[start:end]
+		item := giftAnswer{correct: raw[0] == '='}
+		raw = strings.TrimSpace(raw[1:])
+		if match := giftWeight.FindStringSubmatch(raw); match != nil {
+			weight, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				return fmt.Errorf("el puntaje %s no es válido", match[1])
+			}
+			item.weight = &weight
+			raw = raw[len(match[0]):]
+		}
+		if index := indexUnescaped(raw, "#"); index >= 0 {
+			raw = raw[:index]
+			item.feedback = true
+		}
+		item.text = giftUnescape(strings.TrimSpace(raw))
+		if item.text == "" {
+			return fmt.Errorf("hay una respuesta vacía")
+		}
+		items = append(items, item)
+		return nil
+	}
+
+	for i := 0; i < len(answers); i++ {
+		switch answers[i] {
+		case '\\':
+			i++
+		case '=', '~':
+			if err := add(i); err != nil {
+				return nil, err
+			}
+			start = i
+		default:
+			if start < 0 && answers[i] != ' ' && answers[i] != '\t' && answers[i] != '\n' {
+				return nil, fmt.Errorf("las respuestas deben empezar con = o ~")
+			}
+		}
+	}
+	if err := add(len(answers)); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("la pregunta no tiene respuestas")
+	}
+	return items, nil
+}
+
+// giftText limpia el texto de la pregunta, en formato html se quitan las etiquetas.
+func giftText(text string, isHTML bool) string {
+	text = giftUnescape(text)
+	if isHTML {
+		text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, " "))
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func giftUnescape(text string) string {
+	var result strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			if text[i] == 'n' {
+				result.WriteByte('\n')
+			} else {
+				result.WriteByte(text[i])
+			}
+			continue
+		}
+		result.WriteByte(text[i])
+	}
+	return result.String()
+}
+
+// indexUnescaped posición de sep que no esté escapado con una barra invertida.
+func indexUnescaped(text, sep string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
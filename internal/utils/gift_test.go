@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripQuestions una pregunta de cada tipo, con la dificultad que reciben las preguntas importadas.
+func roundTripQuestions() map[string]types.Question {
+	return map[string]types.Question{
+		types.QuestionTypeTrueOrFalse: {
+			TextRoot:      "La palabra árbol lleva tilde.",
+			Difficulty:    importedDifficulty,
+			TypeQuestion:  types.QuestionTypeTrueOrFalse,
+			CorrectAnswer: &types.Answer{TrueOrFalse: true},
+		},
+		types.QuestionTypeMultiChoiceText + "/single": {
+			TextRoot:      "¿Cuál está bien escrita?",
+			Difficulty:    importedDifficulty,
+			TypeQuestion:  types.QuestionTypeMultiChoiceText,
+			Options:       types.Options{SelectMode: "single", TextOptions: []string{"casa", "kasa", "caza: cazar"}},
+			CorrectAnswer: &types.Answer{TextOptions: []string{"casa"}},
+		},
+		types.QuestionTypeMultiChoiceText + "/multiple": {
+			TextRoot:      "Selecciona las palabras con v.",
+			Difficulty:    importedDifficulty,
+			TypeQuestion:  types.QuestionTypeMultiChoiceText,
+			Options:       types.Options{SelectMode: "multiple", TextOptions: []string{"vaca", "baca", "vota", "bota"}},
+			CorrectAnswer: &types.Answer{TextOptions: []string{"vaca", "vota"}},
+		},
+		types.QuestionTypeCompleteWord: {
+			TextRoot:      "El " + QuestionBlank + " ladra.",
+			Difficulty:    importedDifficulty,
+			TypeQuestion:  types.QuestionTypeCompleteWord,
+			CorrectAnswer: &types.Answer{TextToComplete: []string{"perro", "can"}},
+		},
+		types.QuestionTypeOrderWord: {
+			TextRoot:      "Ordena la oración.",
+			Difficulty:    importedDifficulty,
+			TypeQuestion:  types.QuestionTypeOrderWord,
+			Options:       types.Options{TextOptions: []string{"ladra", "el", "perro"}},
+			CorrectAnswer: &types.Answer{TextOptions: []string{"el", "perro", "ladra"}},
+		},
+	}
+}
+
+func TestGIFTRoundTrip(t *testing.T) {
+	for name, question := range roundTripQuestions() {
+		t.Run(name, func(t *testing.T) {
+			gift, issues := ExportGIFT("Banco", []types.Question{question})
+
+			if question.TypeQuestion == types.QuestionTypeOrderWord {
+				if len(issues) != 1 || issues[0].Severity != types.ConflictError {
+					t.Fatalf("se esperaba un error por la pregunta de ordenar, issues: %+v", issues)
+				}
+				if questions, _ := ImportGIFT([]byte(gift)); len(questions) != 0 {
+					t.Fatalf("la pregunta de ordenar no debe exportarse, se importaron %+v", questions)
+				}
+				return
+			}
+			if len(issues) != 0 {
+				t.Fatalf("issues al exportar: %+v", issues)
+			}
+
+			questions, issues := ImportGIFT([]byte(gift))
+			if len(issues) != 0 {
+				t.Fatalf("issues al importar: %+v\n%s", issues, gift)
+			}
+			if len(questions) != 1 {
+				t.Fatalf("se esperaba una pregunta y se importaron %d\n%s", len(questions), gift)
+			}
+			if !reflect.DeepEqual(questions[0], question) {
+				t.Fatalf("la pregunta cambió\nesperada: %+v %+v\nobtenida: %+v %+v\n%s",
+					question, *question.CorrectAnswer, questions[0], *questions[0].CorrectAnswer, gift)
+			}
+		})
+	}
+}
+
+func TestGIFTExportHintWarning(t *testing.T) {
+	question := roundTripQuestions()[types.QuestionTypeTrueOrFalse]
+	question.Options.Hind = "Es una palabra grave."
+
+	gift, issues := ExportGIFT("Banco", []types.Question{question})
+	if len(issues) != 1 || issues[0].Severity != types.ConflictWarning {
+		t.Fatalf("se esperaba un aviso por la pista, issues: %+v", issues)
+	}
+	if !strings.Contains(gift, "// Pregunta 1 (warning)") {
+		t.Fatalf("el reporte no está en el archivo:\n%s", gift)
+	}
+}
+
+func TestGIFTImportUnsupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{"descripcion", "Solo es un texto.", "descripciones"},
+		{"ensayo", "Escribe un párrafo. {}", "ensayo"},
+		{"numerica", "¿Cuántas sílabas tiene casa? {#2}", "numéricas"},
+		{"emparejamiento", "Relaciona. {=perro -> can =gato -> felino}", "emparejamiento"},
+		{"seleccion en el texto", "El {=perro ~pero} ladra.", "dentro del texto"},
+		{"varios grupos", "El {=perro} y el {=gato}.", "varios grupos"},
+		{"sin cerrar", "Pregunta {=a ~b", "cerradas"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			questions, issues := ImportGIFT([]byte(test.content))
+			if len(questions) != 0 {
+				t.Fatalf("no se esperaban preguntas, se importaron %+v", questions)
+			}
+			if len(issues) != 1 || issues[0].Severity != types.ConflictError || issues[0].Item != 1 {
+				t.Fatalf("se esperaba un error en la pregunta 1, issues: %+v", issues)
+			}
+			if !strings.Contains(issues[0].Message, test.message) {
+				t.Fatalf("el mensaje %q no menciona %q", issues[0].Message, test.message)
+			}
+		})
+	}
+}
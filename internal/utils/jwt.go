@@ -2,10 +2,23 @@ package utils
 
 import (
 	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
 )
 
 func GetClaims(c *fiber.Ctx) *types.UserClaims {
 	return c.Locals("user").(*types.UserClaims)
 }
+
+// GenerateAccessToken genera el JWT de corta duración para el usuario, si los claims no indican la expiración se usa APP_JWT_ACCESS_TTL.
+func GenerateAccessToken(config *viper.Viper, claims types.UserClaims) (string, error) {
+	if claims.RegisteredClaims.ExpiresAt == nil {
+		claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(config.GetDuration("APP_JWT_ACCESS_TTL")))
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.GetString("APP_JWT_SECRET")))
+}
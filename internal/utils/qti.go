@@ -0,0 +1,569 @@
+package utils
+
+import (
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	qtiNamespace      = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiSchemaLocation = "http://www.imsglobal.org/xsd/imsqti_v2p1 http://www.imsglobal.org/xsd/qti/qtiv2p1/imsqti_v2p1.xsd"
+	qtiMatchCorrect   = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+	qtiMapResponse    = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/map_response"
+	qtiItemType       = "imsqti_item_xmlv2p1"
+)
+
+// límites del paquete QTI para no descomprimir archivos enormes ni miles de preguntas.
+const (
+	qtiMaxItems       = 1000
+	qtiMaxFileSize    = 10 << 20
+	qtiMaxPackageSize = 50 << 20
+)
+
+// textos con los que se reconocen las preguntas de verdadero o falso.
+var (
+	qtiTrueTexts  = []string{"true", "verdadero", "cierto", "v", "t"}
+	qtiFalseTexts = []string{"false", "falso", "f"}
+)
+
+func xmlEscape(text string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(text))
+	return buffer.String()
+}
+
+// ExportQTI convierte las preguntas en un paquete IMS QTI 2.1 (zip con imsmanifest.xml y un archivo por pregunta).
+func ExportQTI(title string, questions []types.Question) ([]byte, []types.QuestionConversionIssue, error) {
+	issues := make([]types.QuestionConversionIssue, 0)
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	var resources strings.Builder
+	for i, question := range questions {
+		item := i + 1
+		identifier := fmt.Sprintf("P%d", item)
+		body, err := qtiItem(identifier, question)
+		if err != nil {
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictError, err.Error()))
+			continue
+		}
+
+		href := "items/" + identifier + ".xml"
+		file, err := archive.Create(href)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := file.Write([]byte(body)); err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(&resources, "    <resource identifier=\"%s\" type=\"%s\" href=\"%s\">\n      <file href=\"%s\"/>\n    </resource>\n", identifier, qtiItemType, href, href)
+	}
+
+	manifest, err := archive.Create("imsmanifest.xml")
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = fmt.Fprintf(manifest, `<?xml version="1.0" encoding="UTF-8"?>
+<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="POLIWORD-MANIFEST">
+  <metadata>
+    <schema>QTIv2.1 Package</schema>
+    <schemaversion>1.0.0</schemaversion>
+  </metadata>
+  <organizations/>
+  <!-- %s -->
+  <resources>
+%s  </resources>
+</manifest>
+`, xmlEscape(strings.ReplaceAll(title, "--", "-")), resources.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buffer.Bytes(), issues, nil
+}
+
+// qtiItem genera el assessmentItem de la pregunta, la pista se exporta como rubricBlock para el estudiante.
+func qtiItem(identifier string, question types.Question) (string, error) {
+	if question.CorrectAnswer == nil {
+		return "", errors.New("la pregunta no tiene respuesta correcta")
+	}
+
+	var declaration, interaction, template string
+	switch question.TypeQuestion {
+	case types.QuestionTypeTrueOrFalse:
+		correct := "false"
+		if question.CorrectAnswer.TrueOrFalse {
+			correct = "true"
+		}
+		declaration = qtiDeclaration("single", "identifier", []string{correct}, nil)
+		interaction = fmt.Sprintf(`    <choiceInteraction responseIdentifier="RESPONSE" shuffle="false" maxChoices="1">
+      <prompt>%s</prompt>
+      <simpleChoice identifier="true">Verdadero</simpleChoice>
+      <simpleChoice identifier="false">Falso</simpleChoice>
+    </choiceInteraction>
+`, xmlEscape(question.TextRoot))
+		template = qtiMatchCorrect
+
+	case types.QuestionTypeMultiChoiceText:
+		cardinality, maxChoices := "single", 1
+		if question.Options.SelectMode == "multiple" {
+			cardinality, maxChoices = "multiple", len(question.Options.TextOptions)
+		}
+		correct := make([]string, 0)
+		var choices strings.Builder
+		for i, option := range question.Options.TextOptions {
+			choiceID := fmt.Sprintf("C%d", i+1)
+			if ContainsString(question.CorrectAnswer.TextOptions, option) {
+				correct = append(correct, choiceID)
+			}
+			fmt.Fprintf(&choices, "      <simpleChoice identifier=\"%s\">%s</simpleChoice>\n", choiceID, xmlEscape(option))
+		}
+		if len(correct) == 0 {
+			return "", errors.New("la respuesta correcta no está entre las opciones")
+		}
+		declaration = qtiDeclaration(cardinality, "identifier", correct, nil)
+		interaction = fmt.Sprintf("    <choiceInteraction responseIdentifier=\"RESPONSE\" shuffle=\"true\" maxChoices=\"%d\">\n      <prompt>%s</prompt>\n%s    </choiceInteraction>\n",
+			maxChoices, xmlEscape(question.TextRoot), choices.String())
+		template = qtiMatchCorrect
+
+	case types.QuestionTypeCompleteWord:
+		accepted := question.CorrectAnswer.TextToComplete
+		if len(accepted) == 0 {
+			return "", errors.New("la pregunta no tiene respuesta correcta")
+		}
+		declaration = qtiDeclaration("single", "string", accepted[:1], accepted)
+		entry := `<textEntryInteraction responseIdentifier="RESPONSE" expectedLength="20"/>`
+		parts := blankPattern.Split(question.TextRoot, 2)
+		if len(parts) == 1 {
+			interaction = fmt.Sprintf("    <p>%s %s</p>\n", xmlEscape(question.TextRoot), entry)
+		} else {
+			interaction = fmt.Sprintf("    <p>%s%s%s</p>\n", xmlEscape(parts[0]), entry, xmlEscape(parts[1]))
+		}
+		template = qtiMapResponse
+
+	case types.QuestionTypeOrderWord:
+		// las palabras repetidas se relacionan por posición con su identificador.
+		used := make([]bool, len(question.Options.TextOptions))
+		correct := make([]string, 0, len(question.CorrectAnswer.TextOptions))
+		for _, word := range question.CorrectAnswer.TextOptions {
+			found := false
+			for i, option := range question.Options.TextOptions {
+				if !used[i] && option == word {
+					used[i], found = true, true
+					correct = append(correct, fmt.Sprintf("W%d", i+1))
+					break
+				}
+			}
+			if !found {
+				return "", fmt.Errorf("la palabra %q del orden correcto no está entre las opciones", word)
+			}
+		}
+		var choices strings.Builder
+		for i, option := range question.Options.TextOptions {
+			fmt.Fprintf(&choices, "      <simpleChoice identifier=\"W%d\">%s</simpleChoice>\n", i+1, xmlEscape(option))
+		}
+		declaration = qtiDeclaration("ordered", "identifier", correct, nil)
+		interaction = fmt.Sprintf("    <orderInteraction responseIdentifier=\"RESPONSE\" shuffle=\"true\">\n      <prompt>%s</prompt>\n%s    </orderInteraction>\n",
+			xmlEscape(question.TextRoot), choices.String())
+		template = qtiMatchCorrect
+
+	default:
+		return "", fmt.Errorf("el tipo de pregunta %s no existe en QTI", question.TypeQuestion)
+	}
+
+	hint := ""
+	if question.Options.Hind != "" {
+		hint = fmt.Sprintf("    <rubricBlock view=\"candidate\">\n      <p>%s</p>\n    </rubricBlock>\n", xmlEscape(question.Options.Hind))
+	}
+
+	title := []rune(strings.Join(strings.Fields(question.TextRoot), " "))
+	if len(title) > 60 {
+		title = append(title[:60], []rune("...")...)
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<assessmentItem xmlns="%s" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="%s" identifier="%s" title="%s" adaptive="false" timeDependent="false">
+%s  <outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>
+  <itemBody>
+%s%s  </itemBody>
+  <responseProcessing template="%s"/>
+</assessmentItem>
+`, qtiNamespace, qtiSchemaLocation, identifier, xmlEscape(string(title)), declaration, hint, interaction, template), nil
+}
+
+// qtiDeclaration declara la respuesta correcta, mapping lista las respuestas aceptadas de los textos.
+func qtiDeclaration(cardinality, baseType string, correct, mapping []string) string {
+	var declaration strings.Builder
+	fmt.Fprintf(&declaration, "  <responseDeclaration identifier=\"RESPONSE\" cardinality=\"%s\" baseType=\"%s\">\n    <correctResponse>\n", cardinality, baseType)
+	for _, value := range correct {
+		fmt.Fprintf(&declaration, "      <value>%s</value>\n", xmlEscape(value))
+	}
+	declaration.WriteString("    </correctResponse>\n")
+	if len(mapping) > 0 {
+		declaration.WriteString("    <mapping defaultValue=\"0\">\n")
+		for _, value := range mapping {
+			fmt.Fprintf(&declaration, "      <mapEntry mapKey=\"%s\" mappedValue=\"1\"/>\n", xmlEscape(value))
+		}
+		declaration.WriteString("    </mapping>\n")
+	}
+	declaration.WriteString("  </responseDeclaration>\n")
+	return declaration.String()
+}
+
+type qtiManifest struct {
+	Resources []struct {
+		Type string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"resources>resource"`
+}
+
+type qtiAssessmentItem struct {
+	XMLName   xml.Name
+	Title     string `xml:"title,attr"`
+	Responses []struct {
+		Identifier  string   `xml:"identifier,attr"`
+		Cardinality string   `xml:"cardinality,attr"`
+		Correct     []string `xml:"correctResponse>value"`
+		MapEntries  []struct {
+			Key   string  `xml:"mapKey,attr"`
+			Value float64 `xml:"mappedValue,attr"`
+		} `xml:"mapping>mapEntry"`
+	} `xml:"responseDeclaration"`
+	Body struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"itemBody"`
+}
+
+type qtiInteraction struct {
+	kind       string
+	response   string
+	maxChoices string
+	prompt     strings.Builder
+	choices    []qtiChoice
+}
+
+type qtiChoice struct {
+	id   string
+	text strings.Builder
+}
+
+// ImportQTI lee las preguntas de un paquete QTI 2.1 (zip) o de un assessmentItem (xml). Las interacciones de
+// selección, orden y texto se convierten, las demás se reportan.
+func ImportQTI(filename string, content []byte) ([]types.Question, []types.QuestionConversionIssue, error) {
+	var files [][]byte
+	if strings.ToLower(path.Ext(filename)) == ".zip" {
+		var err error
+		files, err = qtiPackageItems(content)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		files = [][]byte{content}
+	}
+
+	questions := make([]types.Question, 0)
+	issues := make([]types.QuestionConversionIssue, 0)
+	for i, file := range files {
+		item := i + 1
+		var assessmentItem qtiAssessmentItem
+		if err := xml.Unmarshal(file, &assessmentItem); err != nil {
+			issues = append(issues, conversionIssue(item, "", types.ConflictError, "el XML no es válido"))
+			continue
+		}
+		if assessmentItem.XMLName.Local != "assessmentItem" {
+			issues = append(issues, conversionIssue(item, "", types.ConflictError, fmt.Sprintf("se esperaba un assessmentItem y se encontró %s", assessmentItem.XMLName.Local)))
+			continue
+		}
+
+		question, warnings, err := parseQTIItem(assessmentItem)
+		if err == nil {
+			err = types.ValidateQuestion(question)
+		}
+		if err != nil {
+			issues = append(issues, conversionIssue(item, assessmentItem.Title, types.ConflictError, err.Error()))
+			continue
+		}
+		for _, warning := range warnings {
+			issues = append(issues, conversionIssue(item, question.TextRoot, types.ConflictWarning, warning))
+		}
+		questions = append(questions, *question)
+	}
+
+	return questions, issues, nil
+}
+
+// qtiPackageItems lee los assessmentItem listados en el imsmanifest.xml del paquete.
+func qtiPackageItems(content []byte) ([][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errors.New("el archivo no es un paquete QTI válido")
+	}
+
+	filesByName := make(map[string]*zip.File)
+	for _, file := range reader.File {
+		filesByName[path.Clean(file.Name)] = file
+	}
+
+	manifestFile, ok := filesByName["imsmanifest.xml"]
+	if !ok {
+		return nil, errors.New("el paquete no tiene imsmanifest.xml")
+	}
+	// el límite se descuenta con cada archivo que se descomprime.
+	remaining := int64(qtiMaxPackageSize)
+	manifestContent, err := readZipFile(manifestFile, &remaining)
+	if err != nil {
+		return nil, err
+	}
+	var manifest qtiManifest
+	if err := xml.Unmarshal(manifestContent, &manifest); err != nil {
+		return nil, errors.New("el imsmanifest.xml no es válido")
+	}
+
+	items := make([][]byte, 0)
+	read := make(map[string]bool)
+	for _, resource := range manifest.Resources {
+		if !strings.HasPrefix(resource.Type, "imsqti_item") {
+			continue
+		}
+		// cada archivo se lee una sola vez aunque el manifest lo repita.
+		href := path.Clean(resource.Href)
+		if read[href] {
+			continue
+		}
+		read[href] = true
+		if len(items) == qtiMaxItems {
+			return nil, fmt.Errorf("el paquete tiene más de %d preguntas", qtiMaxItems)
+		}
+
+		file, ok := filesByName[href]
+		if !ok {
+			return nil, fmt.Errorf("el paquete no tiene el archivo %s", resource.Href)
+		}
+		itemContent, err := readZipFile(file, &remaining)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, itemContent)
+	}
+	return items, nil
+}
+
+// readZipFile descomprime el archivo sin superar el tamaño máximo por archivo ni lo que queda del paquete.
+func readZipFile(file *zip.File, remaining *int64) ([]byte, error) {
+	limit := int64(qtiMaxFileSize)
+	if *remaining < limit {
+		limit = *remaining
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, errors.New("el paquete QTI es demasiado grande al descomprimirlo")
+	}
+	*remaining -= int64(len(content))
+	return content, nil
+}
+
+func parseQTIItem(item qtiAssessmentItem) (*types.Question, []string, error) {
+	warnings := make([]string, 0)
+
+	interactions := make([]*qtiInteraction, 0)
+	unsupported := make([]string, 0)
+	var text, hint strings.Builder
+	var current *qtiInteraction
+	var choice *qtiChoice
+	inPrompt, inRubric := false, false
+
+	decoder := xml.NewDecoder(bytes.NewReader(item.Body.Inner))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.New("el cuerpo de la pregunta no es un XML válido")
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name.Local
+			switch {
+			case name == "choiceInteraction" || name == "orderInteraction":
+				current = &qtiInteraction{kind: name, response: xmlAttr(element, "responseIdentifier"), maxChoices: xmlAttr(element, "maxChoices")}
+				interactions = append(interactions, current)
+			case name == "prompt" && current != nil:
+				inPrompt = true
+			case name == "simpleChoice" && current != nil:
+				current.choices = append(current.choices, qtiChoice{id: xmlAttr(element, "identifier")})
+				choice = &current.choices[len(current.choices)-1]
+			case name == "textEntryInteraction":
+				interactions = append(interactions, &qtiInteraction{kind: name, response: xmlAttr(element, "responseIdentifier")})
+				text.WriteString(" " + QuestionBlank + " ")
+			case name == "rubricBlock":
+				inRubric = true
+			case strings.HasSuffix(name, "Interaction"):
+				unsupported = append(unsupported, name)
+				_ = decoder.Skip()
+			case name == "feedbackInline" || name == "feedbackBlock":
+				warnings = append(warnings, "la retroalimentación no se importa")
+				_ = decoder.Skip()
+			case name == "img" || name == "object":
+				warnings = append(warnings, "las imágenes de la pregunta no se importan")
+			default:
+				text.WriteString(" ")
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "choiceInteraction", "orderInteraction":
+				current = nil
+			case "prompt":
+				inPrompt = false
+			case "simpleChoice":
+				choice = nil
+			case "rubricBlock":
+				inRubric = false
+			}
+		case xml.CharData:
+			switch {
+			case choice != nil:
+				choice.text.Write(element)
+			case inPrompt && current != nil:
+				current.prompt.Write(element)
+			case inRubric:
+				hint.Write(element)
+			default:
+				text.Write(element)
+			}
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return nil, nil, fmt.Errorf("la interacción %s no tiene un equivalente", unsupported[0])
+	}
+	if len(interactions) != 1 {
+		return nil, nil, fmt.Errorf("solo se admiten preguntas con una interacción, la pregunta tiene %d", len(interactions))
+	}
+	interaction := interactions[0]
+
+	var correct []string
+	var accepted []string
+	found := false
+	for _, response := range item.Responses {
+		if response.Identifier != interaction.response {
+			continue
+		}
+		found = true
+		correct = response.Correct
+		accepted = append(accepted, response.Correct...)
+		for _, entry := range response.MapEntries {
+			if entry.Value > 0 && !ContainsString(accepted, entry.Key) {
+				accepted = append(accepted, entry.Key)
+			}
+		}
+	}
+	if !found || len(correct) == 0 {
+		return nil, nil, errors.New("la pregunta no declara la respuesta correcta")
+	}
+
+	question := &types.Question{
+		TextRoot:   strings.Join(strings.Fields(interaction.prompt.String()+" "+text.String()), " "),
+		Difficulty: importedDifficulty,
+		Options:    types.Options{Hind: strings.Join(strings.Fields(hint.String()), " ")},
+	}
+
+	choiceTexts := make(map[string]string)
+	options := make([]string, 0, len(interaction.choices))
+	for _, choice := range interaction.choices {
+		choiceText := strings.Join(strings.Fields(choice.text.String()), " ")
+		choiceTexts[choice.id] = choiceText
+		options = append(options, choiceText)
+	}
+	correctTexts := make([]string, 0, len(correct))
+	for _, value := range correct {
+		if interaction.kind == "textEntryInteraction" {
+			break
+		}
+		choiceText, ok := choiceTexts[strings.TrimSpace(value)]
+		if !ok {
+			return nil, nil, fmt.Errorf("la respuesta correcta %s no está entre las opciones", value)
+		}
+		correctTexts = append(correctTexts, choiceText)
+	}
+
+	switch interaction.kind {
+	case "choiceInteraction":
+		if value, ok := qtiTrueFalse(interaction.choices, correct[0]); ok && len(correct) == 1 {
+			question.TypeQuestion = types.QuestionTypeTrueOrFalse
+			question.CorrectAnswer = &types.Answer{TrueOrFalse: value}
+			return question, warnings, nil
+		}
+		question.TypeQuestion = types.QuestionTypeMultiChoiceText
+		question.Options.SelectMode = "single"
+		if interaction.maxChoices != "1" || len(correctTexts) > 1 {
+			question.Options.SelectMode = "multiple"
+		}
+		question.Options.TextOptions = options
+		question.CorrectAnswer = &types.Answer{TextOptions: correctTexts}
+	case "orderInteraction":
+		question.TypeQuestion = types.QuestionTypeOrderWord
+		question.Options.TextOptions = options
+		question.CorrectAnswer = &types.Answer{TextOptions: correctTexts}
+	case "textEntryInteraction":
+		question.TypeQuestion = types.QuestionTypeCompleteWord
+		question.CorrectAnswer = &types.Answer{TextToComplete: accepted}
+	}
+	return question, warnings, nil
+}
+
+// qtiTrueFalse reconoce las preguntas de selección con las opciones verdadero y falso.
+func qtiTrueFalse(choices []qtiChoice, correct string) (bool, bool) {
+	if len(choices) != 2 {
+		return false, false
+	}
+	values := make(map[string]bool)
+	for _, choice := range choices {
+		label := strings.ToLower(strings.TrimSpace(choice.text.String()))
+		id := strings.ToLower(choice.id)
+		switch {
+		case ContainsString(qtiTrueTexts, label) || id == "true":
+			values[choice.id] = true
+		case ContainsString(qtiFalseTexts, label) || id == "false":
+			values[choice.id] = false
+		default:
+			return false, false
+		}
+	}
+	value, ok := values[strings.TrimSpace(correct)]
+	if !ok || values[choices[0].id] == values[choices[1].id] {
+		return false, false
+	}
+	return value, true
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"Proyectos-UTEQ/api-ortografia/pkg/types"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQTIRoundTrip(t *testing.T) {
+	for name, question := range roundTripQuestions() {
+		t.Run(name, func(t *testing.T) {
+			question.Options.Hind = "Revisa la regla."
+
+			content, issues, err := ExportQTI("Banco", []types.Question{question})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != 0 {
+				t.Fatalf("issues al exportar: %+v", issues)
+			}
+
+			questions, issues, err := ImportQTI("banco.zip", content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != 0 {
+				t.Fatalf("issues al importar: %+v", issues)
+			}
+			if len(questions) != 1 {
+				t.Fatalf("se esperaba una pregunta y se importaron %d", len(questions))
+			}
+
+			// el tipo de selección solo existe en las preguntas de selección.
+			if question.TypeQuestion != types.QuestionTypeMultiChoiceText {
+				question.Options.SelectMode = ""
+			}
+			if !reflect.DeepEqual(questions[0], question) {
+				t.Fatalf("la pregunta cambió\nesperada: %+v %+v\nobtenida: %+v %+v",
+					question, *question.CorrectAnswer, questions[0], *questions[0].CorrectAnswer)
+			}
+		})
+	}
+}
+
+func TestQTIImportUnsupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{
+			"emparejamiento",
+			`<responseDeclaration identifier="RESPONSE" cardinality="multiple" baseType="directedPair"><correctResponse><value>A B</value></correctResponse></responseDeclaration>
+			<itemBody><matchInteraction responseIdentifier="RESPONSE"/></itemBody>`,
+			"matchInteraction",
+		},
+		{
+			"varias interacciones",
+			`<responseDeclaration identifier="R1" cardinality="single" baseType="string"><correctResponse><value>a</value></correctResponse></responseDeclaration>
+			<itemBody><p>Uno <textEntryInteraction responseIdentifier="R1"/> dos <textEntryInteraction responseIdentifier="R2"/></p></itemBody>`,
+			"una interacción",
+		},
+		{
+			"sin respuesta correcta",
+			`<itemBody><choiceInteraction responseIdentifier="RESPONSE" maxChoices="1"><prompt>¿Cuál?</prompt>
+			<simpleChoice identifier="A">casa</simpleChoice><simpleChoice identifier="B">kasa</simpleChoice></choiceInteraction></itemBody>`,
+			"respuesta correcta",
+		},
+		{
+			"respuesta fuera de las opciones",
+			`<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier"><correctResponse><value>C</value></correctResponse></responseDeclaration>
+			<itemBody><choiceInteraction responseIdentifier="RESPONSE" maxChoices="1"><prompt>¿Cuál?</prompt>
+			<simpleChoice identifier="A">casa</simpleChoice><simpleChoice identifier="B">kasa</simpleChoice></choiceInteraction></itemBody>`,
+			"no está entre las opciones",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := fmt.Sprintf(`<assessmentItem xmlns="%s" identifier="P1" title="Pregunta">%s</assessmentItem>`, qtiNamespace, test.body)
+			questions, issues, err := ImportQTI("pregunta.xml", []byte(item))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(questions) != 0 {
+				t.Fatalf("no se esperaban preguntas, se importaron %+v", questions)
+			}
+			if len(issues) != 1 || issues[0].Severity != types.ConflictError {
+				t.Fatalf("se esperaba un error, issues: %+v", issues)
+			}
+			if !strings.Contains(issues[0].Message, test.message) {
+				t.Fatalf("el mensaje %q no menciona %q", issues[0].Message, test.message)
+			}
+		})
+	}
+
+	questions, issues, err := ImportQTI("prueba.xml", []byte(`<assessmentTest identifier="T1"/>`))
+	if err != nil || len(questions) != 0 || len(issues) != 1 || !strings.Contains(issues[0].Message, "assessmentTest") {
+		t.Fatalf("se esperaba un error por el assessmentTest, issues: %+v, err: %v", issues, err)
+	}
+}
+
+// qtiTestPackage arma un paquete con un recurso por cada href del manifest.
+func qtiTestPackage(t *testing.T, hrefs []string, files map[string]string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	var resources strings.Builder
+	for _, href := range hrefs {
+		fmt.Fprintf(&resources, `<resource type="%s" href="%s"/>`, qtiItemType, href)
+	}
+	files["imsmanifest.xml"] = "<manifest><resources>" + resources.String() + "</resources></manifest>"
+	for name, content := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestQTIPackageItems(t *testing.T) {
+	item, err := qtiItem("P1", roundTripQuestions()[types.QuestionTypeTrueOrFalse])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("href repetido", func(t *testing.T) {
+		content := qtiTestPackage(t, []string{"items/P1.xml", "./items/P1.xml", "items/P1.xml"}, map[string]string{"items/P1.xml": item})
+		items, err := qtiPackageItems(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("el archivo se leyó %d veces", len(items))
+		}
+	})
+
+	t.Run("demasiadas preguntas", func(t *testing.T) {
+		hrefs := make([]string, 0, qtiMaxItems+1)
+		files := make(map[string]string)
+		for i := 0; i <= qtiMaxItems; i++ {
+			href := fmt.Sprintf("items/P%d.xml", i)
+			hrefs = append(hrefs, href)
+			files[href] = item
+		}
+		if _, err := qtiPackageItems(qtiTestPackage(t, hrefs, files)); err == nil {
+			t.Fatal("se esperaba un error por la cantidad de preguntas")
+		}
+	})
+
+	t.Run("archivo demasiado grande", func(t *testing.T) {
+		content := qtiTestPackage(t, []string{"items/P1.xml"}, map[string]string{"items/P1.xml": item + strings.Repeat(" ", qtiMaxFileSize)})
+		if _, err := qtiPackageItems(content); err == nil {
+			t.Fatal("se esperaba un error por el tamaño del archivo")
+		}
+	})
+
+	t.Run("paquete demasiado grande", func(t *testing.T) {
+		// cada archivo cabe en el límite por archivo, pero juntos superan el del paquete.
+		hrefs := make([]string, 0)
+		files := make(map[string]string)
+		for i := 0; i*(qtiMaxFileSize-1) <= qtiMaxPackageSize; i++ {
+			href := fmt.Sprintf("items/P%d.xml", i)
+			hrefs = append(hrefs, href)
+			files[href] = item + strings.Repeat(" ", qtiMaxFileSize-1-len(item))
+		}
+		if _, err := qtiPackageItems(qtiTestPackage(t, hrefs, files)); err == nil {
+			t.Fatal("se esperaba un error por el tamaño del paquete")
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxMaxColumns cantidad de columnas de una hoja de Excel, la última es XFD.
+const xlsxMaxColumns = 16384
+
+// ReadRoster lee las filas de un archivo CSV o XLSX según su extensión. En los XLSX solo se lee la primera hoja,
+// las filas que no se pueden leer se devuelven vacías con su error indexado por la posición de la fila.
+func ReadRoster(filename string, content []byte) ([][]string, map[int]string, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		rows, err := readCSV(content)
+		return rows, nil, err
+	case ".xlsx":
+		return readXLSX(content)
+	default:
+		return nil, nil, errors.New("el archivo debe ser CSV o XLSX")
+	}
+}
+
+// ExcelSerialToDate convierte el número de serie de una fecha de Excel al formato 2006-01-02.
+func ExcelSerialToDate(value string) (string, bool) {
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", false
+	}
+	date := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(serial))
+	return date.Format("2006-01-02"), true
+}
+
+func readCSV(content []byte) ([][]string, error) {
+	// quitamos el BOM que agrega Excel al exportar en UTF-8.
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	// Excel en español separa con punto y coma.
+	if firstLine, _, _ := bytes.Cut(content, []byte("\n")); bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	return reader.ReadAll()
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var sb strings.Builder
+	for _, run := range t.Runs {
+		sb.WriteString(run.Text)
+	}
+	return sb.String()
+}
+
+type xlsxSheet struct {
+	Rows []xlsxRow `xml:"sheetData>row"`
+}
+
+type xlsxRow struct {
+	Cells []struct {
+		Ref    string       `xml:"r,attr"`
+		Type   string       `xml:"t,attr"`
+		Value  string       `xml:"v"`
+		Inline xlsxRichText `xml:"is"`
+	} `xml:"c"`
+}
+
+func readXLSX(content []byte) ([][]string, map[int]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, nil, errors.New("el archivo XLSX no es valido")
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeZipXML(file, &sharedStrings); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sheetFile, ok := files[firstSheetPath(files)]
+	if !ok {
+		return nil, nil, errors.New("el archivo XLSX no tiene hojas")
+	}
+
+	var sheet xlsxSheet
+	if err := decodeZipXML(sheetFile, &sheet); err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	rowErrors := make(map[int]string)
+	// la cabecera define las columnas, en las demás filas se ignoran las celdas que están después.
+	width := xlsxMaxColumns
+	for rowIndex, row := range sheet.Rows {
+		values, err := xlsxRowValues(row, sharedStrings, width)
+		if err != nil {
+			if rowIndex == 0 {
+				return nil, nil, fmt.Errorf("la cabecera del archivo XLSX no es valida: %w", err)
+			}
+			rowErrors[rowIndex] = err.Error()
+			values = []string{}
+		}
+		if rowIndex == 0 {
+			width = len(values)
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, rowErrors, nil
+}
+
+// xlsxRowValues valores de la fila hasta la columna width, las celdas vacías no se guardan en el archivo y se
+// rellenan según la referencia de la columna.
+func xlsxRowValues(row xlsxRow, sharedStrings xlsxSharedStrings, width int) ([]string, error) {
+	values := make([]string, 0, len(row.Cells))
+	for _, cell := range row.Cells {
+		column, err := columnIndex(cell.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if column < 0 {
+			column = len(values)
+		}
+		if column >= width {
+			continue
+		}
+		for len(values) < column {
+			values = append(values, "")
+		}
+
+		value := cell.Value
+		switch cell.Type {
+		case "s":
+			index, err := strconv.Atoi(cell.Value)
+			if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+				return nil, fmt.Errorf("la celda %s no es valida", cell.Ref)
+			}
+			value = sharedStrings.Items[index].String()
+		case "inlineStr":
+			value = cell.Inline.String()
+		}
+		values = append(values, strings.TrimSpace(value))
+	}
+	return values, nil
+}
+
+// firstSheetPath resuelve la ruta de la primera hoja del libro.
+func firstSheetPath(files map[string]*zip.File) string {
+	const defaultSheet = "xl/worksheets/sheet1.xml"
+
+	var workbook xlsxWorkbook
+	var relationships xlsxRelationships
+	workbookFile, ok := files["xl/workbook.xml"]
+	relsFile, okRels := files["xl/_rels/workbook.xml.rels"]
+	if !ok || !okRels || decodeZipXML(workbookFile, &workbook) != nil || decodeZipXML(relsFile, &relationships) != nil || len(workbook.Sheets) == 0 {
+		return defaultSheet
+	}
+
+	for _, rel := range relationships.Relationships {
+		if rel.ID == workbook.Sheets[0].RelID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/")
+			}
+			return path.Join("xl", rel.Target)
+		}
+	}
+	return defaultSheet
+}
+
+// columnIndex convierte la referencia de la celda (por ejemplo C4) al índice de la columna, -1 si la celda no
+// indica la columna. Las columnas después de XFD no existen en Excel.
+func columnIndex(ref string) (int, error) {
+	index := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+		letters++
+		if index > xlsxMaxColumns {
+			return 0, fmt.Errorf("la celda %s está fuera de la hoja", ref)
+		}
+	}
+	if letters == 0 {
+		return -1, nil
+	}
+	return index - 1, nil
+}
+
+func decodeZipXML(file *zip.File, v interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(reader, 50<<20)).Decode(v); err != nil {
+		return errors.New("el archivo XLSX no es valido")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRosterCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		rows    [][]string
+	}{
+		{
+			"coma",
+			"nombre,email\nAna,ana@uteq.edu.ec\n",
+			[][]string{{"nombre", "email"}, {"Ana", "ana@uteq.edu.ec"}},
+		},
+		{
+			"punto y coma de Excel en español",
+			"nombre;email;fecha_nacimiento\nAna; ana@uteq.edu.ec;2010-01-02\n",
+			[][]string{{"nombre", "email", "fecha_nacimiento"}, {"Ana", "ana@uteq.edu.ec", "2010-01-02"}},
+		},
+		{
+			"BOM de UTF-8",
+			"\xef\xbb\xbfnombre,email\r\nAna,ana@uteq.edu.ec\r\n",
+			[][]string{{"nombre", "email"}, {"Ana", "ana@uteq.edu.ec"}},
+		},
+		{
+			"filas con distinta cantidad de columnas",
+			"nombre,email,telegram\nAna,ana@uteq.edu.ec\n",
+			[][]string{{"nombre", "email", "telegram"}, {"Ana", "ana@uteq.edu.ec"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, rowErrors, err := ReadRoster("estudiantes.CSV", []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rowErrors) != 0 {
+				t.Fatalf("no se esperaban errores de filas: %v", rowErrors)
+			}
+			if !reflect.DeepEqual(rows, test.rows) {
+				t.Fatalf("se esperaba %q y se obtuvo %q", test.rows, rows)
+			}
+		})
+	}
+}
+
+// xlsxTestFile arma un XLSX mínimo con la hoja y las cadenas compartidas indicadas.
+func xlsxTestFile(t *testing.T, sheetData, sharedStrings string) []byte {
+	t.Helper()
+
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Estudiantes" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Target="worksheets/estudiantes.xml"/></Relationships>`,
+		"xl/worksheets/estudiantes.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	if sharedStrings != "" {
+		files["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sharedStrings + `</sst>`
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestReadRosterXLSX(t *testing.T) {
+	sharedStrings := `<si><t>nombre</t></si><si><t>email</t></si><si><t>telegram</t></si><si><r><t>An</t></r><r><t>a</t></r></si>`
+	header := `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c></row>`
+
+	tests := []struct {
+		name      string
+		sheetData string
+		rows      [][]string
+		rowErrors map[int]string
+	}{
+		{
+			"cadenas compartidas y en línea",
+			header + `<row r="2"><c r="A2" t="s"><v>3</v></c><c r="B2" t="inlineStr"><is><t> ana@uteq.edu.ec </t></is></c><c r="C2"><v>12345</v></c></row>`,
+			[][]string{{"nombre", "email", "telegram"}, {"Ana", "ana@uteq.edu.ec", "12345"}},
+			map[int]string{},
+		},
+		{
+			"celdas vacías sin guardar",
+			header + `<row r="2"><c r="C2" t="inlineStr"><is><t>@ana</t></is></c></row><row r="3"><c r="B3" t="inlineStr"><is><t>luis@uteq.edu.ec</t></is></c></row>`,
+			[][]string{{"nombre", "email", "telegram"}, {"", "", "@ana"}, {"", "luis@uteq.edu.ec"}},
+			map[int]string{},
+		},
+		{
+			"celdas después de la cabecera",
+			header + `<row r="2"><c r="A2" t="s"><v>3</v></c><c r="XFD2" t="inlineStr"><is><t>ignorada</t></is></c></row>`,
+			[][]string{{"nombre", "email", "telegram"}, {"Ana"}},
+			map[int]string{},
+		},
+		{
+			"columna fuera de la hoja",
+			header + `<row r="2"><c r="ZZZZZZ2" t="inlineStr"><is><t>x</t></is></c></row><row r="3"><c r="A3" t="s"><v>3</v></c></row>`,
+			[][]string{{"nombre", "email", "telegram"}, {}, {"Ana"}},
+			map[int]string{1: "la celda ZZZZZZ2 está fuera de la hoja"},
+		},
+		{
+			"cadena compartida que no existe",
+			header + `<row r="2"><c r="A2" t="s"><v>-1</v></c></row><row r="3"><c r="A3" t="s"><v>99</v></c></row>`,
+			[][]string{{"nombre", "email", "telegram"}, {}, {}},
+			map[int]string{1: "la celda A2 no es valida", 2: "la celda A3 no es valida"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, rowErrors, err := ReadRoster("estudiantes.xlsx", xlsxTestFile(t, test.sheetData, sharedStrings))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, test.rows) {
+				t.Fatalf("se esperaba %q y se obtuvo %q", test.rows, rows)
+			}
+			if !reflect.DeepEqual(rowErrors, test.rowErrors) {
+				t.Fatalf("se esperaban los errores %v y se obtuvo %v", test.rowErrors, rowErrors)
+			}
+		})
+	}
+}
+
+func TestReadRosterXLSXInvalidHeader(t *testing.T) {
+	content := xlsxTestFile(t, `<row r="1"><c r="AAAAA1" t="inlineStr"><is><t>nombre</t></is></c></row>`, "")
+	if _, _, err := ReadRoster("estudiantes.xlsx", content); err == nil || !strings.Contains(err.Error(), "cabecera") {
+		t.Fatalf("se esperaba un error en la cabecera y se obtuvo %v", err)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref   string
+		index int
+		err   bool
+	}{
+		{"A1", 0, false},
+		{"Z9", 25, false},
+		{"AA10", 26, false},
+		{"XFD1", xlsxMaxColumns - 1, false},
+		{"XFE1", 0, true},
+		{"ZZZZZZ1", 0, true},
+		{"", -1, false},
+		{"12", -1, false},
+	}
+
+	for _, test := range tests {
+		index, err := columnIndex(test.ref)
+		if (err != nil) != test.err || (!test.err && index != test.index) {
+			t.Fatalf("%s: se esperaba %d (error %t) y se obtuvo %d (%v)", test.ref, test.index, test.err, index, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "strings"
+
+// DescribeUserAgent resume el user agent en el navegador y el sistema operativo, por ejemplo "Chrome en Windows".
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Dispositivo desconocido"
+	}
+
+	browser := firstMatch(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"PostmanRuntime", "Postman"},
+		{"okhttp", "Android"},
+		{"Dart/", "Aplicación móvil"},
+	}, "Navegador")
+
+	system := firstMatch(userAgent, [][2]string{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"CrOS", "ChromeOS"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}, "")
+
+	if system == "" || system == browser {
+		return browser
+	}
+	return browser + " en " + system
+}
+
+func firstMatch(value string, patterns [][2]string, fallback string) string {
+	for _, pattern := range patterns {
+		if strings.Contains(value, pattern[0]) {
+			return pattern[1]
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKey credencial de una integración, la key completa solo se entrega al crearla.
+type APIKey struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	KeyPrefix  string   `json:"key_prefix"`
+	Key        string   `json:"key,omitempty"`
+	Scopes     []string `json:"scopes"`
+	CreatedBy  *UserAPI `json:"created_by,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  *string  `json:"expires_at"`
+	RevokedAt  *string  `json:"revoked_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	LastUsedIP string   `json:"last_used_ip"`
+	UsageCount int64    `json:"usage_count"`
+}
+
+// ReqAPIKey datos para crear una API key, expires_at tiene el formato 2006-01-02.
+type ReqAPIKey struct {
+	Name      string   `json:"name" validate:"required,min=3,max=100"`
+	Scopes    []string `json:"scopes" validate:"required,min=1"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// Expiration fecha de expiración de la key, nil si no expira.
+func (r *ReqAPIKey) Expiration() (*time.Time, error) {
+	if r.ExpiresAt == "" {
+		return nil, nil
+	}
+	expiresAt, err := time.Parse("2006-01-02", r.ExpiresAt)
+	if err != nil {
+		return nil, errors.New("expires_at debe tener el formato 2006-01-02")
+	}
+	if expiresAt.Before(time.Now()) {
+		return nil, errors.New("expires_at debe ser una fecha futura")
+	}
+	return &expiresAt, nil
+}
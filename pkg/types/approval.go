@@ -0,0 +1,50 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ApprovalDecision decisión de un administrador sobre la solicitud de un profesor o administrador.
+type ApprovalDecision struct {
+	ID        uint     `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	UserID    uint     `json:"user_id"`
+	DecidedBy *UserAPI `json:"decided_by"`
+	Decision  string   `json:"decision"`
+	Reason    string   `json:"reason"`
+}
+
+// ReqApprovalDecision motivo de la aprobación o rechazo.
+type ReqApprovalDecision struct {
+	Reason string `json:"reason"`
+}
+
+// ApprovalFilter filtros para la cola de aprobaciones.
+type ApprovalFilter struct {
+	TypeUser string `query:"type_user"`
+	Status   string `query:"status"`
+	From     string `query:"from"` // 2006-01-02
+	To       string `query:"to"`   // 2006-01-02
+}
+
+func (f *ApprovalFilter) Validate() error {
+	if f.TypeUser != "" && f.TypeUser != "teacher" && f.TypeUser != "admin" {
+		return errors.New("type_user must be one of: teacher, admin")
+	}
+
+	if f.Status != "" && f.Status != "pending_approval" && f.Status != "rejected" {
+		return errors.New("status must be one of: pending_approval, rejected")
+	}
+
+	for _, date := range []string{f.From, f.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return errors.New("the dates must have the format 2006-01-02")
+		}
+	}
+
+	return nil
+}
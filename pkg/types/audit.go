@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditLog registro de una acción administrativa o de calificación.
+type AuditLog struct {
+	ID           uint            `json:"id"`
+	CreatedAt    string          `json:"created_at"`
+	ActorID      *uint           `json:"actor_id"`
+	Actor        *UserAPI        `json:"actor,omitempty"`
+	APIKeyID     *uint           `json:"api_key_id"`
+	OnBehalfOfID *uint           `json:"on_behalf_of_id"`
+	Action       string          `json:"action"`
+	EntityType   string          `json:"entity_type"`
+	EntityID     uint            `json:"entity_id"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	IP           string          `json:"ip"`
+}
+
+// AuditFilter filtros de la consulta de auditoría.
+type AuditFilter struct {
+	ActorID      uint   `query:"actor_id"`
+	APIKeyID     uint   `query:"api_key_id"`
+	OnBehalfOfID uint   `query:"on_behalf_of_id"`
+	Action       string `query:"action"`
+	EntityType   string `query:"entity_type"`
+	EntityID     uint   `query:"entity_id"`
+	From         string `query:"from"` // 2006-01-02
+	To           string `query:"to"`   // 2006-01-02
+}
+
+func (f *AuditFilter) Validate() error {
+	for _, date := range []string{f.From, f.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return errors.New("the dates must have the format 2006-01-02")
+		}
+	}
+
+	return nil
+}
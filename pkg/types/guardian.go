@@ -0,0 +1,23 @@
+package types
+
+// GuardianLink vínculo entre un representante y un estudiante.
+type GuardianLink struct {
+	ID        uint     `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	Guardian  *UserAPI `json:"guardian,omitempty"`
+	Student   *UserAPI `json:"student,omitempty"`
+	Status    string   `json:"status"`
+}
+
+// ReqGuardianLink correo del estudiante con el que se quiere vincular el representante.
+type ReqGuardianLink struct {
+	StudentEmail string `json:"student_email" validate:"required,email"`
+}
+
+// StudentQualification resumen de las calificaciones del estudiante en un módulo.
+type StudentQualification struct {
+	Module               Module  `json:"module"`
+	TestsFinished        int     `json:"tests_finished"`
+	BestQualification    float32 `json:"best_qualification"`
+	AverageQualification float32 `json:"average_qualification"`
+}
@@ -5,5 +5,32 @@ import "github.com/golang-jwt/jwt/v5"
 // UserAPI representa un usuario en el JWT.
 type UserClaims struct {
 	UserAPI
+	// SessionID sesión del dispositivo, el token deja de ser valido al cerrarla.
+	SessionID uint `json:"sid"`
+	// APIKeyID API key con la que se autenticó la integración, no forma parte del JWT.
+	APIKeyID uint `json:"-"`
+	// Impersonator administrador que está viendo la aplicación como el usuario, el token es de corta duración.
+	Impersonator *UserAPI `json:"impersonator,omitempty"`
+	// Permissions permisos del rol del usuario al momento de emitir el token.
+	Permissions []string `json:"permissions"`
+	// TwoFactorPending el rol exige la verificación en dos pasos y el usuario aún no la activa.
+	TwoFactorPending bool `json:"two_factor_pending,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// HasPermission indica si el token tiene alguno de los permisos.
+func (c *UserClaims) HasPermission(permissions ...string) bool {
+	for _, permission := range permissions {
+		for _, granted := range c.Permissions {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsImpersonated indica si el token fue emitido para que un administrador vea la aplicación como el usuario.
+func (c *UserClaims) IsImpersonated() bool {
+	return c.Impersonator != nil
+}
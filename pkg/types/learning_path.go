@@ -0,0 +1,59 @@
+package types
+
+// LearningPath ruta de aprendizaje, cada módulo se desbloquea al aprobar el módulo anterior de la ruta.
+type LearningPath struct {
+	ID          uint    `json:"id"`
+	CreatedAt   string  `json:"created_at"`
+	CreatedBy   UserAPI `json:"created_by"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	// MinQualification porcentaje (0 a 100) que se debe obtener en un módulo para desbloquear el siguiente,
+	// con 0 basta con terminar un test.
+	MinQualification float32              `json:"min_qualification"`
+	Modules          []LearningPathModule `json:"modules"`
+}
+
+// LearningPathModule módulo de la ruta en su posición, con el estado de bloqueo del usuario que consulta.
+type LearningPathModule struct {
+	Position        int                     `json:"position"`
+	Module          Module                  `json:"module"`
+	Locked          bool                    `json:"locked"`
+	UnmetConditions []ModuleUnlockCondition `json:"unmet_conditions"`
+}
+
+// ReqLearningPath datos para crear o actualizar una ruta, ModuleIDs es el orden de los módulos.
+type ReqLearningPath struct {
+	Title            string  `json:"title" validate:"required,min=3,max=100"`
+	Description      string  `json:"description" validate:"max=500"`
+	MinQualification float32 `json:"min_qualification" validate:"gte=0,lte=100"`
+	ModuleIDs        []uint  `json:"module_ids" validate:"required,min=1,max=50,dive,gt=0"`
+}
+
+// ModulePrerequisite módulo que se debe aprobar antes de realizar los test del módulo.
+type ModulePrerequisite struct {
+	RequiredModuleID    uint    `json:"required_module_id"`
+	RequiredModuleTitle string  `json:"required_module_title"`
+	MinQualification    float32 `json:"min_qualification"`
+}
+
+// ReqModulePrerequisites reemplaza los prerrequisitos del módulo, una lista vacía los elimina.
+type ReqModulePrerequisites struct {
+	Prerequisites []ReqModulePrerequisite `json:"prerequisites" validate:"max=20,dive"`
+}
+
+type ReqModulePrerequisite struct {
+	RequiredModuleID uint    `json:"required_module_id" validate:"required,gt=0"`
+	MinQualification float32 `json:"min_qualification" validate:"gte=0,lte=100"`
+}
+
+// ModuleUnlockCondition condición que el estudiante aún no cumple para desbloquear el módulo.
+type ModuleUnlockCondition struct {
+	RequiredModuleID    uint    `json:"required_module_id"`
+	RequiredModuleTitle string  `json:"required_module_title"`
+	MinQualification    float32 `json:"min_qualification"`
+	// BestQualification mejor porcentaje del estudiante en el módulo requerido, nil si no ha terminado ningún test.
+	BestQualification *float32 `json:"best_qualification"`
+	// LearningPathID ruta que impone la condición, nil si es un prerrequisito del módulo.
+	LearningPathID *uint  `json:"learning_path_id,omitempty"`
+	Message        string `json:"message"`
+}
@@ -15,12 +15,65 @@ type Module struct {
 	PointsToEarn     int     `json:"points_to_earn" validate:"required"`
 	Index            int     `json:"index"`
 	IsPublic         bool    `json:"is_public"`
+	// Tags etiquetas o categorías del módulo, por ejemplo "tildes" o "b/v".
+	Tags []string `json:"tags" validate:"max=10,dive,max=40"`
+	// PublishedVersion número de la versión publicada, 0 si el módulo solo tiene borrador.
+	PublishedVersion int `json:"published_version"`
+	// SourceModuleID módulo del que se clonó.
+	SourceModuleID *uint `json:"source_module_id"`
+	// Role rol del profesor que consulta en el módulo (owner, editor o viewer), solo en el listado del profesor.
+	Role string `json:"role,omitempty"`
+}
+
+// ReqCloneModule opciones para clonar un módulo.
+type ReqCloneModule struct {
+	// Title título de la copia, por defecto es el título original con "(copia)".
+	Title string `json:"title" validate:"omitempty,min=3,max=100"`
+	// Draft la copia queda como borrador, sin versión publicada.
+	Draft bool `json:"draft"`
+	// Private la copia no se muestra en el listado público de módulos.
+	Private bool `json:"private"`
+	// RecordSource guarda el módulo original para dar crédito a su autor.
+	RecordSource bool `json:"record_source"`
+}
+
+// ModuleVersion versión publicada e inmutable de un módulo.
+type ModuleVersion struct {
+	ID               uint       `json:"id"`
+	ModuleID         uint       `json:"module_id"`
+	Version          int        `json:"version"`
+	Title            string     `json:"title"`
+	ShortDescription string     `json:"short_description"`
+	TextRoot         string     `json:"text_root"`
+	ImgBackURL       string     `json:"img_back_url"`
+	Difficulty       string     `json:"difficulty"`
+	PointsToEarn     int        `json:"points_to_earn"`
+	Notes            string     `json:"notes"`
+	QuestionsCount   int        `json:"questions_count"`
+	PublishedBy      *UserAPI   `json:"published_by"`
+	PublishedAt      string     `json:"published_at"`
+	Questions        []Question `json:"questions,omitempty"`
+}
+
+// ReqPublishModule datos para publicar el borrador del módulo.
+type ReqPublishModule struct {
+	Notes string `json:"notes" validate:"max=500"`
+}
+
+// ModulePreview borrador del módulo con sus preguntas, tal como quedará al publicarlo.
+type ModulePreview struct {
+	Module                Module     `json:"module"`
+	Questions             []Question `json:"questions"`
+	HasUnpublishedChanges bool       `json:"has_unpublished_changes"`
 }
 
 // Representacion de un modulo para el frontend para saber si el usuario esta subscrito.
 type ModuleUser struct {
 	Module
 	IsSubscribed bool `json:"is_subscribed"`
+	// Locked el estudiante no puede realizar los test hasta cumplir las condiciones de UnmetConditions.
+	Locked          bool                    `json:"locked"`
+	UnmetConditions []ModuleUnlockCondition `json:"unmet_conditions"`
 }
 
 // ModuleTestUser representa un modulo con sus preguntas para que el frontend pueda ralizar
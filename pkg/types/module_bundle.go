@@ -0,0 +1,114 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ModuleBundleFormat identifica los paquetes de módulos exportados por Poliword.
+	ModuleBundleFormat = "poliword.module"
+	// ModuleBundleVersion versión del formato del paquete, se incrementa cuando cambia su estructura.
+	ModuleBundleVersion = 1
+)
+
+// ModuleBundle paquete autocontenido de un módulo para moverlo entre instalaciones.
+type ModuleBundle struct {
+	Format     string              `json:"format"`
+	Version    int                 `json:"version"`
+	ExportedAt string              `json:"exported_at"`
+	Source     ModuleBundleSource  `json:"source"`
+	Module     ModuleBundleModule  `json:"module"`
+	Questions  []Question          `json:"questions"`
+	Images     []ModuleBundleImage `json:"images"`
+}
+
+// ModuleBundleSource instalación y módulo de origen, solo es informativo.
+type ModuleBundleSource struct {
+	Host     string `json:"host"`
+	ModuleID uint   `json:"module_id"`
+	Code     string `json:"code"`
+	Author   string `json:"author"`
+}
+
+// ModuleBundleModule datos del módulo dentro del paquete.
+type ModuleBundleModule struct {
+	Title            string `json:"title"`
+	ShortDescription string `json:"short_description"`
+	TextRoot         string `json:"text_root"`
+	ImgBackURL       string `json:"img_back_url"`
+	Difficulty       string `json:"difficulty"`
+	PointsToEarn     int    `json:"points_to_earn"`
+	Index            int    `json:"index"`
+	// Tags se agregó después de la versión 1, los paquetes anteriores no la tienen.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// ModuleBundleImage imagen subida a la instalación de origen, Ref es la url con la que se referencia en el módulo.
+type ModuleBundleImage struct {
+	Ref         string `json:"ref"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// ValidateFormat revisa que el paquete sea de un formato que se pueda importar.
+func (b *ModuleBundle) ValidateFormat() error {
+	if b.Format != ModuleBundleFormat {
+		return fmt.Errorf("el archivo no es un módulo exportado de Poliword")
+	}
+	if b.Version < 1 || b.Version > ModuleBundleVersion {
+		return fmt.Errorf("la versión %d del paquete no es compatible, se admite hasta la versión %d", b.Version, ModuleBundleVersion)
+	}
+	return nil
+}
+
+// ValidateQuestion valida la pregunta del paquete, revisa antes los campos que Question.Validate asume presentes.
+func ValidateQuestion(question *Question) error {
+	if question.CorrectAnswer == nil {
+		return fmt.Errorf("the correct answer cannot be empty")
+	}
+	if (question.TypeQuestion == QuestionTypeMultiChoiceText || question.TypeQuestion == "multi_choice_abc") && len(question.CorrectAnswer.TextOptions) == 0 {
+		return fmt.Errorf("the correct answer cannot be empty")
+	}
+	return question.Validate()
+}
+
+// QuestionKey clave para detectar preguntas repetidas dentro del paquete.
+func QuestionKey(question Question) string {
+	return question.TypeQuestion + "|" + strings.ToLower(strings.TrimSpace(question.TextRoot)) + "|" + strings.Join(question.Options.TextOptions, "|")
+}
+
+const (
+	ConflictError   = "error"
+	ConflictWarning = "warning"
+)
+
+// ModuleImportConflict problema encontrado al importar el paquete, los errores impiden la importación.
+type ModuleImportConflict struct {
+	Severity string `json:"severity"`
+	// Question posición de la pregunta en el paquete, nil si el conflicto es del módulo.
+	Question *int   `json:"question,omitempty"`
+	SourceID uint   `json:"source_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ModuleImportResult resultado de la importación, QuestionIDs relaciona los ids del paquete con los nuevos.
+type ModuleImportResult struct {
+	DryRun            bool                   `json:"dry_run"`
+	Imported          bool                   `json:"imported"`
+	Module            *Module                `json:"module,omitempty"`
+	QuestionsImported int                    `json:"questions_imported"`
+	QuestionIDs       map[uint]uint          `json:"question_ids"`
+	Conflicts         []ModuleImportConflict `json:"conflicts"`
+}
+
+// HasErrors indica si algún conflicto impide la importación.
+func (r *ModuleImportResult) HasErrors() bool {
+	for _, conflict := range r.Conflicts {
+		if conflict.Severity == ConflictError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package types
+
+// Roles de los profesores en un módulo, el dueño es quien lo creó o quien lo recibió en una transferencia.
+const (
+	ModuleRoleOwner  = "owner"
+	ModuleRoleEditor = "editor"
+	ModuleRoleViewer = "viewer"
+)
+
+// ModuleCollaborator profesor invitado al módulo, Accepted es falso mientras la invitación está pendiente.
+type ModuleCollaborator struct {
+	ID         uint    `json:"id"`
+	ModuleID   uint    `json:"module_id"`
+	User       UserAPI `json:"user"`
+	Role       string  `json:"role"`
+	InvitedBy  UserAPI `json:"invited_by"`
+	Accepted   bool    `json:"accepted"`
+	InvitedAt  string  `json:"invited_at"`
+	AcceptedAt *string `json:"accepted_at"`
+}
+
+// ModuleInvitation invitación pendiente del profesor para colaborar en un módulo.
+type ModuleInvitation struct {
+	ID        uint    `json:"id"`
+	Module    Module  `json:"module"`
+	Role      string  `json:"role"`
+	InvitedBy UserAPI `json:"invited_by"`
+	InvitedAt string  `json:"invited_at"`
+}
+
+// ReqInviteCollaborator invita a un profesor por su correo.
+type ReqInviteCollaborator struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=editor viewer"`
+}
+
+// ReqCollaboratorRole cambia el rol de un colaborador.
+type ReqCollaboratorRole struct {
+	Role string `json:"role" validate:"required,oneof=editor viewer"`
+}
+
+// ReqTransferModule transfiere el módulo a otro profesor, con KeepAsEditor el dueño anterior queda como editor.
+type ReqTransferModule struct {
+	UserID       uint `json:"user_id" validate:"required,gt=0"`
+	KeepAsEditor bool `json:"keep_as_editor"`
+}
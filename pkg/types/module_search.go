@@ -0,0 +1,40 @@
+package types
+
+import (
+	"strings"
+)
+
+// ModuleFilter filtros del listado de módulos, las etiquetas se separan por comas: ?tags=tildes,b/v
+type ModuleFilter struct {
+	Tags string `query:"tags"`
+}
+
+// TagList etiquetas del filtro normalizadas, los módulos deben tener todas las etiquetas.
+func (f *ModuleFilter) TagList() []string {
+	if f == nil || f.Tags == "" {
+		return nil
+	}
+	return NormalizeTags(strings.Split(f.Tags, ","))
+}
+
+// NormalizeTags deja las etiquetas en minúsculas, sin espacios repetidos y sin duplicados.
+// Se conservan las tildes porque son parte del nombre, la búsqueda las ignora.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// TagFacet cantidad de módulos de la búsqueda que tienen la etiqueta.
+type TagFacet struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
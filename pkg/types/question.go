@@ -104,3 +104,21 @@ type Answer struct {
 	TextOptions    []string `json:"text_options"`
 	TextToComplete []string `json:"text_to_complete"`
 }
+
+// QuestionConversionIssue pregunta que no se puede representar en el otro formato o que pierde datos al convertirla.
+// Con severidad error la pregunta se omite, con warning se convierte sin los datos indicados.
+type QuestionConversionIssue struct {
+	Item     int    `json:"item"`
+	Title    string `json:"title"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// QuestionImportResult resultado de importar un banco de preguntas en el módulo.
+type QuestionImportResult struct {
+	DryRun    bool                      `json:"dry_run"`
+	Format    string                    `json:"format"`
+	Imported  int                       `json:"imported"`
+	Questions []Question                `json:"questions"`
+	Issues    []QuestionConversionIssue `json:"issues"`
+}
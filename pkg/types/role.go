@@ -0,0 +1,20 @@
+package types
+
+// Role rol de usuario con los permisos que tiene asignados.
+type Role struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name" validate:"required,min=3,max=50"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Permission permiso disponible para asignar a los roles.
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ReqUserRole rol que se asigna a un usuario.
+type ReqUserRole struct {
+	TypeUser string `json:"type_user" validate:"required"`
+}
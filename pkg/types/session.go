@@ -0,0 +1,12 @@
+package types
+
+// Session sesión activa del usuario en un dispositivo.
+type Session struct {
+	ID         uint   `json:"id"`
+	Device     string `json:"device"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
@@ -38,3 +38,12 @@ type FinishTest struct {
 	Qualification float32 `json:"qualification"`
 	TestID        uint    `json:"test_id"`
 }
+
+// ModuleGrade resumen de las calificaciones de un estudiante en el módulo.
+type ModuleGrade struct {
+	Student              *UserAPI `json:"student"`
+	TestsFinished        int      `json:"tests_finished"`
+	BestQualification    float32  `json:"best_qualification"`
+	AverageQualification float32  `json:"average_qualification"`
+	LastFinished         *string  `json:"last_finished"`
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TwoFactorSetup datos para registrar la cuenta en la app de autenticación.
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// ReqTwoFactorCode código TOTP o código de recuperación.
+type ReqTwoFactorCode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// ReqTwoFactorSignin segundo paso del inicio de sesión.
+type ReqTwoFactorSignin struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// TwoFactorChallengeClaims token temporal que se entrega cuando el usuario tiene la verificación en dos pasos.
+type TwoFactorChallengeClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// TwoFactorPolicy indica si la verificación en dos pasos es obligatoria para un rol.
+type TwoFactorPolicy struct {
+	TypeUser string `json:"type_user"`
+	Required bool   `json:"required"`
+}
+
+func (p *TwoFactorPolicy) Validate() error {
+	if p.TypeUser == "" {
+		return errors.New("type_user is required")
+	}
+	return nil
+}
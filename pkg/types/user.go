@@ -15,8 +15,11 @@ type UserAPI struct {
 	TelegramID           int64  `json:"telegram_id"`
 	URLAvatar            string `json:"url_avatar"`
 	Status               string `json:"status"`
-	TypeUser             string `json:"type_user" validate:"required,oneof=student teacher admin"`
+	TypeUser             string `json:"type_user" validate:"required,role"`
 	PerfilUpdateRequired bool   `json:"perfil_update_required"`
+	TwoFactorEnabled     bool   `json:"two_factor_enabled"`
+	// DeletionScheduledAt fecha en la que se anonimizará la cuenta, el usuario puede cancelarlo antes.
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
 }
 
 func (user *UserAPI) ValidateUpdateUser() error {
@@ -33,10 +36,6 @@ func (user *UserAPI) ValidateUpdateUser() error {
 		return fmt.Errorf("el whatsapp es requerido")
 	}
 
-	if user.URLAvatar == "" {
-		return fmt.Errorf("la url del avatar es requerida")
-	}
-
 	return nil
 }
 
@@ -54,3 +53,25 @@ type ChangePassword struct {
 	Token    string `json:"token" validate:"required"`
 	Password string `json:"password" validate:"required,min=6,max=100"`
 }
+
+// RefreshToken sirve para renovar el JWT o cerrar la sesión.
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// GoogleUser datos del usuario que se recuperan al iniciar sesión con Google.
+type GoogleUser struct {
+	GoogleID  string
+	Email     string
+	FirstName string
+	LastName  string
+	URLAvatar string
+	TypeUser  string
+	// EmailVerified Google verificó que el correo pertenece a la cuenta.
+	EmailVerified bool
+}
+
+// VerifyEmail token para verificar el correo del usuario.
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
+}
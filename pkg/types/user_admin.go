@@ -0,0 +1,39 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// UserFilter filtros de la administración de usuarios.
+type UserFilter struct {
+	TypeUser string `query:"type_user"`
+	Status   string `query:"status"`
+	From     string `query:"from"` // 2006-01-02
+	To       string `query:"to"`   // 2006-01-02
+}
+
+func (f *UserFilter) Validate() error {
+	switch f.Status {
+	case "", "actived", "blocked", "pending_approval", "pending_verification", "rejected":
+	default:
+		return errors.New("el estado debe ser actived, blocked, pending_approval, pending_verification o rejected")
+	}
+
+	for _, date := range []string{f.From, f.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return errors.New("las fechas deben tener el formato 2006-01-02")
+		}
+	}
+
+	return nil
+}
+
+// ReqBulkStatus cambio de estado de varios usuarios.
+type ReqBulkStatus struct {
+	UserIDs []uint `json:"user_ids" validate:"required,min=1,max=500"`
+	Status  string `json:"status" validate:"required,oneof=actived blocked"`
+}
@@ -0,0 +1,49 @@
+package types
+
+// UserDataExport todos los datos vinculados a un usuario, se entrega como un zip de archivos JSON.
+type UserDataExport struct {
+	Profile       UserProfileExport    `json:"profile"`
+	Tests         []TestModule         `json:"tests"`
+	Subscriptions []SubscriptionExport `json:"subscriptions"`
+	Enrollments   []EnrollmentExport   `json:"enrollments"`
+	Chats         []ChatExport         `json:"chats"`
+	GuardianLinks []GuardianLink       `json:"guardian_links"`
+	Sessions      []Session            `json:"sessions"`
+}
+
+type UserProfileExport struct {
+	UserAPI
+	CreatedAt string `json:"created_at"`
+}
+
+type SubscriptionExport struct {
+	ModuleID     uint   `json:"module_id"`
+	ModuleTitle  string `json:"module_title"`
+	SubscribedAt string `json:"subscribed_at"`
+}
+
+type EnrollmentExport struct {
+	ClassID    uint   `json:"class_id"`
+	ClassName  string `json:"class_name"`
+	EnrolledAt string `json:"enrolled_at"`
+}
+
+type ChatExport struct {
+	ID        uint                `json:"id"`
+	Issue     string              `json:"issue"`
+	CreatedAt string              `json:"created_at"`
+	Messages  []ChatMessageExport `json:"messages"`
+}
+
+type ChatMessageExport struct {
+	Message   string `json:"message"`
+	IsIA      bool   `json:"is_ia"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ReqAccountDeletion confirmación de la eliminación de la cuenta, las cuentas sin contraseña (Google) confirman con
+// el token enviado al correo.
+type ReqAccountDeletion struct {
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
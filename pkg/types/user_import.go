@@ -0,0 +1,22 @@
+package types
+
+// UserImportRow resultado de la importación de una fila del archivo.
+type UserImportRow struct {
+	Row               int    `json:"row"`
+	Email             string `json:"email"`
+	Status            string `json:"status"` // created, enrolled, error
+	Message           string `json:"message,omitempty"`
+	TemporaryPassword string `json:"temporary_password,omitempty"`
+}
+
+// UserImportReport reporte de la importación de usuarios, en dry_run no se guarda nada.
+type UserImportReport struct {
+	DryRun   bool            `json:"dry_run"`
+	Mode     string          `json:"mode"`
+	ClassID  uint            `json:"class_id"`
+	Total    int             `json:"total"`
+	Created  int             `json:"created"`
+	Enrolled int             `json:"enrolled"`
+	Failed   int             `json:"failed"`
+	Rows     []UserImportRow `json:"rows"`
+}
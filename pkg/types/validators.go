@@ -7,8 +7,22 @@ type ErrorField struct {
 	Tag   string `json:"tag"`
 }
 
+// RoleExists indica si existe el rol, la API lo reemplaza por la consulta a la base de datos al iniciar.
+// Por defecto solo acepta los roles base.
+var RoleExists = func(name string) bool {
+	switch name {
+	case "student", "teacher", "admin", "guardian":
+		return true
+	}
+	return false
+}
+
 func Validate(data interface{}) ([]ErrorField, error) {
 	validate := validator.New()
+	// role valida que el rol exista, así se pueden usar los roles creados desde /api/roles.
+	_ = validate.RegisterValidation("role", func(fl validator.FieldLevel) bool {
+		return RoleExists(fl.Field().String())
+	})
 	err := validate.Struct(data)
 
 	if err != nil {